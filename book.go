@@ -11,6 +11,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/dustin/go-humanize"
 	"github.com/goccy/go-json"
 	"github.com/goccy/go-yaml"
 	"github.com/k1LoW/duration"
@@ -36,7 +37,10 @@ type book struct {
 	grpcRunners      map[string]*grpcRunner
 	cdpRunners       map[string]*cdpRunner
 	sshRunners       map[string]*sshRunner
+	wsRunners        map[string]*wsRunner
 	profile          bool
+	coverage         bool
+	covThreshold     float64
 	intervalStr      string
 	interval         time.Duration
 	loop             *Loop
@@ -50,6 +54,7 @@ type book struct {
 	grpcNoTLS        bool
 	grpcProtos       []string
 	grpcImportPaths  []string
+	grpcProtosets    []string
 	runID            string
 	runMatch         *regexp.Regexp
 	runSample        int
@@ -68,6 +73,12 @@ type book struct {
 	stderr           io.Writer
 	// skip some errors for `runn list`
 	loadOnly bool
+	// record/replay of HTTP and gRPC traffic
+	recordDir              string
+	replayDir              string
+	replayIgnoreHeaders    []string
+	replayIgnoreBodyFields []string
+	replayStrict           bool
 }
 
 func LoadBook(path string) (*book, error) {
@@ -174,7 +185,7 @@ func (bk *book) parseRunner(k string, v any) error {
 	switch vv := v.(type) {
 	case string:
 		switch {
-		case strings.HasPrefix(vv, "https://") || strings.HasPrefix(vv, "http://"):
+		case strings.HasPrefix(vv, "https://") || strings.HasPrefix(vv, "http://") || isHTTPUnixSocketEndpoint(vv):
 			hc, err := newHTTPRunner(k, vv)
 			if err != nil {
 				return err
@@ -187,6 +198,12 @@ func (bk *book) parseRunner(k string, v any) error {
 				return err
 			}
 			bk.grpcRunners[k] = gc
+		case strings.HasPrefix(vv, grpcUnixScheme) || strings.HasPrefix(vv, unixScheme):
+			gc, err := newGrpcRunner(k, vv)
+			if err != nil {
+				return err
+			}
+			bk.grpcRunners[k] = gc
 		case strings.HasPrefix(vv, "cdp://") || strings.HasPrefix(vv, "chrome://"):
 			remote := strings.TrimPrefix(strings.TrimPrefix(vv, "cdp://"), "chrome://")
 			cc, err := newCDPRunner(k, remote)
@@ -194,6 +211,12 @@ func (bk *book) parseRunner(k string, v any) error {
 				return err
 			}
 			bk.cdpRunners[k] = cc
+		case strings.HasPrefix(vv, "ws://") || strings.HasPrefix(vv, "wss://"):
+			wc, err := newWSRunner(k, vv)
+			if err != nil {
+				return err
+			}
+			bk.wsRunners[k] = wc
 		case strings.HasPrefix(vv, "ssh://"):
 			addr := strings.TrimPrefix(vv, "ssh://")
 			sc, err := newSSHRunner(k, addr)
@@ -215,12 +238,20 @@ func (bk *book) parseRunner(k string, v any) error {
 		}
 		detect := false
 
-		// HTTP Runner
-		detect, err = bk.parseHTTPRunnerWithDetailed(k, tmp)
+		// WebSocket Runner
+		detect, err = bk.parseWSRunnerWithDetailed(k, tmp)
 		if err != nil {
 			return err
 		}
 
+		// HTTP Runner
+		if !detect {
+			detect, err = bk.parseHTTPRunnerWithDetailed(k, tmp)
+			if err != nil {
+				return err
+			}
+		}
+
 		// gRPC Runner
 		if !detect {
 			detect, err = bk.parseGRPCRunnerWithDetailed(k, tmp)
@@ -265,11 +296,26 @@ func (bk *book) parseHTTPRunnerWithDetailed(name string, b []byte) (bool, error)
 
 	if c.NotFollowRedirect {
 		r.client.CheckRedirect = notFollowRedirectFn
+	} else if c.MaxRedirects > 0 {
+		r.client.CheckRedirect = maxRedirectsFn(c.MaxRedirects)
 	}
 	r.multipartBoundary = c.MultipartBoundary
+	if c.Auth != nil {
+		r.auth, err = newHTTPAuth(c.Auth)
+		if err != nil {
+			return false, err
+		}
+	}
+	if c.Sign != nil {
+		if err := c.Sign.validate(); err != nil {
+			return false, err
+		}
+		r.sign = c.Sign
+	}
 	if c.OpenApi3DocLocation != "" && !strings.HasPrefix(c.OpenApi3DocLocation, "https://") && !strings.HasPrefix(c.OpenApi3DocLocation, "http://") && !strings.HasPrefix(c.OpenApi3DocLocation, "/") {
 		c.OpenApi3DocLocation = fp(c.OpenApi3DocLocation, root)
 	}
+	c.root = root
 	if c.CACert != "" {
 		b, err := readFile(fp(c.CACert, root))
 		if err != nil {
@@ -298,6 +344,16 @@ func (bk *book) parseHTTPRunnerWithDetailed(name string, b []byte) (bool, error)
 			return false, fmt.Errorf("timeout in HttpRunnerConfig is invalid: %w", err)
 		}
 	}
+	if c.MaxBodySize != "" {
+		r.maxBodySize, err = humanize.ParseBytes(c.MaxBodySize)
+		if err != nil {
+			return false, fmt.Errorf("maxBodySize in HttpRunnerConfig is invalid: %w", err)
+		}
+	}
+	r.dialer, err = c.newDialer()
+	if err != nil {
+		return false, err
+	}
 	r.useCookie = c.UseCookie
 	hv, err := newHttpValidator(c)
 	if err != nil {
@@ -352,12 +408,73 @@ func (bk *book) parseGRPCRunnerWithDetailed(name string, b []byte) (bool, error)
 		r.key = b
 	}
 	r.skipVerify = c.SkipVerify
+	r.dialer, err = c.newDialer()
+	if err != nil {
+		return false, err
+	}
 	r.importPaths = c.ImportPaths
 	r.protos = c.Protos
+	r.protosets = c.Protosets
+	if err := validateGRPCProtocol(c.Protocol); err != nil {
+		return false, err
+	}
+	r.protocol = c.Protocol
+	if c.JSONSchema != "" {
+		r.jsonSchema, err = loadJSONSchema(c.JSONSchema, root)
+		if err != nil {
+			return false, err
+		}
+	}
 	bk.grpcRunners[name] = r
 	return true, nil
 }
 
+func (bk *book) parseWSRunnerWithDetailed(name string, b []byte) (bool, error) {
+	c := &wsRunnerConfig{}
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return false, nil
+	}
+	if !strings.HasPrefix(c.Endpoint, "ws://") && !strings.HasPrefix(c.Endpoint, "wss://") {
+		return false, nil
+	}
+	root, err := bk.generateOperatorRoot()
+	if err != nil {
+		return false, err
+	}
+	r, err := newWSRunner(name, c.Endpoint)
+	if err != nil {
+		return false, err
+	}
+	for k, v := range c.Headers {
+		r.headers.Set(k, v)
+	}
+	r.subprotocols = c.Subprotocols
+	if c.CACert != "" {
+		b, err := readFile(fp(c.CACert, root))
+		if err != nil {
+			return false, err
+		}
+		r.cacert = b
+	}
+	if c.Cert != "" {
+		b, err := readFile(fp(c.Cert, root))
+		if err != nil {
+			return false, err
+		}
+		r.cert = b
+	}
+	if c.Key != "" {
+		b, err := readFile(fp(c.Key, root))
+		if err != nil {
+			return false, err
+		}
+		r.key = b
+	}
+	r.skipVerify = c.SkipVerify
+	bk.wsRunners[name] = r
+	return true, nil
+}
+
 func (bk *book) parseSSHRunnerWithDetailed(name string, b []byte) (bool, error) {
 	c := &sshRunnerConfig{}
 	if err := yaml.Unmarshal(b, c); err != nil {
@@ -491,6 +608,9 @@ func (bk *book) merge(loaded *book) error {
 	for k, r := range loaded.sshRunners {
 		bk.sshRunners[k] = r
 	}
+	for k, r := range loaded.wsRunners {
+		bk.wsRunners[k] = r
+	}
 	for k, v := range loaded.vars {
 		bk.vars[k] = v
 	}
@@ -510,6 +630,7 @@ func (bk *book) merge(loaded *book) error {
 	bk.grpcNoTLS = loaded.grpcNoTLS
 	bk.grpcProtos = loaded.grpcProtos
 	bk.grpcImportPaths = loaded.grpcImportPaths
+	bk.grpcProtosets = loaded.grpcProtosets
 	if loaded.intervalStr != "" {
 		bk.interval = loaded.interval
 	}
@@ -557,6 +678,7 @@ func newBook() *book {
 		grpcRunners: map[string]*grpcRunner{},
 		cdpRunners:  map[string]*cdpRunner{},
 		sshRunners:  map[string]*sshRunner{},
+		wsRunners:   map[string]*wsRunner{},
 		interval:    0 * time.Second,
 		runnerErrs:  map[string]error{},
 		stdout:      os.Stdout,
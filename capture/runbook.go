@@ -39,6 +39,8 @@ type runbook struct {
 	currentGRPCStatus        *status.Status
 	currentGRPCResponceIndex int
 	currentGRPCTestCond      []string
+	currentWSResponseIndex   int
+	currentWSTestCond        []string
 	currentExecTestCond      []string
 }
 
@@ -149,6 +151,11 @@ func (c *cRunbook) CaptureHTTPResponse(name string, res *http.Response) {
 
 	// body
 	contentType := res.Header.Get("Content-Type")
+	if res.Body == http.NoBody && (strings.HasPrefix(contentType, "text/event-stream") || strings.Contains(contentType, "ndjson")) {
+		// The body of the streaming response is read by the runner as messages
+		r.replaceLatestStep(append(step, yaml.MapItem{Key: "test", Value: fmt.Sprintf("%s\n", strings.Join(cond, "\n&& "))}))
+		return
+	}
 	var (
 		save io.ReadCloser
 		err  error
@@ -188,6 +195,10 @@ func (c *cRunbook) CaptureHTTPResponse(name string, res *http.Response) {
 	r.replaceLatestStep(append(step, yaml.MapItem{Key: "test", Value: fmt.Sprintf("%s\n", strings.Join(cond, "\n&& "))}))
 }
 
+func (c *cRunbook) CaptureHTTPTrace(name string, trace *runn.HTTPTrace) {}
+
+func (c *cRunbook) CaptureHTTPStreamMessage(name string, m any) {}
+
 func (c *cRunbook) CaptureGRPCStart(name string, typ runn.GRPCType, service, method string) {
 	const dummyDsn = "[THIS IS gRPC RUNNER]"
 	if v, ok := c.runners[name]; ok {
@@ -342,6 +353,77 @@ func (c *cRunbook) CaptureSSHStderr(stderr string) {
 	// FIXME: not implemented
 }
 
+func (c *cRunbook) CaptureWSStart(name string) {
+	const dummyDsn = "[THIS IS WebSocket RUNNER]"
+	if v, ok := c.runners[name]; ok {
+		c.setRunner(name, v)
+	} else {
+		c.setRunner(name, dummyDsn)
+	}
+	r := c.currentRunbook()
+	if r == nil {
+		return
+	}
+	step := yaml.MapSlice{
+		{Key: name, Value: yaml.MapSlice{
+			{Key: "messages", Value: []any{}},
+		}},
+	}
+	r.Steps = append(r.Steps, step)
+}
+
+func (c *cRunbook) CaptureWSConnect(name, endpoint string, h map[string][]string) {}
+
+func (c *cRunbook) CaptureWSSend(name string, m map[string]any) {
+	r := c.currentRunbook()
+	if r == nil {
+		return
+	}
+	typ, _ := m["type"].(string)
+	c.appendWSOp(r, map[string]any{typ: m["data"]})
+}
+
+func (c *cRunbook) CaptureWSReceive(name string, m map[string]any) {
+	r := c.currentRunbook()
+	if r == nil {
+		return
+	}
+	c.appendWSOp(r, string(runn.WSOpReceive))
+	b, err := json.Marshal(m["data"])
+	if err != nil {
+		c.errs = multierr.Append(c.errs, fmt.Errorf("failed to json.Marshal: %w", err))
+		return
+	}
+	cond := fmt.Sprintf("current.res.messages[%d].data == %s", r.currentWSResponseIndex, string(b))
+	r.currentWSTestCond = append(r.currentWSTestCond, cond)
+	r.currentWSResponseIndex += 1
+}
+
+func (c *cRunbook) CaptureWSClose(name string, code int, reason string) {
+	r := c.currentRunbook()
+	if r == nil {
+		return
+	}
+	c.appendWSOp(r, map[string]any{string(runn.WSOpClose): code})
+}
+
+func (c *cRunbook) CaptureWSEnd(name string) {
+	r := c.currentRunbook()
+	if r == nil {
+		return
+	}
+	defer func() {
+		r.currentWSTestCond = nil
+		r.currentWSResponseIndex = 0
+	}()
+	if len(r.currentWSTestCond) == 0 {
+		return
+	}
+	step := r.latestStep()
+	step = append(step, yaml.MapItem{Key: "test", Value: fmt.Sprintf("%s\n", strings.Join(r.currentWSTestCond, "\n&& "))})
+	r.replaceLatestStep(step)
+}
+
 func (c *cRunbook) CaptureDBStatement(name string, stmt string) {
 	const dummyDsn = "[THIS IS DB RUNNER]"
 	if v, ok := c.runners[name]; ok {
@@ -523,6 +605,21 @@ func (c *cRunbook) appendOp(hb yaml.MapSlice, m any) yaml.MapSlice {
 	return hb
 }
 
+func (c *cRunbook) appendWSOp(r *runbook, m any) {
+	step := r.latestStep()
+	req, ok := step[0].Value.(yaml.MapSlice)
+	if !ok || len(req) == 0 {
+		c.errs = multierr.Append(c.errs, fmt.Errorf("failed to get WebSocket request: %v", step[0].Value))
+		return
+	}
+	ms, ok := req[0].Value.([]any)
+	if !ok {
+		c.errs = multierr.Append(c.errs, fmt.Errorf("failed to get messages: %v", req[0].Value))
+		return
+	}
+	req[0].Value = append(ms, m)
+}
+
 func (c *cRunbook) writeRunbook(trs runn.Trails, bookPath string) {
 	v, ok := c.runbooks.Load(trs[0])
 	if !ok {
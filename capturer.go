@@ -14,6 +14,8 @@ type Capturer interface {
 
 	CaptureHTTPRequest(name string, req *http.Request)
 	CaptureHTTPResponse(name string, res *http.Response)
+	CaptureHTTPTrace(name string, trace *HTTPTrace)
+	CaptureHTTPStreamMessage(name string, m any)
 
 	CaptureGRPCStart(name string, typ GRPCType, service, method string)
 	CaptureGRPCRequestHeaders(h map[string][]string)
@@ -34,6 +36,13 @@ type Capturer interface {
 	CaptureSSHStdout(stdout string)
 	CaptureSSHStderr(stderr string)
 
+	CaptureWSStart(name string)
+	CaptureWSConnect(name, endpoint string, h map[string][]string)
+	CaptureWSSend(name string, m map[string]any)
+	CaptureWSReceive(name string, m map[string]any)
+	CaptureWSClose(name string, code int, reason string)
+	CaptureWSEnd(name string)
+
 	CaptureDBStatement(name string, stmt string)
 	CaptureDBResponse(name string, res *DBResponse)
 
@@ -78,6 +87,18 @@ func (cs capturers) captureHTTPResponse(name string, res *http.Response) { //nos
 	}
 }
 
+func (cs capturers) captureHTTPTrace(name string, trace *HTTPTrace) { //nostyle:recvtype
+	for _, c := range cs {
+		c.CaptureHTTPTrace(name, trace)
+	}
+}
+
+func (cs capturers) captureHTTPStreamMessage(name string, m any) { //nostyle:recvtype
+	for _, c := range cs {
+		c.CaptureHTTPStreamMessage(name, m)
+	}
+}
+
 func (cs capturers) captureGRPCStart(name string, typ GRPCType, service, method string) { //nostyle:recvtype
 	for _, c := range cs {
 		c.CaptureGRPCStart(name, typ, service, method)
@@ -173,6 +194,42 @@ func (cs capturers) captureSSHStderr(stderr string) { //nostyle:recvtype
 	}
 }
 
+func (cs capturers) captureWSStart(name string) { //nostyle:recvtype
+	for _, c := range cs {
+		c.CaptureWSStart(name)
+	}
+}
+
+func (cs capturers) captureWSConnect(name, endpoint string, h map[string][]string) { //nostyle:recvtype
+	for _, c := range cs {
+		c.CaptureWSConnect(name, endpoint, h)
+	}
+}
+
+func (cs capturers) captureWSSend(name string, m map[string]any) { //nostyle:recvtype
+	for _, c := range cs {
+		c.CaptureWSSend(name, m)
+	}
+}
+
+func (cs capturers) captureWSReceive(name string, m map[string]any) { //nostyle:recvtype
+	for _, c := range cs {
+		c.CaptureWSReceive(name, m)
+	}
+}
+
+func (cs capturers) captureWSClose(name string, code int, reason string) { //nostyle:recvtype
+	for _, c := range cs {
+		c.CaptureWSClose(name, code, reason)
+	}
+}
+
+func (cs capturers) captureWSEnd(name string) { //nostyle:recvtype
+	for _, c := range cs {
+		c.CaptureWSEnd(name)
+	}
+}
+
 func (cs capturers) captureDBStatement(name string, stmt string) { //nostyle:recvtype
 	for _, c := range cs {
 		c.CaptureDBStatement(name, stmt)
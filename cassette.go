@@ -0,0 +1,770 @@
+package runn
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/goccy/go-json"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+type cassetteMode string
+
+const (
+	cassetteModeRecord cassetteMode = "record"
+	cassetteModeReplay cassetteMode = "replay"
+)
+
+const cassetteExt = ".json"
+
+// cassetteConfig is the configuration of record and replay of HTTP and gRPC traffic.
+type cassetteConfig struct {
+	mode             cassetteMode
+	dir              string
+	ignoreHeaders    []string
+	ignoreBodyFields []string
+	strict           bool
+}
+
+// cassette is the recorded request/response pairs of a runbook.
+type cassette struct {
+	cfg          *cassetteConfig
+	path         string
+	interactions []*cassetteInteraction
+	descriptors  map[string][]byte
+	mu           sync.Mutex
+}
+
+type cassetteFile struct {
+	ID           string                 `json:"id"`
+	Interactions []*cassetteInteraction `json:"interactions"`
+	// Descriptors are the serialized FileDescriptorSets of the methods resolved by gRPC runners, keyed by the runner name.
+	// They are used to replay gRPC calls without server reflection.
+	Descriptors map[string][]byte `json:"descriptors,omitempty"`
+}
+
+// cassetteInteraction is a request/response pair sent by the runner of the step.
+type cassetteInteraction struct {
+	Step   int           `json:"step"`
+	Runner string        `json:"runner"`
+	HTTP   *cassetteHTTP `json:"http,omitempty"`
+	GRPC   *cassetteGRPC `json:"grpc,omitempty"`
+	used   bool
+}
+
+type cassetteHTTP struct {
+	Request  cassetteHTTPRequest  `json:"request"`
+	Response cassetteHTTPResponse `json:"response"`
+}
+
+type cassetteHTTPRequest struct {
+	Method  string       `json:"method"`
+	URL     string       `json:"url"`
+	Headers http.Header  `json:"headers,omitempty"`
+	Body    cassetteBody `json:"body,omitempty"`
+}
+
+type cassetteHTTPResponse struct {
+	Status  int          `json:"status"`
+	Headers http.Header  `json:"headers,omitempty"`
+	Body    cassetteBody `json:"body,omitempty"`
+}
+
+type cassetteGRPC struct {
+	Method           string            `json:"method"`
+	Headers          metadata.MD       `json:"headers,omitempty"`
+	Requests         []json.RawMessage `json:"requests,omitempty"`
+	ResponseHeaders  metadata.MD       `json:"responseHeaders,omitempty"`
+	ResponseTrailers metadata.MD       `json:"responseTrailers,omitempty"`
+	Responses        []json.RawMessage `json:"responses,omitempty"`
+	Code             codes.Code        `json:"code"`
+	Message          string            `json:"message,omitempty"`
+}
+
+// cassetteBody is the body of the request or response.
+// It is encoded as a string if it is valid UTF-8, otherwise as {"base64": "..."}.
+type cassetteBody []byte
+
+type cassetteBase64Body struct {
+	Base64 string `json:"base64"`
+}
+
+func (b cassetteBody) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(cassetteBase64Body{Base64: base64.StdEncoding.EncodeToString(b)})
+}
+
+func (b *cassetteBody) UnmarshalJSON(in []byte) error {
+	var s string
+	if err := json.Unmarshal(in, &s); err == nil {
+		*b = cassetteBody(s)
+		return nil
+	}
+	var bb cassetteBase64Body
+	if err := json.Unmarshal(in, &bb); err != nil {
+		return err
+	}
+	d, err := base64.StdEncoding.DecodeString(bb.Base64)
+	if err != nil {
+		return err
+	}
+	*b = d
+	return nil
+}
+
+type cassetteStepKey struct{}
+
+type cassetteHeadersKey struct{}
+
+type cassetteRedactionKey struct{}
+
+// cassetteRedacted is the value recorded instead of the redacted fields.
+const cassetteRedacted = "REDACTED"
+
+// cassetteRedaction is the fields of the request body ( form ) and the response body ( JSON ) that are recorded as cassetteRedacted.
+type cassetteRedaction struct {
+	requestFields  []string
+	responseFields []string
+}
+
+// cassetteCredentialHeaders are the headers of credentials that are neither recorded nor matched.
+var cassetteCredentialHeaders = []string{"Authorization", "Proxy-Authorization"}
+
+// withCassetteStep returns the context with the index of the running step to key interactions.
+func withCassetteStep(ctx context.Context, idx int) context.Context {
+	return context.WithValue(ctx, cassetteStepKey{}, idx)
+}
+
+func cassetteStep(ctx context.Context) int {
+	idx, ok := ctx.Value(cassetteStepKey{}).(int)
+	if !ok {
+		return 0
+	}
+	return idx
+}
+
+// withCassetteHeaders returns the context with the headers of the request before authorization and signing.
+// The cassette records and matches them instead of the headers sent, so that credentials and signatures that change on every run are not recorded.
+func withCassetteHeaders(ctx context.Context, h http.Header) context.Context {
+	return context.WithValue(ctx, cassetteHeadersKey{}, h)
+}
+
+func cassetteHeaders(req *http.Request) http.Header {
+	h, ok := req.Context().Value(cassetteHeadersKey{}).(http.Header)
+	if !ok {
+		h = req.Header
+	}
+	h = h.Clone()
+	removeCredentialHeaders(h, http.CanonicalHeaderKey)
+	return h
+}
+
+// cassetteMetadata returns the copy of the metadata of the gRPC request to record without the headers of credentials.
+func cassetteMetadata(md metadata.MD) metadata.MD {
+	c := md.Copy()
+	removeCredentialHeaders(c, strings.ToLower)
+	return c
+}
+
+// removeCredentialHeaders removes the headers of credentials from the headers.
+func removeCredentialHeaders(h map[string][]string, canonical func(string) string) {
+	for k := range h {
+		if isCredentialHeader(canonical(k), canonical) {
+			delete(h, k)
+		}
+	}
+}
+
+func isCredentialHeader(k string, canonical func(string) string) bool {
+	for _, ch := range cassetteCredentialHeaders {
+		if canonical(ch) == k {
+			return true
+		}
+	}
+	return false
+}
+
+// withCassetteRedaction returns the context with the fields of the request to be redacted in the cassette ( e.g. credentials and tokens of OAuth 2.0 token requests ).
+// Redacted fields of the request body are also redacted on matching, so that requests with different credentials match.
+func withCassetteRedaction(ctx context.Context, r *cassetteRedaction) context.Context {
+	return context.WithValue(ctx, cassetteRedactionKey{}, r)
+}
+
+func cassetteRedactionFromRequest(req *http.Request) *cassetteRedaction {
+	r, ok := req.Context().Value(cassetteRedactionKey{}).(*cassetteRedaction)
+	if !ok {
+		return nil
+	}
+	return r
+}
+
+// redactRequestBody returns the form body with the fields redacted.
+func (r *cassetteRedaction) redactRequestBody(b []byte) []byte {
+	if r == nil || len(b) == 0 {
+		return b
+	}
+	v, err := url.ParseQuery(string(b))
+	if err != nil {
+		return b
+	}
+	for _, f := range r.requestFields {
+		if v.Has(f) {
+			v.Set(f, cassetteRedacted)
+		}
+	}
+	return []byte(v.Encode())
+}
+
+// redactResponseBody returns the JSON body with the fields redacted.
+func (r *cassetteRedaction) redactResponseBody(b []byte) []byte {
+	if r == nil || len(b) == 0 {
+		return b
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return b
+	}
+	for _, f := range r.responseFields {
+		if _, ok := m[f]; ok {
+			m[f] = cassetteRedacted
+		}
+	}
+	rb, err := json.Marshal(m)
+	if err != nil {
+		return b
+	}
+	return rb
+}
+
+// openCassette opens the cassette of the runbook. In replay mode, the recorded interactions are loaded.
+func (o *operator) openCassette() error {
+	if o.cassetteCfg == nil {
+		return nil
+	}
+	c := &cassette{
+		cfg:  o.cassetteCfg,
+		path: filepath.Join(o.cassetteCfg.dir, o.id+cassetteExt),
+	}
+	if c.cfg.mode == cassetteModeReplay {
+		b, err := os.ReadFile(c.path)
+		switch {
+		case err == nil:
+			var cf cassetteFile
+			if err := json.Unmarshal(b, &cf); err != nil {
+				return fmt.Errorf("failed to load cassette %s: %w", c.path, err)
+			}
+			c.interactions = cf.Interactions
+			c.descriptors = cf.Descriptors
+		case errors.Is(err, os.ErrNotExist) && !c.cfg.strict:
+			o.Debugf("Cassette %s is not found. All requests are sent to the servers\n", c.path)
+		default:
+			return fmt.Errorf("failed to load cassette %s: %w", c.path, err)
+		}
+	}
+	o.cassette = c
+	return nil
+}
+
+// closeCassette closes the cassette of the runbook. In record mode, the recorded interactions are saved.
+func (o *operator) closeCassette() error {
+	c := o.cassette
+	if c == nil {
+		return nil
+	}
+	o.cassette = nil
+	if c.cfg.mode != cassetteModeRecord || len(c.interactions) == 0 {
+		return nil
+	}
+	b, err := json.MarshalIndent(cassetteFile{ID: o.id, Interactions: c.interactions, Descriptors: c.descriptors}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to save cassette %s: %w", c.path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("failed to save cassette %s: %w", c.path, err)
+	}
+	if err := os.WriteFile(c.path, b, 0o600); err != nil {
+		return fmt.Errorf("failed to save cassette %s: %w", c.path, err)
+	}
+	return nil
+}
+
+func (c *cassette) record(i *cassetteInteraction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.interactions = append(c.interactions, i)
+}
+
+// find returns the first unused interaction of the step and the runner that matches.
+func (c *cassette) find(step int, runner string, match func(*cassetteInteraction) bool) *cassetteInteraction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, i := range c.interactions {
+		if i.used || i.Step != step || i.Runner != runner {
+			continue
+		}
+		if match(i) {
+			i.used = true
+			return i
+		}
+	}
+	return nil
+}
+
+// recordDescriptors records the files of the methods resolved by the gRPC runner and the files imported by them.
+func (c *cassette) recordDescriptors(runner string, mds map[string]protoreflect.MethodDescriptor) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.descriptors[runner]; ok {
+		return nil
+	}
+	files := map[string]protoreflect.FileDescriptor{}
+	for _, md := range mds {
+		fd := md.ParentFile()
+		files[fd.Path()] = fd
+	}
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	// Sort to save the same cassette for the same methods
+	sort.Strings(paths)
+	fds := make([]protoreflect.FileDescriptor, 0, len(paths))
+	for _, p := range paths {
+		fds = append(fds, files[p])
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, fd := range withImports(fds) {
+		set.File = append(set.File, protodesc.ToFileDescriptorProto(fd))
+	}
+	b, err := proto.Marshal(set)
+	if err != nil {
+		return fmt.Errorf("failed to record descriptors of %s: %w", runner, err)
+	}
+	if c.descriptors == nil {
+		c.descriptors = map[string][]byte{}
+	}
+	c.descriptors[runner] = b
+	return nil
+}
+
+// replayDescriptors returns the recorded files of the gRPC runner. It returns false if they are not recorded.
+func (c *cassette) replayDescriptors(runner string) ([]protoreflect.FileDescriptor, bool, error) {
+	c.mu.Lock()
+	b, ok := c.descriptors[runner]
+	c.mu.Unlock()
+	if !ok {
+		return nil, false, nil
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(b, set); err != nil {
+		return nil, false, fmt.Errorf("failed to load descriptors of %s from cassette %s: %w", runner, c.path, err)
+	}
+	fds, err := buildFileDescriptorSets(set)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to load descriptors of %s from cassette %s: %w", runner, c.path, err)
+	}
+	return fds, true, nil
+}
+
+func (c *cassette) notFoundError(step int, runner, req string) error {
+	return fmt.Errorf("no matching interaction in cassette %s (step: %d, runner: %s): %s", c.path, step, runner, req)
+}
+
+// httpTransport returns http.RoundTripper that records the traffic of the runner or replays it from the cassette.
+func (c *cassette) httpTransport(runner string, next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		step := cassetteStep(req.Context())
+		creq, err := newCassetteHTTPRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		if c.cfg.mode == cassetteModeReplay {
+			i := c.find(step, runner, func(i *cassetteInteraction) bool {
+				return i.HTTP != nil && c.matchHTTPRequest(i.HTTP.Request, creq)
+			})
+			if i != nil {
+				return i.HTTP.Response.toResponse(req), nil
+			}
+			if c.cfg.strict {
+				return nil, c.notFoundError(step, runner, fmt.Sprintf("%s %s", creq.Method, creq.URL))
+			}
+			return next.RoundTrip(req)
+		}
+		res, err := next.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		b, err := io.ReadAll(res.Body)
+		if err != nil {
+			return nil, err
+		}
+		_ = res.Body.Close()
+		res.Body = io.NopCloser(bytes.NewReader(b))
+		c.record(&cassetteInteraction{
+			Step:   step,
+			Runner: runner,
+			HTTP: &cassetteHTTP{
+				Request: creq,
+				Response: cassetteHTTPResponse{
+					Status:  res.StatusCode,
+					Headers: res.Header.Clone(),
+					Body:    cassetteRedactionFromRequest(req).redactResponseBody(b),
+				},
+			},
+		})
+		return res, nil
+	})
+}
+
+func newCassetteHTTPRequest(req *http.Request) (cassetteHTTPRequest, error) {
+	creq := cassetteHTTPRequest{
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Headers: cassetteHeaders(req),
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		return creq, nil
+	}
+	b, err := io.ReadAll(req.Body)
+	if err != nil {
+		return creq, err
+	}
+	_ = req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(b))
+	creq.Body = cassetteRedactionFromRequest(req).redactRequestBody(b)
+	return creq, nil
+}
+
+func (r cassetteHTTPResponse) toResponse(req *http.Request) *http.Response {
+	h := r.Headers.Clone()
+	if h == nil {
+		h = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status)),
+		StatusCode:    r.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          io.NopCloser(bytes.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
+
+func (c *cassette) matchHTTPRequest(recorded, req cassetteHTTPRequest) bool {
+	if recorded.Method != req.Method || recorded.URL != req.URL {
+		return false
+	}
+	if !c.matchHeaders(recorded.Headers, req.Headers, http.CanonicalHeaderKey) {
+		return false
+	}
+	return c.matchBody(recorded.Body, req.Body)
+}
+
+// matchHeaders reports whether the headers are equal except for the ignored headers and the headers of credentials.
+func (c *cassette) matchHeaders(recorded, h map[string][]string, canonical func(string) string) bool {
+	normalize := func(h map[string][]string) map[string][]string {
+		n := map[string][]string{}
+		for k, v := range h {
+			k = canonical(k)
+			if c.ignoreHeader(k, canonical) || isCredentialHeader(k, canonical) {
+				continue
+			}
+			n[k] = append(n[k], v...)
+		}
+		return n
+	}
+	return reflect.DeepEqual(normalize(recorded), normalize(h))
+}
+
+func (c *cassette) ignoreHeader(k string, canonical func(string) string) bool {
+	for _, ih := range c.cfg.ignoreHeaders {
+		if canonical(ih) == k {
+			return true
+		}
+	}
+	return false
+}
+
+// matchBody reports whether the bodies are equal. JSON bodies are compared except for the ignored fields.
+func (c *cassette) matchBody(recorded, body []byte) bool {
+	if bytes.Equal(recorded, body) {
+		return true
+	}
+	var rv, bv any
+	if err := json.Unmarshal(recorded, &rv); err != nil {
+		return false
+	}
+	if err := json.Unmarshal(body, &bv); err != nil {
+		return false
+	}
+	for _, f := range c.cfg.ignoreBodyFields {
+		keys := strings.Split(f, ".")
+		removeField(rv, keys)
+		removeField(bv, keys)
+	}
+	return reflect.DeepEqual(rv, bv)
+}
+
+// removeField removes the field of the dot-separated keys from v. Keys are applied to each element of arrays.
+func removeField(v any, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	switch vv := v.(type) {
+	case map[string]any:
+		if len(keys) == 1 {
+			delete(vv, keys[0])
+			return
+		}
+		removeField(vv[keys[0]], keys[1:])
+	case []any:
+		for _, e := range vv {
+			removeField(e, keys)
+		}
+	}
+}
+
+// grpcConn returns grpc.ClientConnInterface that records the calls of the runner or replays them from the cassette.
+func (c *cassette) grpcConn(runner string, cc grpc.ClientConnInterface) grpc.ClientConnInterface {
+	return &cassetteGRPCConn{
+		c:      c,
+		runner: runner,
+		cc:     cc,
+	}
+}
+
+type cassetteGRPCConn struct {
+	c      *cassette
+	runner string
+	cc     grpc.ClientConnInterface
+}
+
+func (cc *cassetteGRPCConn) Invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	step := cassetteStep(ctx)
+	req, err := marshalProtoJSON(args)
+	if err != nil {
+		return err
+	}
+	headers, _ := metadata.FromOutgoingContext(ctx)
+	if cc.c.cfg.mode == cassetteModeReplay {
+		i := cc.c.find(step, cc.runner, func(i *cassetteInteraction) bool {
+			return i.GRPC != nil && i.GRPC.Method == method &&
+				cc.c.matchHeaders(i.GRPC.Headers, headers, strings.ToLower) &&
+				len(i.GRPC.Requests) == 1 && cc.c.matchBody(i.GRPC.Requests[0], req)
+		})
+		if i != nil {
+			return i.GRPC.replayUnary(reply, opts)
+		}
+		if cc.c.cfg.strict {
+			return cc.c.notFoundError(step, cc.runner, method)
+		}
+		return cc.cc.Invoke(ctx, method, args, reply, opts...)
+	}
+	var resHeaders, resTrailers metadata.MD
+	err = cc.cc.Invoke(ctx, method, args, reply, append(opts, grpc.Header(&resHeaders), grpc.Trailer(&resTrailers))...)
+	stat, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	g := &cassetteGRPC{
+		Method:           method,
+		Headers:          cassetteMetadata(headers),
+		Requests:         []json.RawMessage{req},
+		ResponseHeaders:  resHeaders,
+		ResponseTrailers: resTrailers,
+		Code:             stat.Code(),
+		Message:          stat.Message(),
+	}
+	if stat.Code() == codes.OK {
+		res, err := marshalProtoJSON(reply)
+		if err != nil {
+			return err
+		}
+		g.Responses = []json.RawMessage{res}
+	}
+	cc.c.record(&cassetteInteraction{Step: step, Runner: cc.runner, GRPC: g})
+	return err
+}
+
+func (cc *cassetteGRPCConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	step := cassetteStep(ctx)
+	headers, _ := metadata.FromOutgoingContext(ctx)
+	if cc.c.cfg.mode == cassetteModeReplay {
+		// Streams are matched without the request messages because they are not sent yet.
+		i := cc.c.find(step, cc.runner, func(i *cassetteInteraction) bool {
+			return i.GRPC != nil && i.GRPC.Method == method &&
+				cc.c.matchHeaders(i.GRPC.Headers, headers, strings.ToLower)
+		})
+		if i != nil {
+			return &cassetteReplayStream{ctx: ctx, g: i.GRPC}, nil
+		}
+		if cc.c.cfg.strict {
+			return nil, cc.c.notFoundError(step, cc.runner, method)
+		}
+		return cc.cc.NewStream(ctx, desc, method, opts...)
+	}
+	s, err := cc.cc.NewStream(ctx, desc, method, opts...)
+	if err != nil {
+		return nil, err
+	}
+	g := &cassetteGRPC{
+		Method:  method,
+		Headers: cassetteMetadata(headers),
+	}
+	cc.c.record(&cassetteInteraction{Step: step, Runner: cc.runner, GRPC: g})
+	return &cassetteRecordStream{ClientStream: s, c: cc.c, desc: desc, g: g}, nil
+}
+
+func (g *cassetteGRPC) replayUnary(reply any, opts []grpc.CallOption) error {
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case grpc.HeaderCallOption:
+			*o.HeaderAddr = g.ResponseHeaders.Copy()
+		case grpc.TrailerCallOption:
+			*o.TrailerAddr = g.ResponseTrailers.Copy()
+		}
+	}
+	if g.Code != codes.OK {
+		return status.Error(g.Code, g.Message)
+	}
+	if len(g.Responses) != 1 {
+		return fmt.Errorf("invalid recorded response of %s", g.Method)
+	}
+	return unmarshalProtoJSON(g.Responses[0], reply)
+}
+
+// cassetteRecordStream records the messages and the status of the stream.
+type cassetteRecordStream struct {
+	grpc.ClientStream
+	c    *cassette
+	desc *grpc.StreamDesc
+	g    *cassetteGRPC
+}
+
+func (s *cassetteRecordStream) SendMsg(m any) error {
+	if err := s.ClientStream.SendMsg(m); err != nil {
+		return err
+	}
+	b, err := marshalProtoJSON(m)
+	if err != nil {
+		return err
+	}
+	s.c.mu.Lock()
+	defer s.c.mu.Unlock()
+	s.g.Requests = append(s.g.Requests, b)
+	return nil
+}
+
+func (s *cassetteRecordStream) RecvMsg(m any) error {
+	if err := s.ClientStream.RecvMsg(m); err != nil {
+		s.finish(err)
+		return err
+	}
+	b, err := marshalProtoJSON(m)
+	if err != nil {
+		return err
+	}
+	s.c.mu.Lock()
+	s.g.Responses = append(s.g.Responses, b)
+	s.c.mu.Unlock()
+	if !s.desc.ServerStreams {
+		// The stream of client streaming RPC is finished after receiving the response
+		s.finish(nil)
+	}
+	return nil
+}
+
+func (s *cassetteRecordStream) finish(err error) {
+	h, _ := s.ClientStream.Header()
+	t := s.ClientStream.Trailer()
+	s.c.mu.Lock()
+	defer s.c.mu.Unlock()
+	s.g.ResponseHeaders = h
+	s.g.ResponseTrailers = t
+	if err != nil && !errors.Is(err, io.EOF) {
+		stat := status.Convert(err)
+		s.g.Code = stat.Code()
+		s.g.Message = stat.Message()
+	}
+}
+
+// cassetteReplayStream replays the recorded messages and the status of the stream.
+type cassetteReplayStream struct {
+	ctx context.Context
+	g   *cassetteGRPC
+	n   int
+}
+
+func (s *cassetteReplayStream) Header() (metadata.MD, error) {
+	return s.g.ResponseHeaders.Copy(), nil
+}
+
+func (s *cassetteReplayStream) Trailer() metadata.MD {
+	return s.g.ResponseTrailers.Copy()
+}
+
+func (s *cassetteReplayStream) CloseSend() error {
+	return nil
+}
+
+func (s *cassetteReplayStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *cassetteReplayStream) SendMsg(m any) error {
+	return nil
+}
+
+func (s *cassetteReplayStream) RecvMsg(m any) error {
+	if s.n < len(s.g.Responses) {
+		b := s.g.Responses[s.n]
+		s.n++
+		return unmarshalProtoJSON(b, m)
+	}
+	if s.g.Code != codes.OK {
+		return status.Error(s.g.Code, s.g.Message)
+	}
+	return io.EOF
+}
+
+func marshalProtoJSON(m any) (json.RawMessage, error) {
+	pm, ok := m.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("invalid message type: %T", m)
+	}
+	return protojson.Marshal(pm)
+}
+
+func unmarshalProtoJSON(b []byte, m any) error {
+	pm, ok := m.(proto.Message)
+	if !ok {
+		return fmt.Errorf("invalid message type: %T", m)
+	}
+	return protojson.Unmarshal(b, pm)
+}
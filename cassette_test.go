@@ -0,0 +1,385 @@
+package runn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestCassetteHTTP(t *testing.T) {
+	var count int64
+	h := http.NewServeMux()
+	h.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&count, 1)
+		w.Header().Set("Content-Type", MediaTypeApplicationJSON)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":1}`))
+	})
+	h.HandleFunc("/users/1", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&count, 1)
+		w.Header().Set("Content-Type", MediaTypeApplicationJSON)
+		_, _ = w.Write([]byte(`{"id":1,"name":"alice"}`))
+	})
+	book := "testdata/book/cassette.yml"
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	o, err := New(Book(book), HTTPRunnerWithHandler("req", h), Record(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := atomic.LoadInt64(&count), int64(2); got != want {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, o.id+cassetteExt)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		opts      []Option
+		wantCount int64
+		wantErr   bool
+	}{
+		{"replay", nil, 0, false},
+		{"unmatched body", []Option{Var("requestedAt", "2023-01-01T00:00:00Z")}, 1, false},
+		{"unmatched body with strict", []Option{Var("requestedAt", "2023-01-01T00:00:00Z"), ReplayStrict(true)}, 0, true},
+		{"ignore body field", []Option{Var("requestedAt", "2023-01-01T00:00:00Z"), ReplayIgnoreBodyFields("requestedAt"), ReplayStrict(true)}, 0, false},
+		{"unmatched header with strict", []Option{Var("requestID", "2"), ReplayStrict(true)}, 0, true},
+		{"ignore header", []Option{Var("requestID", "2"), ReplayIgnoreHeaders("x-request-id"), ReplayStrict(true)}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt64(&count, 0)
+			opts := append([]Option{Book(book), HTTPRunnerWithHandler("req", h), Replay(dir)}, tt.opts...)
+			o, err := New(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := o.Run(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("got %v\nwantErr %v", err, tt.wantErr)
+			}
+			if got := atomic.LoadInt64(&count); got != tt.wantCount {
+				t.Errorf("got %v\nwant %v", got, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestCassetteHTTPWithCredentials(t *testing.T) {
+	var count int64
+	h := http.NewServeMux()
+	h.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&count, 1)
+		w.Header().Set("Content-Type", MediaTypeApplicationJSON)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":1}`))
+	})
+	h.HandleFunc("/users/1", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&count, 1)
+		w.Header().Set("Content-Type", MediaTypeApplicationJSON)
+		_, _ = w.Write([]byte(`{"id":1,"name":"alice"}`))
+	})
+	var signed int64
+	opts := func(token string) []httpRunnerOption {
+		return []httpRunnerOption{
+			HTTPBearerAuth(token),
+			// The signature changes on every request like signatures with timestamps
+			HTTPRequestSigner(func(req *http.Request) error {
+				req.Header.Set("X-Signature", fmt.Sprintf("signature-%d", atomic.AddInt64(&signed, 1)))
+				return nil
+			}),
+		}
+	}
+	book := "testdata/book/cassette.yml"
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	o, err := New(Book(book), HTTPRunnerWithHandler("req", h, opts("secret-token")...), Record(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, o.id+cassetteExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"secret-token", "Authorization", "signature-", "X-Signature"} {
+		if strings.Contains(string(b), s) {
+			t.Errorf("cassette should not contain %q\ngot: %s", s, string(b))
+		}
+	}
+
+	atomic.StoreInt64(&count, 0)
+	o, err = New(Book(book), HTTPRunnerWithHandler("req", h, opts("another-token")...), Replay(dir), ReplayStrict(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Run(ctx); err != nil {
+		t.Error(err)
+	}
+	if got := atomic.LoadInt64(&count); got != 0 {
+		t.Errorf("got %v\nwant %v", got, 0)
+	}
+}
+
+func TestCassetteOptions(t *testing.T) {
+	if _, err := New(Record("a"), Replay("b")); err == nil {
+		t.Error("want error")
+	}
+	o, err := New(Book("testdata/book/cassette.yml"), Replay(t.TempDir()), ReplayStrict(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Run(context.Background()); err == nil {
+		t.Error("want error because the cassette is not found")
+	}
+}
+
+func TestCassetteBody(t *testing.T) {
+	tests := []cassetteBody{
+		cassetteBody("hello"),
+		cassetteBody{0xff, 0xfe, 0x00},
+		cassetteBody(nil),
+	}
+	for _, tt := range tests {
+		b, err := tt.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got cassetteBody
+		if err := got.UnmarshalJSON(b); err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(tt) {
+			t.Errorf("got %v\nwant %v", got, tt)
+		}
+	}
+}
+
+func TestCassetteMatchBody(t *testing.T) {
+	tests := []struct {
+		recorded string
+		body     string
+		ignore   []string
+		want     bool
+	}{
+		{"hello", "hello", nil, true},
+		{"hello", "world", nil, false},
+		{`{"a":1,"b":2}`, `{"b":2,"a":1}`, nil, true},
+		{`{"a":1,"b":2}`, `{"a":1,"b":3}`, nil, false},
+		{`{"a":1,"b":2}`, `{"a":1,"b":3}`, []string{"b"}, true},
+		{`{"a":{"b":1,"c":2}}`, `{"a":{"b":1,"c":3}}`, []string{"a.c"}, true},
+		{`{"a":[{"b":1,"c":2}]}`, `{"a":[{"b":1,"c":3}]}`, []string{"a.c"}, true},
+		{`{"a":[{"b":1,"c":2}]}`, `{"a":[{"b":2,"c":3}]}`, []string{"a.c"}, false},
+	}
+	for _, tt := range tests {
+		c := &cassette{cfg: &cassetteConfig{ignoreBodyFields: tt.ignore}}
+		if got := c.matchBody([]byte(tt.recorded), []byte(tt.body)); got != tt.want {
+			t.Errorf("%s %s: got %v\nwant %v", tt.recorded, tt.body, got, tt.want)
+		}
+	}
+}
+
+type fakeGRPCConn struct {
+	count int
+}
+
+func (cc *fakeGRPCConn) Invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	cc.count++
+	in := args.(*wrapperspb.StringValue)
+	if in.GetValue() == "" {
+		return status.Error(codes.InvalidArgument, "empty")
+	}
+	reply.(*wrapperspb.StringValue).Value = "hello " + in.GetValue()
+	for _, opt := range opts {
+		if o, ok := opt.(grpc.HeaderCallOption); ok {
+			*o.HeaderAddr = metadata.Pairs("x-server", "fake")
+		}
+	}
+	return nil
+}
+
+func (cc *fakeGRPCConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestCassetteGRPCUnary(t *testing.T) {
+	const method = "/greet.GreetService/Hello"
+	fake := &fakeGRPCConn{}
+	ctx := withCassetteStep(metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "1"), 0)
+	rec := &cassette{cfg: &cassetteConfig{mode: cassetteModeRecord}}
+	cc := rec.grpcConn("greq", fake)
+	for _, v := range []string{"alice", ""} {
+		res := &wrapperspb.StringValue{}
+		_ = cc.Invoke(ctx, method, wrapperspb.String(v), res)
+	}
+	if got, want := len(rec.interactions), 2; got != want {
+		t.Fatalf("got %v\nwant %v", got, want)
+	}
+
+	rep := &cassette{cfg: &cassetteConfig{mode: cassetteModeReplay, strict: true}, interactions: rec.interactions}
+	cc = rep.grpcConn("greq", fake)
+	fake.count = 0
+	var h metadata.MD
+	res := &wrapperspb.StringValue{}
+	if err := cc.Invoke(ctx, method, wrapperspb.String("alice"), res, grpc.Header(&h)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := res.GetValue(), "hello alice"; got != want {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+	if got, want := h.Get("x-server"), []string{"fake"}; len(got) != 1 || got[0] != want[0] {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+	err := cc.Invoke(ctx, method, wrapperspb.String(""), &wrapperspb.StringValue{})
+	if got, want := status.Code(err), codes.InvalidArgument; got != want {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+	// Each interaction is replayed once
+	if err := cc.Invoke(ctx, method, wrapperspb.String("alice"), &wrapperspb.StringValue{}); err == nil {
+		t.Error("want error")
+	}
+	// Interactions are keyed by step
+	if err := cc.Invoke(withCassetteStep(ctx, 1), method, wrapperspb.String(""), &wrapperspb.StringValue{}); status.Code(err) == codes.InvalidArgument {
+		t.Error("want not found error")
+	}
+	if fake.count != 0 {
+		t.Errorf("got %v\nwant %v", fake.count, 0)
+	}
+}
+
+func TestCassetteGRPCStreamReplay(t *testing.T) {
+	const method = "/greet.GreetService/HelloStream"
+	g := &cassetteGRPC{
+		Method:           method,
+		Responses:        []json.RawMessage{[]byte(`"a"`), []byte(`"b"`)},
+		ResponseTrailers: metadata.Pairs("x-trailer", "t"),
+		Code:             codes.Aborted,
+		Message:          "aborted",
+	}
+	c := &cassette{
+		cfg:          &cassetteConfig{mode: cassetteModeReplay, strict: true},
+		interactions: []*cassetteInteraction{{Step: 0, Runner: "greq", GRPC: g}},
+	}
+	s, err := c.grpcConn("greq", &fakeGRPCConn{}).NewStream(context.Background(), &grpc.StreamDesc{ServerStreams: true}, method)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for {
+		res := &wrapperspb.StringValue{}
+		if err := s.RecvMsg(res); err != nil {
+			if errors.Is(err, io.EOF) {
+				t.Fatal("want status error")
+			}
+			if status.Code(err) != codes.Aborted {
+				t.Errorf("got %v\nwant %v", status.Code(err), codes.Aborted)
+			}
+			break
+		}
+		got = append(got, res.GetValue())
+	}
+	if len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Errorf("got %v", got)
+	}
+	if got := s.Trailer().Get("x-trailer"); len(got) != 1 {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestCassetteGRPCReplayWithoutServer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(s, hs)
+	reflection.Register(s)
+	go func() {
+		_ = s.Serve(l)
+	}()
+	target := l.Addr().String()
+	book := "testdata/book/cassette_grpc.yml"
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	// Methods are resolved using server reflection
+	o, err := New(Book(book), GrpcRunnerWithOptions("greq", target, TLS(false)), Record(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	s.Stop()
+	p := filepath.Join(dir, o.id+cassetteExt)
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cf cassetteFile
+	if err := json.Unmarshal(b, &cf); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cf.Descriptors["greq"]; !ok {
+		t.Fatal("descriptors should be recorded")
+	}
+
+	t.Run("replay", func(t *testing.T) {
+		for _, strict := range []bool{false, true} {
+			o, err := New(Book(book), GrpcRunnerWithOptions("greq", target, TLS(false)), Replay(dir), ReplayStrict(strict))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := o.Run(ctx); err != nil {
+				t.Errorf("strict: %v: %v", strict, err)
+			}
+		}
+	})
+
+	t.Run("replay without descriptors", func(t *testing.T) {
+		dir := t.TempDir()
+		cf := cf
+		cf.Descriptors = nil
+		b, err := json.Marshal(cf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, o.id+cassetteExt), b, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		o, err := New(Book(book), GrpcRunnerWithOptions("greq", target, TLS(false)), Replay(dir), ReplayStrict(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = o.Run(ctx)
+		if err == nil || !strings.Contains(err.Error(), "specify proto files or protosets") {
+			t.Errorf("got %v\nwant error asking for proto files or protosets", err)
+		}
+	})
+}
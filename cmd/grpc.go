@@ -0,0 +1,171 @@
+/*
+Copyright © 2022 Ken'ichiro Oyama <k1lowxb@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/k1LoW/runn"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// grpcCmd represents the grpc command.
+var grpcCmd = &cobra.Command{
+	Use:   "grpc",
+	Short: "explore gRPC services",
+	Long:  `explore gRPC services using server reflection or proto files.`,
+}
+
+// grpcListCmd represents the grpc list command.
+var grpcListCmd = &cobra.Command{
+	Use:     "list [TARGET]",
+	Short:   "list gRPC services and methods",
+	Long:    `list gRPC services and methods.`,
+	Aliases: []string{"ls"},
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		methods, err := grpcMethods(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"service", "method", "type"})
+		table.SetAutoWrapText(false)
+		table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+		table.SetAutoFormatHeaders(false)
+		table.SetCenterSeparator("")
+		table.SetColumnSeparator("")
+		table.SetRowSeparator("-")
+		table.SetHeaderLine(true)
+		table.SetBorder(false)
+		for _, m := range methods {
+			table.Append([]string{m.Service, m.Method, m.StreamType()})
+		}
+		table.Render()
+		return nil
+	},
+}
+
+// grpcDescribeCmd represents the grpc describe command.
+var grpcDescribeCmd = &cobra.Command{
+	Use:   "describe [TARGET] [SERVICE|METHOD]",
+	Short: "describe gRPC services and methods",
+	Long:  `describe gRPC services and methods, or print a runbook with step skeletons to call them.`,
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		target := args[0]
+		methods, err := grpcMethods(ctx, target)
+		if err != nil {
+			return err
+		}
+		if len(args) > 1 {
+			methods = selectGRPCMethods(methods, args[1])
+			if len(methods) == 0 {
+				return fmt.Errorf("service or method not found: %s", args[1])
+			}
+		}
+		if flgs.GRPCStep {
+			rb := runn.NewRunbook(flgs.Desc)
+			for _, m := range methods {
+				if err := rb.AppendGRPCStep(target, m); err != nil {
+					return err
+				}
+			}
+			o := os.Stdout
+			if flgs.Out != "" {
+				o, err = os.Create(filepath.Clean(flgs.Out))
+				if err != nil {
+					return err
+				}
+				defer func() {
+					_ = o.Close()
+				}()
+			}
+			enc := yaml.NewEncoder(o)
+			if err := enc.Encode(rb); err != nil {
+				return err
+			}
+			return enc.Close()
+		}
+		for i, m := range methods {
+			if i > 0 {
+				_, _ = fmt.Fprintln(os.Stdout)
+			}
+			_, _ = fmt.Fprintf(os.Stdout, "%s ( %s )\n%s", m.FullName(), m.StreamType(), m.Describe())
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(grpcCmd)
+	grpcCmd.AddCommand(grpcListCmd)
+	grpcCmd.AddCommand(grpcDescribeCmd)
+	for _, c := range []*cobra.Command{grpcListCmd, grpcDescribeCmd} {
+		c.Flags().BoolVarP(&flgs.GRPCNoTLS, "grpc-no-tls", "", false, flgs.Usage("GRPCNoTLS"))
+		c.Flags().StringSliceVarP(&flgs.GRPCProtos, "grpc-proto", "", []string{}, flgs.Usage("GRPCProtos"))
+		c.Flags().StringSliceVarP(&flgs.GRPCImportPaths, "grpc-import-path", "", []string{}, flgs.Usage("GRPCImportPaths"))
+		c.Flags().StringSliceVarP(&flgs.GRPCProtosets, "grpc-protoset", "", []string{}, flgs.Usage("GRPCProtosets"))
+		c.Flags().StringVarP(&flgs.GRPCCACert, "grpc-cacert", "", "", flgs.Usage("GRPCCACert"))
+		c.Flags().StringVarP(&flgs.GRPCCert, "grpc-cert", "", "", flgs.Usage("GRPCCert"))
+		c.Flags().StringVarP(&flgs.GRPCKey, "grpc-key", "", "", flgs.Usage("GRPCKey"))
+		c.Flags().BoolVarP(&flgs.GRPCSkipVerify, "grpc-skip-verify", "", false, flgs.Usage("GRPCSkipVerify"))
+	}
+	grpcDescribeCmd.Flags().BoolVarP(&flgs.GRPCStep, "step", "", false, flgs.Usage("GRPCStep"))
+	grpcDescribeCmd.Flags().StringVarP(&flgs.Desc, "desc", "", "", flgs.Usage("Desc"))
+	grpcDescribeCmd.Flags().StringVarP(&flgs.Out, "out", "", "", flgs.Usage("Out"))
+}
+
+// grpcMethods returns the methods of the target using the gRPC runner configured by the flags.
+func grpcMethods(ctx context.Context, target string) ([]*runn.GRPCMethod, error) {
+	opts := []runn.Option{
+		runn.GRPCNoTLS(flgs.GRPCNoTLS),
+		runn.GRPCProtos(flgs.GRPCProtos),
+		runn.GRPCImportPaths(flgs.GRPCImportPaths),
+		runn.GRPCProtosets(flgs.GRPCProtosets),
+	}
+	return runn.GRPCMethods(ctx, target, opts,
+		runn.CACert(flgs.GRPCCACert),
+		runn.Cert(flgs.GRPCCert),
+		runn.Key(flgs.GRPCKey),
+		runn.GRPCSkipVerify(flgs.GRPCSkipVerify),
+	)
+}
+
+// selectGRPCMethods selects methods by the name of the service ( e.g. "grpc.health.v1.Health" ) or the method ( e.g. "grpc.health.v1.Health/Check" or "grpc.health.v1.Health.Check" ).
+func selectGRPCMethods(methods []*runn.GRPCMethod, name string) []*runn.GRPCMethod {
+	var selected []*runn.GRPCMethod
+	for _, m := range methods {
+		if m.Service == name || m.FullName() == name || strings.Join([]string{m.Service, m.Method}, ".") == name {
+			selected = append(selected, m)
+		}
+	}
+	return selected
+}
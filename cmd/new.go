@@ -29,6 +29,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"github.com/k1LoW/runn"
 	"github.com/k1LoW/runn/capture"
@@ -49,12 +50,23 @@ var newCmd = &cobra.Command{
 			err error
 			al  [][]string
 		)
-		if len(args) == 0 {
+		if flgs.FromOpenApi3 != "" {
+			if len(args) > 0 || flgs.Out != "" || flgs.AndRun {
+				return errors.New("--from-openapi cannot be used with arguments, --out or --and-run")
+			}
+			return newFromOpenApi3(flgs.FromOpenApi3, flgs.GroupBy, flgs.OutDir)
+		}
+		if flgs.FromHAR != "" && len(args) > 0 {
+			return errors.New("--from-har cannot be used with arguments")
+		}
+		switch {
+		case flgs.FromHAR != "":
+		case len(args) == 0:
 			if isatty.IsTerminal(os.Stdin.Fd()) {
 				return errors.New("interactive mode is planned, but not yet implemented")
 			}
 			al = argsListFromStdin(os.Stdin)
-		} else {
+		default:
 			al = [][]string{args}
 		}
 		ctx := context.Background()
@@ -80,6 +92,21 @@ var newCmd = &cobra.Command{
 				return err
 			}
 		}
+		if flgs.FromHAR != "" {
+			in, err := harInput(flgs.FromHAR)
+			if err != nil {
+				return err
+			}
+			defer in.Close()
+			if err := rb.AppendStepsFromHAR(in,
+				runn.HARHosts(flgs.HARHosts...),
+				runn.HARMethods(flgs.HARMethods...),
+				runn.HARContentTypes(flgs.HARContentTypes...),
+				runn.HARStatusTest(flgs.HARTestStatus),
+			); err != nil {
+				return err
+			}
+		}
 		if flgs.Out == "" {
 			o = os.Stdout
 		} else {
@@ -122,9 +149,18 @@ func init() {
 	newCmd.Flags().StringVarP(&flgs.Desc, "desc", "", "", flgs.Usage("Desc"))
 	newCmd.Flags().StringVarP(&flgs.Out, "out", "", "", flgs.Usage("Out"))
 	newCmd.Flags().BoolVarP(&flgs.AndRun, "and-run", "", false, flgs.Usage("AndRun"))
+	newCmd.Flags().StringVarP(&flgs.FromOpenApi3, "from-openapi", "", "", flgs.Usage("FromOpenApi3"))
+	newCmd.Flags().StringVarP(&flgs.GroupBy, "group-by", "", runn.OpenApi3GroupByOperation, flgs.Usage("GroupBy"))
+	newCmd.Flags().StringVarP(&flgs.OutDir, "out-dir", "", "", flgs.Usage("OutDir"))
+	newCmd.Flags().StringVarP(&flgs.FromHAR, "from-har", "", "", flgs.Usage("FromHAR"))
+	newCmd.Flags().StringSliceVarP(&flgs.HARHosts, "har-host", "", []string{}, flgs.Usage("HARHosts"))
+	newCmd.Flags().StringSliceVarP(&flgs.HARMethods, "har-method", "", []string{}, flgs.Usage("HARMethods"))
+	newCmd.Flags().StringSliceVarP(&flgs.HARContentTypes, "har-content-type", "", []string{}, flgs.Usage("HARContentTypes"))
+	newCmd.Flags().BoolVarP(&flgs.HARTestStatus, "har-test-status", "", false, flgs.Usage("HARTestStatus"))
 	newCmd.Flags().BoolVarP(&flgs.GRPCNoTLS, "grpc-no-tls", "", false, flgs.Usage("GRPCNoTLS"))
 	newCmd.Flags().StringSliceVarP(&flgs.GRPCProtos, "grpc-proto", "", []string{}, flgs.Usage("GRPCProtos"))
 	newCmd.Flags().StringSliceVarP(&flgs.GRPCImportPaths, "grpc-import-path", "", []string{}, flgs.Usage("GRPCImportPaths"))
+	newCmd.Flags().StringSliceVarP(&flgs.GRPCProtosets, "grpc-protoset", "", []string{}, flgs.Usage("GRPCProtosets"))
 }
 
 func runAndCapture(ctx context.Context, o *os.File, fn func(*os.File) error) error {
@@ -155,6 +191,7 @@ func runAndCapture(ctx context.Context, o *os.File, fn func(*os.File) error) err
 		runn.GRPCNoTLS(flgs.GRPCNoTLS),
 		runn.GRPCProtos(flgs.GRPCProtos),
 		runn.GRPCImportPaths(flgs.GRPCImportPaths),
+		runn.GRPCProtosets(flgs.GRPCProtosets),
 	}
 	oo, err := runn.New(opts...)
 	if err != nil {
@@ -183,6 +220,53 @@ func runAndCapture(ctx context.Context, o *os.File, fn func(*os.File) error) err
 	return nil
 }
 
+// newFromOpenApi3 writes runbooks generated from the OpenAPI 3 document to the directory or stdout.
+func newFromOpenApi3(l, groupBy, dir string) error {
+	rbs, err := runn.NewRunbooksFromOpenApi3(l, groupBy)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for name := range rbs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if dir == "" {
+		enc := yaml.NewEncoder(os.Stdout)
+		for _, name := range names {
+			if err := enc.Encode(rbs[name]); err != nil {
+				return err
+			}
+		}
+		return enc.Close()
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, name := range names {
+		p := filepath.Join(dir, fmt.Sprintf("%s.yml", name))
+		if _, err := os.Stat(p); err == nil {
+			return fmt.Errorf("%s already exists", p)
+		}
+		b, err := yaml.Marshal(rbs[name])
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(p, b, 0o600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// harInput opens the HAR file or STDIN ( "-" ).
+func harInput(p string) (io.ReadCloser, error) {
+	if p == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(filepath.Clean(p))
+}
+
 func argsListFromStdin(in io.Reader) [][]string {
 	var al [][]string
 	scanner := bufio.NewScanner(in)
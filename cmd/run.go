@@ -77,6 +77,11 @@ var runCmd = &cobra.Command{
 			if err := r.Out(os.Stdout, !flgs.Verbose); err != nil {
 				return err
 			}
+			if r.Coverage != nil {
+				if err := r.Coverage.Out(os.Stdout); err != nil {
+					return err
+				}
+			}
 		}
 
 		if flgs.Profile {
@@ -98,6 +103,11 @@ var runCmd = &cobra.Command{
 		if r.HasFailure() {
 			os.Exit(1)
 		}
+		if r.Coverage != nil {
+			if err := r.Coverage.CheckThreshold(); err != nil {
+				return err
+			}
+		}
 		return nil
 	},
 }
@@ -111,6 +121,7 @@ func init() {
 	runCmd.Flags().BoolVarP(&flgs.GRPCNoTLS, "grpc-no-tls", "", false, flgs.Usage("GRPCNoTLS"))
 	runCmd.Flags().StringSliceVarP(&flgs.GRPCProtos, "grpc-proto", "", []string{}, flgs.Usage("GRPCProtos"))
 	runCmd.Flags().StringSliceVarP(&flgs.GRPCImportPaths, "grpc-import-path", "", []string{}, flgs.Usage("GRPCImportPaths"))
+	runCmd.Flags().StringSliceVarP(&flgs.GRPCProtosets, "grpc-protoset", "", []string{}, flgs.Usage("GRPCProtosets"))
 	runCmd.Flags().StringVarP(&flgs.CaptureDir, "capture", "", "", flgs.Usage("CaptureDir"))
 	runCmd.Flags().StringSliceVarP(&flgs.Vars, "var", "", []string{}, flgs.Usage("Vars"))
 	runCmd.Flags().StringSliceVarP(&flgs.Runners, "runner", "", []string{}, flgs.Usage("Runners"))
@@ -127,6 +138,13 @@ func init() {
 	runCmd.Flags().StringVarP(&flgs.Format, "format", "", "", flgs.Usage("Format"))
 	runCmd.Flags().BoolVarP(&flgs.Profile, "profile", "", false, flgs.Usage("Profile"))
 	runCmd.Flags().StringVarP(&flgs.ProfileOut, "profile-out", "", "runn.prof", flgs.Usage("ProfileOut"))
+	runCmd.Flags().BoolVarP(&flgs.Coverage, "coverage", "", false, flgs.Usage("Coverage"))
+	runCmd.Flags().Float64VarP(&flgs.CovThreshold, "coverage-threshold", "", 0, flgs.Usage("CovThreshold"))
+	runCmd.Flags().StringVarP(&flgs.Record, "record", "", "", flgs.Usage("Record"))
+	runCmd.Flags().StringVarP(&flgs.Replay, "replay", "", "", flgs.Usage("Replay"))
+	runCmd.Flags().StringSliceVarP(&flgs.IgnoreHeaders, "replay-ignore-header", "", []string{}, flgs.Usage("IgnoreHeaders"))
+	runCmd.Flags().StringSliceVarP(&flgs.IgnoreFields, "replay-ignore-body-field", "", []string{}, flgs.Usage("IgnoreFields"))
+	runCmd.Flags().BoolVarP(&flgs.ReplayStrict, "replay-strict", "", false, flgs.Usage("ReplayStrict"))
 	runCmd.Flags().StringVarP(&flgs.CacheDir, "cache-dir", "", "", flgs.Usage("CacheDir"))
 	runCmd.Flags().BoolVarP(&flgs.RetainCacheDir, "retain-cache-dir", "", false, flgs.Usage("RetainCacheDir"))
 	runCmd.Flags().BoolVarP(&flgs.Verbose, "verbose", "", false, flgs.Usage("Verbose"))
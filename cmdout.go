@@ -44,6 +44,8 @@ func (d *cmdOut) CaptureEnd(trs Trails, bookPath, desc string) {}
 
 func (d *cmdOut) CaptureHTTPRequest(name string, req *http.Request)                  {}
 func (d *cmdOut) CaptureHTTPResponse(name string, res *http.Response)                {}
+func (d *cmdOut) CaptureHTTPTrace(name string, trace *HTTPTrace)                     {}
+func (d *cmdOut) CaptureHTTPStreamMessage(name string, m any)                        {}
 func (d *cmdOut) CaptureGRPCStart(name string, typ GRPCType, service, method string) {}
 func (d *cmdOut) CaptureGRPCRequestHeaders(h map[string][]string)                    {}
 func (d *cmdOut) CaptureGRPCRequestMessage(m map[string]any)                         {}
@@ -60,6 +62,12 @@ func (d *cmdOut) CaptureCDPEnd(name string)
 func (d *cmdOut) CaptureSSHCommand(command string)                                   {}
 func (d *cmdOut) CaptureSSHStdout(stdout string)                                     {}
 func (d *cmdOut) CaptureSSHStderr(stderr string)                                     {}
+func (d *cmdOut) CaptureWSStart(name string)                                         {}
+func (d *cmdOut) CaptureWSConnect(name, endpoint string, h map[string][]string)      {}
+func (d *cmdOut) CaptureWSSend(name string, m map[string]any)                        {}
+func (d *cmdOut) CaptureWSReceive(name string, m map[string]any)                     {}
+func (d *cmdOut) CaptureWSClose(name string, code int, reason string)                {}
+func (d *cmdOut) CaptureWSEnd(name string)                                           {}
 func (d *cmdOut) CaptureDBStatement(name string, stmt string)                        {}
 func (d *cmdOut) CaptureDBResponse(name string, res *DBResponse)                     {}
 func (d *cmdOut) CaptureExecCommand(command string)                                  {}
@@ -0,0 +1,289 @@
+package runn
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// coverage collects operations of API specs ( OpenAPI documents and gRPC service descriptors ) and the operations invoked by runners.
+// Operations are keyed by the runner name and the location of the spec, because runners of the same name in different runbooks may use different specs.
+type coverage struct {
+	runners map[coverageKey]*coverageRunner
+	// threshold percentage of coverage of each runner. 0 means no threshold.
+	threshold float64
+	mu        sync.Mutex
+}
+
+type coverageKey struct {
+	name string
+	spec string
+}
+
+type coverageRunner struct {
+	runnerType RunnerType
+	operations map[string]*coverageOperation
+}
+
+type coverageOperation struct {
+	count    int
+	statuses map[string]int
+}
+
+type coverageResult struct {
+	Runners   []*runnerCoverage `json:"runners"`
+	Threshold float64           `json:"threshold,omitempty"`
+}
+
+type runnerCoverage struct {
+	Name       string               `json:"name"`
+	Type       RunnerType           `json:"type"`
+	Spec       string               `json:"spec,omitempty"`
+	Total      int                  `json:"total"`
+	Covered    int                  `json:"covered"`
+	Operations []*operationCoverage `json:"operations"`
+}
+
+type operationCoverage struct {
+	Operation string         `json:"operation"`
+	Count     int            `json:"count"`
+	Statuses  map[string]int `json:"statuses,omitempty"`
+}
+
+func newCoverage() *coverage {
+	return &coverage{
+		runners: map[coverageKey]*coverageRunner{},
+	}
+}
+
+// register registers operations of the API spec of the runner.
+func (c *coverage) register(name, spec string, runnerType RunnerType, operations []string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r := c.runner(coverageKey{name: name, spec: spec}, runnerType)
+	for _, op := range operations {
+		if _, ok := r.operations[op]; ok {
+			continue
+		}
+		r.operations[op] = &coverageOperation{statuses: map[string]int{}}
+	}
+}
+
+// hit records the operation invoked by the runner.
+// Operations that are not registered are ignored.
+func (c *coverage) hit(name, spec, operation, status string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.runners[coverageKey{name: name, spec: spec}]
+	if !ok {
+		return
+	}
+	op, ok := r.operations[operation]
+	if !ok {
+		return
+	}
+	op.count++
+	if status != "" {
+		op.statuses[status]++
+	}
+}
+
+func (c *coverage) runner(key coverageKey, runnerType RunnerType) *coverageRunner {
+	r, ok := c.runners[key]
+	if !ok {
+		r = &coverageRunner{
+			runnerType: runnerType,
+			operations: map[string]*coverageOperation{},
+		}
+		c.runners[key] = r
+	}
+	return r
+}
+
+func (c *coverage) result() *coverageResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res := &coverageResult{
+		Runners:   []*runnerCoverage{},
+		Threshold: c.threshold,
+	}
+	for key, r := range c.runners {
+		rc := &runnerCoverage{
+			Name:       key.name,
+			Type:       r.runnerType,
+			Spec:       key.spec,
+			Total:      len(r.operations),
+			Operations: []*operationCoverage{},
+		}
+		for k, op := range r.operations {
+			oc := &operationCoverage{
+				Operation: k,
+				Count:     op.count,
+			}
+			if len(op.statuses) > 0 {
+				oc.Statuses = map[string]int{}
+				for s, n := range op.statuses {
+					oc.Statuses[s] = n
+				}
+			}
+			if op.count > 0 {
+				rc.Covered++
+			}
+			rc.Operations = append(rc.Operations, oc)
+		}
+		sort.Slice(rc.Operations, func(i, j int) bool {
+			return rc.Operations[i].Operation < rc.Operations[j].Operation
+		})
+		res.Runners = append(res.Runners, rc)
+	}
+	sort.Slice(res.Runners, func(i, j int) bool {
+		if res.Runners[i].Name == res.Runners[j].Name {
+			return res.Runners[i].Spec < res.Runners[j].Spec
+		}
+		return res.Runners[i].Name < res.Runners[j].Name
+	})
+	return res
+}
+
+// Percentage returns the percentage of operations invoked at least once.
+func (rc *runnerCoverage) Percentage() float64 {
+	if rc.Total == 0 {
+		return 0
+	}
+	return float64(rc.Covered) * 100 / float64(rc.Total)
+}
+
+// CheckThreshold returns an error if the coverage of any runner is below the threshold.
+func (r *coverageResult) CheckThreshold() error {
+	if r.Threshold == 0 {
+		return nil
+	}
+	var below []string
+	for _, rc := range r.Runners {
+		if rc.Percentage() < r.Threshold {
+			below = append(below, fmt.Sprintf("%s %.1f%%", rc.runner(), rc.Percentage()))
+		}
+	}
+	if len(below) > 0 {
+		return fmt.Errorf("API coverage is below the threshold (%.1f%%): %s", r.Threshold, strings.Join(below, ", "))
+	}
+	return nil
+}
+
+// runner returns the runner name with the runner type and the spec.
+func (rc *runnerCoverage) runner() string {
+	if rc.Spec == "" {
+		return fmt.Sprintf("%s (%s)", rc.Name, rc.Type)
+	}
+	return fmt.Sprintf("%s (%s, %s)", rc.Name, rc.Type, rc.Spec)
+}
+
+// Out writes the coverage table of each runner.
+func (r *coverageResult) Out(out io.Writer) error {
+	for _, rc := range r.Runners {
+		if _, err := fmt.Fprintf(out, "\n%s: %d/%d operations covered (%.1f%%)\n", rc.runner(), rc.Covered, rc.Total, rc.Percentage()); err != nil {
+			return err
+		}
+		table := tablewriter.NewWriter(out)
+		table.SetHeader([]string{"operation:", "count:", "statuses:"})
+		table.SetAutoWrapText(false)
+		table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+		table.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_LEFT})
+		table.SetAutoFormatHeaders(false)
+		table.SetCenterSeparator("")
+		table.SetColumnSeparator("")
+		table.SetRowSeparator("-")
+		table.SetHeaderLine(true)
+		table.SetBorder(false)
+		for _, op := range rc.Operations {
+			var statuses []string
+			for s, n := range op.Statuses {
+				statuses = append(statuses, fmt.Sprintf("%s(%d)", s, n))
+			}
+			sort.Strings(statuses)
+			table.Append([]string{op.Operation, strconv.Itoa(op.Count), strings.Join(statuses, " ")})
+		}
+		table.Render()
+	}
+	return nil
+}
+
+func httpCoverageOperation(method, path string) string {
+	return fmt.Sprintf("%s %s", strings.ToUpper(method), path)
+}
+
+func grpcCoverageOperation(service, method string) string {
+	return strings.Join([]string{service, method}, "/")
+}
+
+// registerCoverage registers operations of the OpenAPI documents of HTTP runners.
+// Operations of gRPC runners are registered when the methods are resolved.
+func (o *operator) registerCoverage() {
+	if o.cov == nil {
+		return
+	}
+	for name, r := range o.httpRunners {
+		v, ok := r.validator.(*openApi3Validator)
+		if !ok {
+			continue
+		}
+		var ops []string
+		for p, item := range v.doc.Paths {
+			for m := range item.Operations() {
+				ops = append(ops, httpCoverageOperation(m, p))
+			}
+		}
+		o.cov.register(name, v.location, RunnerTypeHTTP, ops)
+	}
+}
+
+func (rnr *httpRunner) hitCoverage(req *http.Request, res *http.Response) {
+	if rnr.operator.cov == nil {
+		return
+	}
+	v, ok := rnr.validator.(*openApi3Validator)
+	if !ok {
+		return
+	}
+	op, err := v.operation(req)
+	if err != nil {
+		rnr.operator.Debugf("Skip recording coverage: %s\n", err.Error())
+		return
+	}
+	rnr.operator.cov.hit(rnr.name, v.location, op, strconv.Itoa(res.StatusCode))
+}
+
+func (rnr *grpcRunner) registerCoverage() {
+	if rnr.operator.cov == nil {
+		return
+	}
+	var ops []string
+	for _, md := range rnr.mds {
+		svc := string(md.Parent().FullName())
+		if strings.HasPrefix(svc, "grpc.reflection.") {
+			continue
+		}
+		ops = append(ops, grpcCoverageOperation(svc, string(md.Name())))
+	}
+	rnr.operator.cov.register(rnr.name, rnr.coverageSpec(), RunnerTypeGRPC, ops)
+}
+
+// coverageSpec returns the location of the spec of the gRPC runner, that is proto files and protosets, or the target using server reflection.
+func (rnr *grpcRunner) coverageSpec() string {
+	if len(rnr.protos) > 0 || len(rnr.protosets) > 0 {
+		return strings.Join(append(append([]string{}, rnr.protos...), rnr.protosets...), ",")
+	}
+	return rnr.target
+}
@@ -0,0 +1,234 @@
+package runn
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/k1LoW/runn/testutil"
+)
+
+func TestCoverage(t *testing.T) {
+	c := newCoverage()
+	c.register("req", "a.yml", RunnerTypeHTTP, []string{"GET /users", "POST /users", "GET /users/{id}"})
+	c.register("req", "a.yml", RunnerTypeHTTP, []string{"GET /users"})
+	c.register("req", "b.yml", RunnerTypeHTTP, []string{"GET /items"})
+	c.register("greq", "", RunnerTypeGRPC, []string{"pkg.Service/Hello"})
+	c.hit("req", "a.yml", "GET /users", "200")
+	c.hit("req", "a.yml", "GET /users", "200")
+	c.hit("req", "a.yml", "GET /users/{id}", "404")
+	c.hit("req", "a.yml", "DELETE /users", "200")
+	c.hit("req", "b.yml", "GET /items", "200")
+	c.hit("req", "b.yml", "GET /users", "200")
+	c.hit("req", "c.yml", "GET /users", "200")
+	c.hit("unknown", "a.yml", "GET /users", "200")
+
+	got := c.result()
+	want := &coverageResult{
+		Runners: []*runnerCoverage{
+			{
+				Name:    "greq",
+				Type:    RunnerTypeGRPC,
+				Total:   1,
+				Covered: 0,
+				Operations: []*operationCoverage{
+					{Operation: "pkg.Service/Hello"},
+				},
+			},
+			{
+				Name:    "req",
+				Type:    RunnerTypeHTTP,
+				Spec:    "a.yml",
+				Total:   3,
+				Covered: 2,
+				Operations: []*operationCoverage{
+					{Operation: "GET /users", Count: 2, Statuses: map[string]int{"200": 2}},
+					{Operation: "GET /users/{id}", Count: 1, Statuses: map[string]int{"404": 1}},
+					{Operation: "POST /users"},
+				},
+			},
+			{
+				Name:    "req",
+				Type:    RunnerTypeHTTP,
+				Spec:    "b.yml",
+				Total:   1,
+				Covered: 1,
+				Operations: []*operationCoverage{
+					{Operation: "GET /items", Count: 1, Statuses: map[string]int{"200": 1}},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Error(diff)
+	}
+	if got := got.Runners[1].Percentage(); got < 66.6 || got > 66.7 {
+		t.Errorf("got %v\nwant %v", got, 66.6)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := got.Out(buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"greq (grpc): 0/1 operations covered (0.0%)", "req (http, a.yml): 2/3 operations covered (66.7%)", "req (http, b.yml): 1/1 operations covered (100.0%)", "GET /users/{id}"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("got %s\nwant %s", buf.String(), want)
+		}
+	}
+}
+
+func TestCoverageHTTP(t *testing.T) {
+	ctx := context.Background()
+	ts := testutil.HTTPServer(t)
+	ops, err := Load("testdata/book/coverage.yml", Runner("req", ts.URL, OpenApi3("testdata/openapi3.yml"), SkipValidateResponse(true)), Coverage(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.RunN(ctx); err != nil {
+		t.Fatal(err)
+	}
+	r := ops.Result()
+	if r.HasFailure() {
+		t.Fatal("result has failure")
+	}
+	if r.Coverage == nil || len(r.Coverage.Runners) != 1 {
+		t.Fatalf("invalid coverage: %#v", r.Coverage)
+	}
+	rc := r.Coverage.Runners[0]
+	if rc.Name != "req" || rc.Type != RunnerTypeHTTP {
+		t.Errorf("got %s (%s)\nwant %s (%s)", rc.Name, rc.Type, "req", RunnerTypeHTTP)
+	}
+	if want := filepath.Join("testdata", "openapi3.yml"); !strings.HasSuffix(rc.Spec, want) {
+		t.Errorf("got %v\nwant suffix %v", rc.Spec, want)
+	}
+	if want := 9; rc.Total != want {
+		t.Errorf("got %v\nwant %v", rc.Total, want)
+	}
+	if want := 2; rc.Covered != want {
+		t.Errorf("got %v\nwant %v", rc.Covered, want)
+	}
+	got := map[string]*operationCoverage{}
+	for _, op := range rc.Operations {
+		got[op.Operation] = op
+	}
+	if diff := cmp.Diff(got["GET /users"], &operationCoverage{Operation: "GET /users", Count: 1, Statuses: map[string]int{"200": 1}}); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff(got["GET /users/{id}"], &operationCoverage{Operation: "GET /users/{id}", Count: 2, Statuses: map[string]int{"200": 1, "404": 1}}); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestCoverageGRPC(t *testing.T) {
+	ctx := context.Background()
+	ts := testutil.GRPCServer(t, false, false)
+	ops, err := Load("testdata/book/grpc.yml", GrpcRunner("greq", ts.Conn()), Coverage(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.RunN(ctx); err != nil {
+		t.Fatal(err)
+	}
+	r := ops.Result()
+	if r.Coverage == nil || len(r.Coverage.Runners) != 1 {
+		t.Fatalf("invalid coverage: %#v", r.Coverage)
+	}
+	rc := r.Coverage.Runners[0]
+	if rc.Name != "greq" || rc.Type != RunnerTypeGRPC {
+		t.Errorf("got %s (%s)\nwant %s (%s)", rc.Name, rc.Type, "greq", RunnerTypeGRPC)
+	}
+	got := map[string]int{}
+	for _, op := range rc.Operations {
+		if strings.HasPrefix(op.Operation, "grpc.reflection.") {
+			t.Errorf("reflection service should not be included: %s", op.Operation)
+		}
+		got[op.Operation] = op.Count
+	}
+	want := map[string]int{
+		"grpctest.GrpcTestService/Hello":      2,
+		"grpctest.GrpcTestService/ListHello":  1,
+		"grpctest.GrpcTestService/MultiHello": 1,
+		"grpctest.GrpcTestService/HelloChat":  1,
+	}
+	for op, count := range want {
+		if got[op] != count {
+			t.Errorf("%s: got %v\nwant %v", op, got[op], count)
+		}
+	}
+	if rc.Covered != len(want) {
+		t.Errorf("got %v\nwant %v", rc.Covered, len(want))
+	}
+}
+
+func TestCoverageDisabled(t *testing.T) {
+	ctx := context.Background()
+	ts := testutil.HTTPServer(t)
+	ops, err := Load("testdata/book/coverage.yml", Runner("req", ts.URL, OpenApi3("testdata/openapi3.yml"), SkipValidateResponse(true)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.RunN(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := ops.Result().Coverage; got != nil {
+		t.Errorf("got %#v\nwant nil", got)
+	}
+}
+
+func TestCoverageCheckThreshold(t *testing.T) {
+	tests := []struct {
+		threshold float64
+		wantErr   string
+	}{
+		{0, ""},
+		{20, ""},
+		{22.2, ""},
+		{50, "API coverage is below the threshold (50.0%): req (http, "},
+		{100, "openapi3.yml) 22.2%"},
+	}
+	ctx := context.Background()
+	ts := testutil.HTTPServer(t)
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v", tt.threshold), func(t *testing.T) {
+			opts := []Option{Runner("req", ts.URL, OpenApi3("testdata/openapi3.yml"), SkipValidateResponse(true))}
+			if tt.threshold != 0 {
+				opts = append(opts, CoverageThreshold(tt.threshold))
+			} else {
+				opts = append(opts, Coverage(true))
+			}
+			ops, err := Load("testdata/book/coverage.yml", opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := ops.RunN(ctx); err != nil {
+				t.Fatal(err)
+			}
+			r := ops.Result()
+			if r.Coverage == nil {
+				t.Fatal("coverage should be enabled")
+			}
+			err = r.Coverage.CheckThreshold()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("got %v\nwant no error", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got %v\nwant %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCoverageThresholdInvalid(t *testing.T) {
+	for _, threshold := range []float64{-1, 100.1} {
+		if _, err := New(CoverageThreshold(threshold)); err == nil {
+			t.Errorf("want error: %v", threshold)
+		}
+	}
+}
@@ -42,6 +42,14 @@ func (d *debugger) CaptureHTTPResponse(name string, res *http.Response) {
 	_, _ = fmt.Fprintf(d.out, "-----START HTTP RESPONSE-----\n%s\n-----END HTTP RESPONSE-----\n", string(b))
 }
 
+func (d *debugger) CaptureHTTPTrace(name string, trace *HTTPTrace) {
+	_, _ = fmt.Fprintf(d.out, "-----START HTTP TRACE-----\n%s\n-----END HTTP TRACE-----\n", dumpHTTPTrace(trace))
+}
+
+func (d *debugger) CaptureHTTPStreamMessage(name string, m any) {
+	_, _ = fmt.Fprintf(d.out, "-----START HTTP STREAM MESSAGE-----\n%s\n-----END HTTP STREAM MESSAGE-----\n", dumpHTTPStreamMessage(m))
+}
+
 func (d *debugger) CaptureGRPCStart(name string, typ GRPCType, service, method string) {
 	_, _ = fmt.Fprintf(d.out, ">>>>>START gRPC (%s/%s)>>>>>\n", service, method)
 }
@@ -60,6 +68,13 @@ func (d *debugger) CaptureGRPCResponseStatus(s *status.Status) {
 	if c != codes.OK {
 		m = fmt.Sprintf("%s (%d): %s", c.String(), int(c), s.Message())
 	}
+	if details := grpcStatusDetails(s); len(details) > 0 {
+		m += "\ndetails:"
+		for _, detail := range details {
+			b, _ := json.Marshal(detail)
+			m += fmt.Sprintf("\n- %s", string(b))
+		}
+	}
 	_, _ = fmt.Fprintf(d.out, "-----START gRPC RESPONSE STATUS-----\n%s\n-----END gRPC RESPONSE STATUS-----\n", m)
 }
 
@@ -106,6 +121,30 @@ func (d *debugger) CaptureSSHStderr(stderr string) {
 	_, _ = fmt.Fprintf(d.out, "-----START STDERR-----\n%s\n-----END STDERR-----\n", stderr)
 }
 
+func (d *debugger) CaptureWSStart(name string) {
+	_, _ = fmt.Fprint(d.out, ">>>>>START WebSocket>>>>>\n")
+}
+
+func (d *debugger) CaptureWSConnect(name, endpoint string, h map[string][]string) {
+	_, _ = fmt.Fprintf(d.out, "-----START WebSocket CONNECT-----\n%s\n%s\n-----END WebSocket CONNECT-----\n", endpoint, dumpGRPCMetadata(h))
+}
+
+func (d *debugger) CaptureWSSend(name string, m map[string]any) {
+	_, _ = fmt.Fprintf(d.out, "-----START WebSocket SEND MESSAGE-----\n%s\n-----END WebSocket SEND MESSAGE-----\n", dumpMapInterface(m))
+}
+
+func (d *debugger) CaptureWSReceive(name string, m map[string]any) {
+	_, _ = fmt.Fprintf(d.out, "-----START WebSocket RECEIVE MESSAGE-----\n%s\n-----END WebSocket RECEIVE MESSAGE-----\n", dumpMapInterface(m))
+}
+
+func (d *debugger) CaptureWSClose(name string, code int, reason string) {
+	_, _ = fmt.Fprintf(d.out, "-----START WebSocket CLOSE-----\ncode: %d\nreason: %s\n-----END WebSocket CLOSE-----\n", code, reason)
+}
+
+func (d *debugger) CaptureWSEnd(name string) {
+	_, _ = fmt.Fprint(d.out, "<<<<<END WebSocket<<<<<\n")
+}
+
 func (d *debugger) CaptureDBStatement(name string, stmt string) {
 	_, _ = fmt.Fprintf(d.out, "-----START QUERY-----\n%s\n-----END QUERY-----\n", stmt)
 }
@@ -190,6 +229,29 @@ var (
 	dumpGRPCMessage = dumpMapInterface
 )
 
+func dumpHTTPStreamMessage(m any) string {
+	switch v := m.(type) {
+	case map[string]any:
+		return dumpMapInterface(v)
+	case string:
+		return v
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+func dumpHTTPTrace(t *HTTPTrace) string {
+	d := []string{
+		fmt.Sprintf("%s: %s", httpTraceDNSKey, t.DNS),
+		fmt.Sprintf("%s: %s", httpTraceConnectKey, t.Connect),
+		fmt.Sprintf("%s: %s", httpTraceTLSHandshakeKey, t.TLSHandshake),
+		fmt.Sprintf("%s: %s", httpTraceFirstByteKey, t.FirstByte),
+		fmt.Sprintf("%s: %s", httpTraceTotalKey, t.Total),
+	}
+	return strings.Join(d, "\n")
+}
+
 func dumpGRPCMetadata(m map[string][]string) string {
 	var keys []string
 	for k := range m {
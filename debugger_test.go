@@ -16,6 +16,7 @@ import (
 
 var testDebuggerHostRe = regexp.MustCompile(`(?s)Host:[^\r\n]+\r\n`)
 var testDebuggerDateRe = regexp.MustCompile(`(?s)Date:[^\r\n]+\r\n`)
+var testDebuggerTraceRe = regexp.MustCompile(`(?m)^(dns|connect|tls|ttfb|total): .+$`)
 
 func TestDebugger(t *testing.T) {
 	tests := []struct {
@@ -58,6 +59,7 @@ func TestDebugger(t *testing.T) {
 			if strings.Contains(tt.book, "http.yml") {
 				got = testDebuggerHostRe.ReplaceAllString(got, "Host: replace.example.com\r\n")
 				got = testDebuggerDateRe.ReplaceAllString(got, "Date: Wed, 07 Sep 2022 06:28:20 GMT\r\n")
+				got = testDebuggerTraceRe.ReplaceAllString(got, "$1: 0s")
 			}
 
 			f := fmt.Sprintf("%s.debugger", filepath.Base(tt.book))
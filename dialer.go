@@ -0,0 +1,151 @@
+package runn
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// dialConfig is the config of connections shared by HTTP runner and gRPC runner.
+type dialConfig struct {
+	Resolve       []string
+	ServerName    string
+	TLSMinVersion string
+	TLSMaxVersion string
+	ALPN          []string
+}
+
+// dialer connects to the address overridden by `resolve:` and applies the low-level TLS settings.
+type dialer struct {
+	// host:port -> addr:port
+	resolve    map[string]string
+	serverName string
+	minVersion uint16
+	maxVersion uint16
+	alpn       []string
+	d          net.Dialer
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+func (c *httpRunnerConfig) newDialer() (*dialer, error) {
+	return dialConfig{
+		Resolve:       c.Resolve,
+		ServerName:    c.ServerName,
+		TLSMinVersion: c.TLSMinVersion,
+		TLSMaxVersion: c.TLSMaxVersion,
+		ALPN:          c.ALPN,
+	}.newDialer()
+}
+
+func (c *grpcRunnerConfig) newDialer() (*dialer, error) {
+	return dialConfig{
+		Resolve:       c.Resolve,
+		ServerName:    c.ServerName,
+		TLSMinVersion: c.TLSMinVersion,
+		TLSMaxVersion: c.TLSMaxVersion,
+		ALPN:          c.ALPN,
+	}.newDialer()
+}
+
+// newDialer returns *dialer. It returns nil if no settings are specified.
+func (c dialConfig) newDialer() (*dialer, error) {
+	if len(c.Resolve) == 0 && c.ServerName == "" && c.TLSMinVersion == "" && c.TLSMaxVersion == "" && len(c.ALPN) == 0 {
+		return nil, nil
+	}
+	d := &dialer{
+		resolve:    map[string]string{},
+		serverName: c.ServerName,
+		alpn:       c.ALPN,
+	}
+	for _, r := range c.Resolve {
+		from, to, err := parseResolve(r)
+		if err != nil {
+			return nil, err
+		}
+		d.resolve[from] = to
+	}
+	var err error
+	if c.TLSMinVersion != "" {
+		d.minVersion, err = parseTLSVersion(c.TLSMinVersion)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if c.TLSMaxVersion != "" {
+		d.maxVersion, err = parseTLSVersion(c.TLSMaxVersion)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if d.minVersion != 0 && d.maxVersion != 0 && d.minVersion > d.maxVersion {
+		return nil, fmt.Errorf("tlsMinVersion (%s) is greater than tlsMaxVersion (%s)", c.TLSMinVersion, c.TLSMaxVersion)
+	}
+	return d, nil
+}
+
+// parseResolve parses the entry of `resolve:` in the same format as curl --resolve ( host:port:addr ).
+func parseResolve(s string) (string, string, error) {
+	host, rest, ok := strings.Cut(s, ":")
+	if strings.HasPrefix(s, "[") {
+		// IPv6 host
+		var h string
+		h, rest, ok = strings.Cut(strings.TrimPrefix(s, "["), "]:")
+		host = h
+	}
+	if !ok {
+		return "", "", fmt.Errorf("invalid resolve (should be host:port:addr): %s", s)
+	}
+	port, addr, ok := strings.Cut(rest, ":")
+	if !ok || host == "" || port == "" || addr == "" {
+		return "", "", fmt.Errorf("invalid resolve (should be host:port:addr): %s", s)
+	}
+	addr = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	if net.ParseIP(addr) == nil {
+		return "", "", fmt.Errorf("invalid resolve (addr should be IP address): %s", s)
+	}
+	return net.JoinHostPort(strings.ToLower(host), port), net.JoinHostPort(addr, port), nil
+}
+
+func parseTLSVersion(v string) (uint16, error) {
+	tv, ok := tlsVersions[strings.TrimPrefix(strings.ToLower(v), "tls")]
+	if !ok {
+		return 0, fmt.Errorf("invalid TLS version (should be 1.0, 1.1, 1.2 or 1.3): %s", v)
+	}
+	return tv, nil
+}
+
+// DialContext connects to the address. If the address is overridden by `resolve:`, it connects to the overridden address.
+func (d *dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err == nil {
+		if to, ok := d.resolve[net.JoinHostPort(strings.ToLower(host), port)]; ok {
+			addr = to
+		}
+	}
+	return d.d.DialContext(ctx, network, addr)
+}
+
+// applyTLS applies the low-level TLS settings to c.
+// SNI is the host of the original address unless the server name is specified.
+func (d *dialer) applyTLS(c *tls.Config) {
+	if d.serverName != "" {
+		c.ServerName = d.serverName
+	}
+	if d.minVersion != 0 {
+		c.MinVersion = d.minVersion
+	}
+	if d.maxVersion != 0 {
+		c.MaxVersion = d.maxVersion
+	}
+	if len(d.alpn) > 0 {
+		c.NextProtos = d.alpn
+	}
+}
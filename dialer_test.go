@@ -0,0 +1,331 @@
+package runn
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+)
+
+func TestParseResolve(t *testing.T) {
+	tests := []struct {
+		in       string
+		wantFrom string
+		wantTo   string
+		wantErr  bool
+	}{
+		{"example.com:443:127.0.0.1", "example.com:443", "127.0.0.1:443", false},
+		{"Example.COM:8080:10.0.0.1", "example.com:8080", "10.0.0.1:8080", false},
+		{"example.com:443:::1", "example.com:443", "[::1]:443", false},
+		{"example.com:443:[::1]", "example.com:443", "[::1]:443", false},
+		{"[::1]:443:127.0.0.1", "[::1]:443", "127.0.0.1:443", false},
+		{"example.com:443", "", "", true},
+		{"example.com:443:localhost", "", "", true},
+		{":443:127.0.0.1", "", "", true},
+		{"example.com::127.0.0.1", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			from, to, err := parseResolve(tt.in)
+			if err != nil {
+				if !tt.wantErr {
+					t.Error(err)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Error("want error")
+			}
+			if from != tt.wantFrom {
+				t.Errorf("got %v\nwant %v", from, tt.wantFrom)
+			}
+			if to != tt.wantTo {
+				t.Errorf("got %v\nwant %v", to, tt.wantTo)
+			}
+		})
+	}
+}
+
+func TestParseTLSVersion(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    uint16
+		wantErr bool
+	}{
+		{"1.0", tls.VersionTLS10, false},
+		{"1.2", tls.VersionTLS12, false},
+		{"1.3", tls.VersionTLS13, false},
+		{"TLS1.3", tls.VersionTLS13, false},
+		{"tls1.1", tls.VersionTLS11, false},
+		{"1.4", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseTLSVersion(tt.in)
+			if err != nil {
+				if !tt.wantErr {
+					t.Error(err)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Error("want error")
+			}
+			if got != tt.want {
+				t.Errorf("got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewDialer(t *testing.T) {
+	tests := []struct {
+		name    string
+		c       dialConfig
+		want    *dialer
+		wantErr bool
+	}{
+		{"empty", dialConfig{}, nil, false},
+		{
+			"all",
+			dialConfig{
+				Resolve:       []string{"example.com:443:127.0.0.1"},
+				ServerName:    "api.example.com",
+				TLSMinVersion: "1.2",
+				TLSMaxVersion: "1.3",
+				ALPN:          []string{"h2", "http/1.1"},
+			},
+			&dialer{
+				resolve:    map[string]string{"example.com:443": "127.0.0.1:443"},
+				serverName: "api.example.com",
+				minVersion: tls.VersionTLS12,
+				maxVersion: tls.VersionTLS13,
+				alpn:       []string{"h2", "http/1.1"},
+			},
+			false,
+		},
+		{"invalid resolve", dialConfig{Resolve: []string{"example.com"}}, nil, true},
+		{"invalid version", dialConfig{TLSMinVersion: "2.0"}, nil, true},
+		{"min greater than max", dialConfig{TLSMinVersion: "1.3", TLSMaxVersion: "1.2"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.c.newDialer()
+			if err != nil {
+				if !tt.wantErr {
+					t.Error(err)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Error("want error")
+			}
+			if diff := cmp.Diff(got, tt.want, cmp.AllowUnexported(dialer{}), cmp.Comparer(func(x, y net.Dialer) bool { return true })); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestParseRunnerWithDialConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       map[string]any
+		wantErr bool
+	}{
+		{
+			"http",
+			map[string]any{
+				"endpoint":      "https://example.com",
+				"resolve":       []any{"example.com:443:127.0.0.1"},
+				"serverName":    "api.example.com",
+				"tlsMinVersion": "1.2",
+				"alpn":          []any{"http/1.1"},
+			},
+			false,
+		},
+		{
+			"grpc",
+			map[string]any{
+				"addr":          "example.com:443",
+				"resolve":       []any{"example.com:443:127.0.0.1"},
+				"serverName":    "api.example.com",
+				"tlsMinVersion": "1.2",
+				"alpn":          []any{"http/1.1"},
+			},
+			false,
+		},
+		{
+			"invalid",
+			map[string]any{
+				"endpoint":      "https://example.com",
+				"tlsMinVersion": "1.3",
+				"tlsMaxVersion": "1.2",
+			},
+			true,
+		},
+	}
+	want := &dialer{
+		resolve:    map[string]string{"example.com:443": "127.0.0.1:443"},
+		serverName: "api.example.com",
+		minVersion: tls.VersionTLS12,
+		alpn:       []string{"http/1.1"},
+	}
+	opts := []cmp.Option{
+		cmp.AllowUnexported(dialer{}),
+		cmp.Comparer(func(x, y net.Dialer) bool { return true }),
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bk := newBook()
+			if err := bk.parseRunner("req", tt.v); err != nil {
+				if !tt.wantErr {
+					t.Error(err)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Error("want error")
+				return
+			}
+			var got *dialer
+			if r, ok := bk.httpRunners["req"]; ok {
+				got = r.dialer
+			}
+			if r, ok := bk.grpcRunners["req"]; ok {
+				got = r.dialer
+			}
+			if diff := cmp.Diff(got, want, opts...); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestHTTPRunnerDialer(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "%s %s %x", r.Host, r.TLS.ServerName, r.TLS.Version)
+	}))
+	t.Cleanup(ts.Close)
+	_, port, err := net.SplitHostPort(ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := net.JoinHostPort("example.com", port)
+	resolve := fmt.Sprintf("example.com:%s:127.0.0.1", port)
+
+	tests := []struct {
+		name string
+		opts []httpRunnerOption
+		want string
+	}{
+		{
+			"resolve",
+			[]httpRunnerOption{HTTPResolve(resolve)},
+			fmt.Sprintf("%s example.com 304", host),
+		},
+		{
+			"server name",
+			[]httpRunnerOption{HTTPResolve(resolve), HTTPServerName("api.example.com")},
+			fmt.Sprintf("%s api.example.com 304", host),
+		},
+		{
+			"max version",
+			[]httpRunnerOption{HTTPResolve(resolve), HTTPTLSMaxVersion("1.2")},
+			fmt.Sprintf("%s example.com 303", host),
+		},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]httpRunnerOption{HTTPSkipVerify(true)}, tt.opts...)
+			o, err := New(HTTPRunner("req", "https://"+host, &http.Client{}, opts...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, ok := o.httpRunners["req"]
+			if !ok {
+				t.Fatal("http runner not found")
+			}
+			r.operator = o
+			req := &httpRequest{
+				path:    "/",
+				method:  http.MethodGet,
+				headers: http.Header{},
+			}
+			if err := r.Run(ctx, req); err != nil {
+				t.Fatal(err)
+			}
+			res, ok := o.store.latest()["res"].(map[string]any)
+			if !ok {
+				t.Fatalf("invalid res: %#v", o.store.latest()["res"])
+			}
+			if got := res["rawBody"]; got != tt.want {
+				t.Errorf("got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGrpcRunnerResolve(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	reflection.Register(s)
+	go func() {
+		_ = s.Serve(l)
+	}()
+	t.Cleanup(s.Stop)
+	_, port, err := net.SplitHostPort(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := New(GrpcRunnerWithOptions("greq", "example.com:"+port, TLS(false), GRPCResolve(fmt.Sprintf("example.com:%s:127.0.0.1", port))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, ok := o.grpcRunners["greq"]
+	if !ok {
+		t.Fatal("grpc runner not found")
+	}
+	r.operator = o
+	t.Cleanup(func() {
+		_ = r.Close()
+	})
+	req := &grpcRequest{
+		service: "grpc.health.v1.Health",
+		method:  "Check",
+		headers: metadata.MD{},
+		messages: []*grpcMessage{
+			{
+				op:     GRPCOpMessage,
+				params: map[string]any{},
+			},
+		},
+	}
+	if err := r.Run(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	res, ok := o.store.latest()[grpcStoreResponseKey].(map[string]any)
+	if !ok {
+		t.Fatalf("invalid res: %#v", o.store.latest()[grpcStoreResponseKey])
+	}
+	if got := res[grpcStoreStatusKey]; got != 0 {
+		t.Errorf("got %v\nwant %v", got, 0)
+	}
+}
@@ -31,6 +31,12 @@ type Flags struct {
 	GRPCNoTLS       bool     `usage:"disable TLS use in all gRPC runners"`
 	GRPCProtos      []string `usage:"set the name of proto source for all gRPC runners"`
 	GRPCImportPaths []string `usage:"set the path to the directory where proto sources can be imported for all gRPC runners"`
+	GRPCProtosets   []string `usage:"set the path of protoset (FileDescriptorSet file or buf image) for all gRPC runners"`
+	GRPCStep        bool     `usage:"print a runbook with step skeletons to call the methods"`
+	GRPCCACert      string   `usage:"set the path of CA certificate to connect to the gRPC server"`
+	GRPCCert        string   `usage:"set the path of client certificate to connect to the gRPC server"`
+	GRPCKey         string   `usage:"set the path of client key to connect to the gRPC server"`
+	GRPCSkipVerify  bool     `usage:"skip verification of the gRPC server certificate"`
 	CaptureDir      string   `usage:"destination of runbook run capture results"`
 	Vars            []string `usage:"set var to runbook (\"key:value\")"`
 	Runners         []string `usage:"set runner to runbook (\"key:dsn\")"`
@@ -44,6 +50,14 @@ type Flags struct {
 	Random          int      `usage:"run the specified number of runbooks at random"`
 	Desc            string   `usage:"description of runbook"`
 	Out             string   `usage:"target path of runbook"`
+	OutDir          string   `usage:"target directory of runbooks generated from OpenAPI document"`
+	FromOpenApi3    string   `usage:"path or URL of OpenAPI 3 document to generate runbooks from"`
+	GroupBy         string   `usage:"unit of runbooks generated from OpenAPI document (\"operation\",\"tag\")"`
+	FromHAR         string   `usage:"path of HAR file to generate steps from (\"-\" reads from STDIN)"`
+	HARHosts        []string `usage:"hosts of HAR entries to generate steps from (glob patterns are allowed)"`
+	HARMethods      []string `usage:"methods of HAR entries to generate steps from"`
+	HARContentTypes []string `usage:"response content types of HAR entries to generate steps from (glob patterns are allowed)"`
+	HARTestStatus   bool     `usage:"add test conditions on the recorded status codes of HAR entries"`
 	Format          string   `usage:"format of result output"`
 	AndRun          bool     `usage:"run created runbook and capture the response for test"`
 	LoadTConcurrent int      `usage:"number of concurrent load test runs"`
@@ -56,6 +70,13 @@ type Flags struct {
 	ProfileDepth    int      `usage:"depth of profile"`
 	ProfileUnit     string   `usage:"-"`
 	ProfileSort     string   `usage:"-"`
+	Coverage        bool     `usage:"show API coverage of runners against OpenAPI documents and gRPC service descriptors"`
+	CovThreshold    float64  `usage:"if the API coverage of any runner is below this percentage, run command returns exit status 1 (EXIT_FAILURE). It also enables --coverage"`
+	Record          string   `usage:"record HTTP and gRPC traffic of runners to cassette files in the directory"`
+	Replay          string   `usage:"replay HTTP and gRPC traffic of runners from cassette files in the directory"`
+	IgnoreHeaders   []string `usage:"request headers to ignore when matching requests with recorded ones"`
+	IgnoreFields    []string `usage:"fields of JSON request bodies to ignore when matching requests with recorded ones (e.g. \"user.createdAt\")"`
+	ReplayStrict    bool     `usage:"fail on requests that do not match any recorded ones"`
 	CacheDir        string   `usage:"specify cache directory for remote runbooks"`
 	RetainCacheDir  bool     `usage:"retain cache directory for remote runbooks"`
 	Verbose         bool     `usage:"verbose"`
@@ -75,7 +96,26 @@ func (f *Flags) ToOpts() ([]runn.Option, error) {
 		runn.GRPCNoTLS(f.GRPCNoTLS),
 		runn.GRPCProtos(f.GRPCProtos),
 		runn.GRPCImportPaths(f.GRPCImportPaths),
+		runn.GRPCProtosets(f.GRPCProtosets),
 		runn.Profile(f.Profile),
+		runn.Coverage(f.Coverage),
+	}
+	if f.CovThreshold != 0 {
+		opts = append(opts, runn.CoverageThreshold(f.CovThreshold))
+	}
+	if f.Record != "" && f.Replay != "" {
+		return nil, errors.New("--record and --replay cannot be used at the same time")
+	}
+	if f.Record != "" {
+		opts = append(opts, runn.Record(f.Record))
+	}
+	if f.Replay != "" {
+		opts = append(opts,
+			runn.Replay(f.Replay),
+			runn.ReplayIgnoreHeaders(f.IgnoreHeaders...),
+			runn.ReplayIgnoreBodyFields(f.IgnoreFields...),
+			runn.ReplayStrict(f.ReplayStrict),
+		)
 	}
 	if f.RunID != "" {
 		opts = append(opts, runn.RunID(f.RunID))
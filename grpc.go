@@ -7,13 +7,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/bufbuild/protocompile"
-	"github.com/bufbuild/protocompile/linker"
 	"github.com/goccy/go-json"
 	"github.com/jhump/protoreflect/v2/grpcreflect"
 	"github.com/k1LoW/runn/version"
@@ -54,6 +54,7 @@ const (
 	grpcStoreTrailerKey  = "trailers"
 	grpcStoreMessageKey  = "message"
 	grpcStoreMessagesKey = "messages"
+	grpcStoreDetailsKey  = "details"
 	grpcStoreResponseKey = "res"
 )
 
@@ -67,26 +68,37 @@ type grpcRunner struct {
 	skipVerify  bool
 	importPaths []string
 	protos      []string
+	protosets   []string
+	protocol    string
 	cc          *grpc.ClientConn
+	hc          *grpcHTTPConn
 	refc        *grpcreflect.Client
 	mds         map[string]protoreflect.MethodDescriptor
+	jsonSchema  *jsonSchema
+	dialer      *dialer
 	operator    *operator
 }
 
 type grpcMessage struct {
-	op     GRPCOp
-	params map[string]any
+	op      GRPCOp
+	params  map[string]any
+	receive *grpcReceive
 }
 
 type grpcRequest struct {
-	service  string
-	method   string
-	headers  metadata.MD
-	messages []*grpcMessage
-	timeout  time.Duration
+	service    string
+	method     string
+	headers    metadata.MD
+	messages   []*grpcMessage
+	timeout    time.Duration
+	jsonSchema string
+	receive    *grpcReceive
 }
 
 func newGrpcRunner(name, target string) (*grpcRunner, error) {
+	if strings.HasPrefix(target, grpcUnixScheme) {
+		target = grpcUnixSocketTarget(target)
+	}
 	return &grpcRunner{
 		name:   name,
 		target: target,
@@ -95,6 +107,10 @@ func newGrpcRunner(name, target string) (*grpcRunner, error) {
 }
 
 func (rnr *grpcRunner) Close() error {
+	if rnr.hc != nil {
+		rnr.hc.close()
+		rnr.hc = nil
+	}
 	if rnr.cc == nil {
 		rnr.refc = nil
 		return nil
@@ -104,46 +120,60 @@ func (rnr *grpcRunner) Close() error {
 }
 
 func (rnr *grpcRunner) Run(ctx context.Context, r *grpcRequest) error {
+	if err := rnr.connect(ctx); err != nil {
+		return err
+	}
+	if err := rnr.resolveAllMethods(ctx); err != nil {
+		return err
+	}
+	rnr.registerCoverage()
+	key := strings.Join([]string{r.service, r.method}, "/")
+	md, ok := rnr.mds[key]
+	if !ok {
+		return fmt.Errorf("cannot find method: %s", key)
+	}
+	rnr.operator.cov.hit(rnr.name, rnr.coverageSpec(), key, "")
+	if err := rnr.invoke(ctx, md, r); err != nil {
+		return err
+	}
+	return rnr.validateMessages(r)
+}
+
+// connect connects to the target if not connected yet.
+func (rnr *grpcRunner) connect(ctx context.Context) error {
+	if rnr.isHTTPProtocol() {
+		// gRPC-Web and Connect are called over HTTP, so server reflection ( bidi streaming ) is not available.
+		if rnr.hc == nil {
+			hc, err := rnr.newGRPCHTTPConn()
+			if err != nil {
+				return err
+			}
+			rnr.hc = hc
+		}
+		return nil
+	}
 	if rnr.cc == nil {
 		opts := []grpc.DialOption{
-			grpc.WithReturnConnectionError(),
 			grpc.WithUserAgent(fmt.Sprintf("runn/%s", version.Version)),
 		}
-		useTLS := true
-		if strings.HasSuffix(rnr.target, ":80") {
-			useTLS = false
-		}
-		if rnr.tls != nil {
-			useTLS = *rnr.tls
+		// Connect lazily in replay mode because calls may be replayed without the server
+		if c := rnr.operator.cassette; c == nil || c.cfg.mode != cassetteModeReplay {
+			opts = append(opts, grpc.WithReturnConnectionError())
 		}
-		if useTLS {
-			tlsc := tls.Config{MinVersion: tls.VersionTLS12}
-			if len(rnr.cert) != 0 {
-				certificate, err := tls.X509KeyPair(rnr.cert, rnr.key)
-				if err != nil {
-					return err
-				}
-				tlsc.Certificates = []tls.Certificate{certificate}
-			}
-			if rnr.skipVerify {
-				//#nosec G402
-				tlsc.InsecureSkipVerify = true
-			} else if len(rnr.cacert) != 0 {
-				certpool, err := x509.SystemCertPool()
-				if err != nil {
-					// FIXME for Windows
-					// ref: https://github.com/golang/go/issues/18609
-					certpool = x509.NewCertPool()
-				}
-				if ok := certpool.AppendCertsFromPEM(rnr.cacert); !ok {
-					return errors.New("failed to append cacert")
-				}
-				tlsc.RootCAs = certpool
+		if rnr.useTLS() {
+			tlsc, err := rnr.tlsConfig()
+			if err != nil {
+				return err
 			}
-			opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(&tlsc)))
+			opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsc)))
 		} else {
 			opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
 		}
+		if rnr.dialer != nil && len(rnr.dialer.resolve) > 0 && !isGRPCUnixSocketTarget(rnr.target) {
+			opts = append(opts, grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+				return rnr.dialer.DialContext(ctx, "tcp", addr)
+			}))
+		}
 		cctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 		defer cancel()
 		cc, err := grpc.DialContext(cctx, rnr.target, opts...)
@@ -155,20 +185,120 @@ func (rnr *grpcRunner) Run(ctx context.Context, r *grpcRequest) error {
 	if rnr.refc == nil {
 		rnr.refc = grpcreflect.NewClientAuto(ctx, rnr.cc)
 	}
+	return nil
+}
+
+func (rnr *grpcRunner) useTLS() bool {
+	if rnr.tls != nil {
+		return *rnr.tls
+	}
+	return !strings.HasSuffix(rnr.target, ":80") && !isGRPCUnixSocketTarget(rnr.target)
+}
+
+func (rnr *grpcRunner) tlsConfig() (*tls.Config, error) {
+	tlsc := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(rnr.cert) != 0 {
+		certificate, err := tls.X509KeyPair(rnr.cert, rnr.key)
+		if err != nil {
+			return nil, err
+		}
+		tlsc.Certificates = []tls.Certificate{certificate}
+	}
+	if rnr.skipVerify {
+		//#nosec G402
+		tlsc.InsecureSkipVerify = true
+	} else if len(rnr.cacert) != 0 {
+		certpool, err := x509.SystemCertPool()
+		if err != nil {
+			// FIXME for Windows
+			// ref: https://github.com/golang/go/issues/18609
+			certpool = x509.NewCertPool()
+		}
+		if ok := certpool.AppendCertsFromPEM(rnr.cacert); !ok {
+			return nil, errors.New("failed to append cacert")
+		}
+		tlsc.RootCAs = certpool
+	}
+	if rnr.dialer != nil {
+		rnr.dialer.applyTLS(tlsc)
+	}
+	return tlsc, nil
+}
+
+// resolveAllMethods resolves all methods using proto files and protosets if specified, otherwise using server reflection.
+func (rnr *grpcRunner) resolveAllMethods(ctx context.Context) error {
 	if len(rnr.importPaths) > 0 || len(rnr.protos) > 0 {
 		if err := rnr.resolveAllMethodsUsingProtos(ctx); err != nil {
 			return err
 		}
 	}
+	if len(rnr.protosets) > 0 {
+		if err := rnr.resolveAllMethodsUsingProtosets(); err != nil {
+			return err
+		}
+	}
 	if len(rnr.mds) == 0 {
+		resolved, err := rnr.resolveAllMethodsUsingCassette()
+		if err != nil {
+			return err
+		}
+		if resolved {
+			return nil
+		}
+		if rnr.refc == nil {
+			return fmt.Errorf("proto files or protosets are required to call methods using %s protocol", rnr.protocol)
+		}
 		if err := rnr.resolveAllMethodsUsingReflection(ctx); err != nil {
 			return err
 		}
 	}
-	key := strings.Join([]string{r.service, r.method}, "/")
-	md, ok := rnr.mds[key]
+	if c := rnr.operator.cassette; c != nil && c.cfg.mode == cassetteModeRecord {
+		return c.recordDescriptors(rnr.name, rnr.mds)
+	}
+	return nil
+}
+
+// resolveAllMethodsUsingCassette resolves all methods using the descriptors recorded in the cassette in replay mode.
+// In strict replay mode, the server is never called, so it returns an error if the descriptors are not recorded.
+func (rnr *grpcRunner) resolveAllMethodsUsingCassette() (bool, error) {
+	c := rnr.operator.cassette
+	if c == nil || c.cfg.mode != cassetteModeReplay {
+		return false, nil
+	}
+	fds, ok, err := c.replayDescriptors(rnr.name)
+	if err != nil {
+		return false, err
+	}
 	if !ok {
-		return fmt.Errorf("cannot find method: %s", key)
+		if c.cfg.strict {
+			return false, fmt.Errorf("descriptors of %s are not found in cassette %s: record the cassette again, or specify proto files or protosets", rnr.name, c.path)
+		}
+		return false, nil
+	}
+	if err := registerFiles(fds); err != nil {
+		return false, err
+	}
+	for _, fd := range fds {
+		rnr.addMethods(fd)
+	}
+	return true, nil
+}
+
+// conn returns the connection of the runner that records or replays calls when the cassette is enabled.
+func (rnr *grpcRunner) conn() grpc.ClientConnInterface {
+	var cc grpc.ClientConnInterface = rnr.cc
+	if rnr.hc != nil {
+		cc = rnr.hc
+	}
+	if rnr.operator.cassette == nil {
+		return cc
+	}
+	return rnr.operator.cassette.grpcConn(rnr.name, cc)
+}
+
+func (rnr *grpcRunner) invoke(ctx context.Context, md protoreflect.MethodDescriptor, r *grpcRequest) error {
+	if r.receive != nil && (!md.IsStreamingServer() || md.IsStreamingClient()) {
+		return errors.New("receive: is only for server streaming RPC ( use receive op in messages: for bidirectional streaming RPC )")
 	}
 	switch {
 	case !md.IsStreamingServer() && !md.IsStreamingClient():
@@ -216,7 +346,7 @@ func (rnr *grpcRunner) invokeUnary(ctx context.Context, md protoreflect.MethodDe
 		resTrailers metadata.MD
 	)
 	res := dynamicpb.NewMessage(md.Output())
-	err := rnr.cc.Invoke(ctx, toEndpoint(md.FullName()), req, res, grpc.Header(&resHeaders), grpc.Trailer(&resTrailers))
+	err := rnr.conn().Invoke(ctx, toEndpoint(md.FullName()), req, res, grpc.Header(&resHeaders), grpc.Trailer(&resTrailers))
 	stat, ok := status.FromError(err)
 	if !ok {
 		return err
@@ -227,6 +357,7 @@ func (rnr *grpcRunner) invokeUnary(ctx context.Context, md protoreflect.MethodDe
 		string(grpcStoreHeaderKey):  resHeaders,
 		string(grpcStoreTrailerKey): resTrailers,
 		string(grpcStoreMessageKey): nil,
+		string(grpcStoreDetailsKey): []any{},
 	}
 
 	rnr.operator.capturers.captureGRPCResponseStatus(stat)
@@ -251,6 +382,7 @@ func (rnr *grpcRunner) invokeUnary(ctx context.Context, md protoreflect.MethodDe
 		d[grpcStoreMessagesKey] = messages
 	} else {
 		d[grpcStoreMessageKey] = stat.Message()
+		d[grpcStoreDetailsKey] = grpcStatusDetails(stat)
 	}
 
 	rnr.operator.record(map[string]any{
@@ -284,7 +416,10 @@ func (rnr *grpcRunner) invokeServerStreaming(ctx context.Context, md protoreflec
 		ClientStreams: md.IsStreamingClient(),
 	}
 
-	stream, err := rnr.cc.NewStream(ctx, streamDesc, toEndpoint(md.FullName()))
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := rnr.conn().NewStream(ctx, streamDesc, toEndpoint(md.FullName()))
 	if err != nil {
 		return err
 	}
@@ -296,44 +431,55 @@ func (rnr *grpcRunner) invokeServerStreaming(ctx context.Context, md protoreflec
 		string(grpcStoreHeaderKey):  metadata.MD{},
 		string(grpcStoreTrailerKey): metadata.MD{},
 		string(grpcStoreMessageKey): nil,
+		string(grpcStoreDetailsKey): []any{},
 	}
 	var messages []map[string]any
 
-	for err == nil {
-		res := dynamicpb.NewMessage(md.Output())
-		err = stream.RecvMsg(res)
+	if r.receive != nil {
+		messages, _, err = rnr.receiveWithCondition(stream, md, r.receive, cancel, d, messages)
 		if err != nil {
-			if errors.Is(err, context.Canceled) {
-				break
-			}
-			if errors.Is(err, io.EOF) {
-				break
-			}
-		}
-		stat, ok := status.FromError(err)
-		if !ok {
 			return err
 		}
-		d[grpcStoreStatusKey] = int64(stat.Code())
-
-		rnr.operator.capturers.captureGRPCResponseStatus(stat)
-
-		if stat.Code() == codes.OK {
-			b, err := protojson.MarshalOptions{UseProtoNames: true, UseEnumNumbers: true, EmitUnpopulated: true}.Marshal(res)
+		// Cancel the stream because the rest of messages are not received ( e.g. infinite watch-style streams )
+		cancel()
+	} else {
+		for err == nil {
+			res := dynamicpb.NewMessage(md.Output())
+			err = stream.RecvMsg(res)
 			if err != nil {
-				return err
+				if errors.Is(err, context.Canceled) {
+					break
+				}
+				if errors.Is(err, io.EOF) {
+					break
+				}
 			}
-			var msg map[string]any
-			if err := json.Unmarshal(b, &msg); err != nil {
+			stat, ok := status.FromError(err)
+			if !ok {
 				return err
 			}
-			d[grpcStoreMessageKey] = msg
+			d[grpcStoreStatusKey] = int64(stat.Code())
 
-			rnr.operator.capturers.captureGRPCResponseMessage(msg)
+			rnr.operator.capturers.captureGRPCResponseStatus(stat)
 
-			messages = append(messages, msg)
-		} else {
-			d[grpcStoreMessageKey] = stat.Message()
+			if stat.Code() == codes.OK {
+				b, err := protojson.MarshalOptions{UseProtoNames: true, UseEnumNumbers: true, EmitUnpopulated: true}.Marshal(res)
+				if err != nil {
+					return err
+				}
+				var msg map[string]any
+				if err := json.Unmarshal(b, &msg); err != nil {
+					return err
+				}
+				d[grpcStoreMessageKey] = msg
+
+				rnr.operator.capturers.captureGRPCResponseMessage(msg)
+
+				messages = append(messages, msg)
+			} else {
+				d[grpcStoreMessageKey] = stat.Message()
+				d[grpcStoreDetailsKey] = grpcStatusDetails(stat)
+			}
 		}
 	}
 	d[grpcStoreMessagesKey] = messages
@@ -370,7 +516,7 @@ func (rnr *grpcRunner) invokeClientStreaming(ctx context.Context, md protoreflec
 		ServerStreams: md.IsStreamingServer(),
 		ClientStreams: md.IsStreamingClient(),
 	}
-	stream, err := rnr.cc.NewStream(ctx, streamDesc, toEndpoint(md.FullName()))
+	stream, err := rnr.conn().NewStream(ctx, streamDesc, toEndpoint(md.FullName()))
 	if err != nil {
 		return err
 	}
@@ -378,6 +524,7 @@ func (rnr *grpcRunner) invokeClientStreaming(ctx context.Context, md protoreflec
 		string(grpcStoreHeaderKey):  metadata.MD{},
 		string(grpcStoreTrailerKey): metadata.MD{},
 		string(grpcStoreMessageKey): nil,
+		string(grpcStoreDetailsKey): []any{},
 	}
 	var messages []map[string]any
 	for _, m := range r.messages {
@@ -430,6 +577,7 @@ func (rnr *grpcRunner) invokeClientStreaming(ctx context.Context, md protoreflec
 		messages = append(messages, msg)
 	} else {
 		d[grpcStoreMessageKey] = stat.Message()
+		d[grpcStoreDetailsKey] = grpcStatusDetails(stat)
 	}
 
 	d[grpcStoreMessagesKey] = messages
@@ -464,7 +612,10 @@ func (rnr *grpcRunner) invokeBidiStreaming(ctx context.Context, md protoreflect.
 		ClientStreams: md.IsStreamingClient(),
 	}
 
-	stream, err := rnr.cc.NewStream(ctx, streamDesc, toEndpoint(md.FullName()))
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := rnr.conn().NewStream(ctx, streamDesc, toEndpoint(md.FullName()))
 	if err != nil {
 		return err
 	}
@@ -473,9 +624,12 @@ func (rnr *grpcRunner) invokeBidiStreaming(ctx context.Context, md protoreflect.
 		string(grpcStoreHeaderKey):  metadata.MD{},
 		string(grpcStoreTrailerKey): metadata.MD{},
 		string(grpcStoreMessageKey): nil,
+		string(grpcStoreDetailsKey): []any{},
 	}
 	var messages []map[string]any
 	clientClose := false
+	// Whether messages are received with conditions. If so, the rest of messages are not received after the operations.
+	receiveWithCondition := false
 L:
 	for _, m := range r.messages {
 		switch m.op {
@@ -494,6 +648,26 @@ L:
 
 			req.Reset()
 		case GRPCOpReceive:
+			if m.receive != nil {
+				receiveWithCondition = true
+				var (
+					ended bool
+					rerr  error
+				)
+				messages, ended, rerr = rnr.receiveWithCondition(stream, md, m.receive, cancel, d, messages)
+				if rerr != nil {
+					return rerr
+				}
+				if h, err := stream.Header(); err == nil {
+					d[grpcStoreHeaderKey] = h
+
+					rnr.operator.capturers.captureGRPCResponseHeaders(h)
+				}
+				if ended {
+					break L
+				}
+				continue
+			}
 			res := dynamicpb.NewMessage(md.Output())
 			err := stream.RecvMsg(res)
 			if errors.Is(err, context.Canceled) {
@@ -531,6 +705,7 @@ L:
 				messages = append(messages, msg)
 			} else {
 				d[grpcStoreMessageKey] = stat.Message()
+				d[grpcStoreDetailsKey] = grpcStatusDetails(stat)
 			}
 		case GRPCOpClose:
 			clientClose = true
@@ -548,11 +723,15 @@ L:
 	if stat.Code() != codes.OK {
 		d[grpcStoreStatusKey] = int64(stat.Code())
 		d[grpcStoreMessageKey] = stat.Message()
+		d[grpcStoreDetailsKey] = grpcStatusDetails(stat)
 
 		rnr.operator.capturers.captureGRPCResponseStatus(stat)
 	}
 
-	if clientClose {
+	if receiveWithCondition {
+		// Cancel the stream because the rest of messages are not received ( e.g. infinite watch-style streams )
+		cancel()
+	} else if clientClose {
 		for {
 			res := dynamicpb.NewMessage(md.Output())
 			if err := stream.RecvMsg(res); err != nil {
@@ -603,17 +782,20 @@ L:
 					messages = append(messages, msg)
 				} else {
 					d[grpcStoreMessageKey] = stat.Message()
+					d[grpcStoreDetailsKey] = grpcStatusDetails(stat)
 				}
 			}
 		}
 	}
 
 	// If the connection is not disconnected here, it will fall into a race condition when retrieving the trailer.
-	if err := rnr.cc.Close(); err != nil {
-		return err
+	if rnr.cc != nil {
+		if err := rnr.cc.Close(); err != nil {
+			return err
+		}
+		rnr.cc = nil
+		rnr.refc = nil
 	}
-	rnr.cc = nil
-	rnr.refc = nil
 
 	d[grpcStoreMessagesKey] = messages
 	if h, err := stream.Header(); len(d[grpcStoreHeaderKey].(metadata.MD)) == 0 && err == nil {
@@ -698,6 +880,14 @@ func (rnr *grpcRunner) findDescripter(svc protoreflect.FullName) (protoreflect.D
 	if err != nil {
 		return nil, err
 	}
+	// Register imported files to resolve types not used by methods ( e.g. types of status details )
+	var imports []protoreflect.FileDescriptor
+	for i := 0; i < fd.Imports().Len(); i++ {
+		imports = append(imports, fd.Imports().Get(i).FileDescriptor)
+	}
+	if err := registerFiles(imports); err != nil {
+		return nil, err
+	}
 	if err := protoregistry.GlobalFiles.RegisterFile(fd); err != nil {
 		return nil, err
 	}
@@ -722,22 +912,30 @@ func (rnr *grpcRunner) resolveAllMethodsUsingProtos(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	if err := registerFiles(fds); err != nil {
+	files := make([]protoreflect.FileDescriptor, 0, len(fds))
+	for _, fd := range fds {
+		files = append(files, fd)
+	}
+	if err := registerFiles(files); err != nil {
 		return err
 	}
-	for _, fd := range fds {
-		for i := 0; i < fd.Services().Len(); i++ {
-			svc := fd.Services().Get(i)
-			for j := 0; j < svc.Methods().Len(); j++ {
-				m := svc.Methods().Get(j)
-				key := fmt.Sprintf("%s/%s", svc.FullName(), m.Name())
-				rnr.mds[key] = m
-			}
-		}
+	for _, fd := range files {
+		rnr.addMethods(fd)
 	}
 	return nil
 }
 
+func (rnr *grpcRunner) addMethods(fd protoreflect.FileDescriptor) {
+	for i := 0; i < fd.Services().Len(); i++ {
+		svc := fd.Services().Get(i)
+		for j := 0; j < svc.Methods().Len(); j++ {
+			m := svc.Methods().Get(j)
+			key := fmt.Sprintf("%s/%s", svc.FullName(), m.Name())
+			rnr.mds[key] = m
+		}
+	}
+}
+
 func dcopy(in any) any {
 	return copystructure.Must(copystructure.Copy(in))
 }
@@ -749,8 +947,9 @@ func toEndpoint(mn protoreflect.FullName) string {
 	return fmt.Sprintf("/%s/%s", service, method)
 }
 
-func registerFiles(fds linker.Files) (err error) {
-	for _, fd := range fds {
+// registerFiles registers the files and the files imported by them to protoregistry.GlobalFiles.
+func registerFiles(fds []protoreflect.FileDescriptor) (err error) {
+	for _, fd := range withImports(fds) {
 		// Skip registration of already registered descriptors
 		if _, err := protoregistry.GlobalFiles.FindFileByPath(fd.Path()); !errors.Is(protoregistry.NotFound, err) {
 			continue
@@ -773,6 +972,27 @@ func registerFiles(fds linker.Files) (err error) {
 	return nil
 }
 
+// withImports returns the files and the files imported by them in the order of dependencies.
+func withImports(fds []protoreflect.FileDescriptor) []protoreflect.FileDescriptor {
+	var files []protoreflect.FileDescriptor
+	seen := map[string]struct{}{}
+	var walk func(fd protoreflect.FileDescriptor)
+	walk = func(fd protoreflect.FileDescriptor) {
+		if _, ok := seen[fd.Path()]; ok || fd.IsPlaceholder() {
+			return
+		}
+		seen[fd.Path()] = struct{}{}
+		for i := 0; i < fd.Imports().Len(); i++ {
+			walk(fd.Imports().Get(i).FileDescriptor)
+		}
+		files = append(files, fd)
+	}
+	for _, fd := range fds {
+		walk(fd)
+	}
+	return files
+}
+
 // copy from google.golang.org/protobuf/reflect/protoregistry.
 func rangeTopLevelDescriptors(fd protoreflect.FileDescriptor, f func(protoreflect.Descriptor)) {
 	eds := fd.Enums()
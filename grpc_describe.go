@@ -0,0 +1,341 @@
+package runn
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"gopkg.in/yaml.v2"
+)
+
+const grpcDescribeRunnerKey = "greq"
+
+const (
+	GRPCStreamTypeUnary           = "unary"
+	GRPCStreamTypeServerStreaming = "server streaming"
+	GRPCStreamTypeClientStreaming = "client streaming"
+	GRPCStreamTypeBidiStreaming   = "bidi streaming"
+)
+
+// GRPCMethod is the method of gRPC service.
+type GRPCMethod struct {
+	Service string
+	Method  string
+	md      protoreflect.MethodDescriptor
+}
+
+// GRPCMethods returns all methods of the target ( e.g. "grpc.example.com:443", "grpc://grpc.example.com:443" or "unix:///var/run/app.sock" ) resolved using proto files ( GRPCProtos and GRPCImportPaths ), protosets ( GRPCProtosets ) or server reflection.
+// runnerOpts configure the gRPC runner connecting to the target ( e.g. CACert, Cert, Key and GRPCSkipVerify ).
+func GRPCMethods(ctx context.Context, target string, opts []Option, runnerOpts ...grpcRunnerOption) ([]*GRPCMethod, error) {
+	dsn := grpcTargetToDSN(target)
+	if len(runnerOpts) > 0 {
+		opts = append(opts, GrpcRunnerWithOptions(grpcDescribeRunnerKey, grpcDSNToAddr(dsn), runnerOpts...))
+	} else {
+		opts = append(opts, Runner(grpcDescribeRunnerKey, dsn))
+	}
+	o, err := New(opts...)
+	if err != nil {
+		return nil, err
+	}
+	rnr, ok := o.grpcRunners[grpcDescribeRunnerKey]
+	if !ok {
+		return nil, fmt.Errorf("invalid gRPC target: %s", target)
+	}
+	defer func() {
+		_ = rnr.Close()
+	}()
+	// Methods can be resolved without connecting to the target if proto files or protosets are specified.
+	if len(rnr.importPaths) > 0 || len(rnr.protos) > 0 || len(rnr.protosets) > 0 {
+		if len(rnr.importPaths) > 0 || len(rnr.protos) > 0 {
+			if err := rnr.resolveAllMethodsUsingProtos(ctx); err != nil {
+				return nil, err
+			}
+		}
+		if len(rnr.protosets) > 0 {
+			if err := rnr.resolveAllMethodsUsingProtosets(); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		if err := rnr.connect(ctx); err != nil {
+			return nil, err
+		}
+		if err := rnr.resolveAllMethodsUsingReflection(ctx); err != nil {
+			return nil, err
+		}
+	}
+	var methods []*GRPCMethod
+	for _, md := range rnr.mds {
+		methods = append(methods, &GRPCMethod{
+			Service: string(md.Parent().FullName()),
+			Method:  string(md.Name()),
+			md:      md,
+		})
+	}
+	sort.Slice(methods, func(i, j int) bool {
+		if methods[i].Service == methods[j].Service {
+			return methods[i].Method < methods[j].Method
+		}
+		return methods[i].Service < methods[j].Service
+	})
+	return methods, nil
+}
+
+// FullName returns the name of the method in the form used in runbooks ( e.g. "grpc.health.v1.Health/Check" ).
+func (m *GRPCMethod) FullName() string {
+	return strings.Join([]string{m.Service, m.Method}, "/")
+}
+
+// StreamType returns the streaming type of the method.
+func (m *GRPCMethod) StreamType() string {
+	switch {
+	case m.md.IsStreamingClient() && m.md.IsStreamingServer():
+		return GRPCStreamTypeBidiStreaming
+	case m.md.IsStreamingClient():
+		return GRPCStreamTypeClientStreaming
+	case m.md.IsStreamingServer():
+		return GRPCStreamTypeServerStreaming
+	default:
+		return GRPCStreamTypeUnary
+	}
+}
+
+// Describe returns the definition of the method and the messages used by the method in protocol buffers format.
+func (m *GRPCMethod) Describe() string {
+	var b strings.Builder
+	in := string(m.md.Input().FullName())
+	if m.md.IsStreamingClient() {
+		in = "stream " + in
+	}
+	out := string(m.md.Output().FullName())
+	if m.md.IsStreamingServer() {
+		out = "stream " + out
+	}
+	_, _ = fmt.Fprintf(&b, "rpc %s ( %s ) returns ( %s );\n", m.md.Name(), in, out)
+
+	// messages and enums used by the method ( except well-known types )
+	var ds []protoreflect.Descriptor
+	seen := map[protoreflect.FullName]struct{}{}
+	var walk func(d protoreflect.Descriptor)
+	walk = func(d protoreflect.Descriptor) {
+		if _, ok := seen[d.FullName()]; ok || isWellKnownType(d) {
+			return
+		}
+		seen[d.FullName()] = struct{}{}
+		ds = append(ds, d)
+		md, ok := d.(protoreflect.MessageDescriptor)
+		if !ok {
+			return
+		}
+		fs := md.Fields()
+		for i := 0; i < fs.Len(); i++ {
+			f := fs.Get(i)
+			if f.IsMap() {
+				f = f.MapValue()
+			}
+			switch {
+			case f.Message() != nil:
+				walk(f.Message())
+			case f.Enum() != nil:
+				walk(f.Enum())
+			}
+		}
+	}
+	walk(m.md.Input())
+	walk(m.md.Output())
+	for _, d := range ds {
+		b.WriteString("\n")
+		switch v := d.(type) {
+		case protoreflect.MessageDescriptor:
+			writeMessageDescriptor(&b, v)
+		case protoreflect.EnumDescriptor:
+			writeEnumDescriptor(&b, v)
+		}
+	}
+	return b.String()
+}
+
+// Message returns the zero-valued request message of the method.
+func (m *GRPCMethod) Message() yaml.MapSlice {
+	return zeroMessage(m.md.Input(), map[protoreflect.FullName]struct{}{})
+}
+
+// AppendGRPCStep appends the step skeleton to call the gRPC method to the runbook.
+func (rb *runbook) AppendGRPCStep(target string, m *GRPCMethod) error {
+	if rb.useMap {
+		key := fmt.Sprintf("%s%d", strings.ToLower(m.Method), len(rb.stepKeys))
+		rb.stepKeys = append(rb.stepKeys, key)
+	}
+	key := rb.setRunner(grpcTargetToDSN(target))
+	var hm yaml.MapSlice
+	switch m.StreamType() {
+	case GRPCStreamTypeClientStreaming:
+		hm = yaml.MapSlice{
+			{Key: "messages", Value: []any{m.Message()}},
+		}
+	case GRPCStreamTypeBidiStreaming:
+		hm = yaml.MapSlice{
+			{Key: "messages", Value: []any{m.Message(), string(GRPCOpReceive), string(GRPCOpClose)}},
+		}
+	default:
+		hm = yaml.MapSlice{
+			{Key: "message", Value: m.Message()},
+		}
+	}
+	step := yaml.MapSlice{
+		{Key: key, Value: yaml.MapSlice{
+			{Key: m.FullName(), Value: hm},
+		}},
+	}
+	rb.Steps = append(rb.Steps, step)
+	return nil
+}
+
+// grpcTargetToDSN converts the target of gRPC to the DSN of gRPC runner.
+func grpcTargetToDSN(target string) string {
+	switch {
+	case strings.HasPrefix(target, unixScheme):
+		return grpcUnixScheme + strings.TrimPrefix(target, unixScheme)
+	case strings.Contains(target, "://"):
+		return target
+	default:
+		return "grpc://" + target
+	}
+}
+
+// grpcDSNToAddr converts the DSN of gRPC runner to the address of gRPC runner.
+func grpcDSNToAddr(dsn string) string {
+	if strings.HasPrefix(dsn, grpcUnixScheme) {
+		return grpcUnixSocketTarget(dsn)
+	}
+	return strings.TrimPrefix(dsn, "grpc://")
+}
+
+func isWellKnownType(d protoreflect.Descriptor) bool {
+	return d.ParentFile() != nil && d.ParentFile().Package() == "google.protobuf"
+}
+
+func writeMessageDescriptor(b *strings.Builder, md protoreflect.MessageDescriptor) {
+	_, _ = fmt.Fprintf(b, "message %s {\n", md.FullName())
+	fs := md.Fields()
+	written := map[protoreflect.FullName]struct{}{}
+	for i := 0; i < fs.Len(); i++ {
+		f := fs.Get(i)
+		if od := f.ContainingOneof(); od != nil && !od.IsSynthetic() {
+			if _, ok := written[od.FullName()]; ok {
+				continue
+			}
+			written[od.FullName()] = struct{}{}
+			_, _ = fmt.Fprintf(b, "  oneof %s {\n", od.Name())
+			ofs := od.Fields()
+			for j := 0; j < ofs.Len(); j++ {
+				_, _ = fmt.Fprintf(b, "    %s %s = %d;\n", fieldType(ofs.Get(j)), ofs.Get(j).Name(), ofs.Get(j).Number())
+			}
+			b.WriteString("  }\n")
+			continue
+		}
+		label := ""
+		switch {
+		case f.IsList():
+			label = "repeated "
+		case f.HasOptionalKeyword():
+			label = "optional "
+		}
+		_, _ = fmt.Fprintf(b, "  %s%s %s = %d;\n", label, fieldType(f), f.Name(), f.Number())
+	}
+	b.WriteString("}\n")
+}
+
+func writeEnumDescriptor(b *strings.Builder, ed protoreflect.EnumDescriptor) {
+	_, _ = fmt.Fprintf(b, "enum %s {\n", ed.FullName())
+	vs := ed.Values()
+	for i := 0; i < vs.Len(); i++ {
+		_, _ = fmt.Fprintf(b, "  %s = %d;\n", vs.Get(i).Name(), vs.Get(i).Number())
+	}
+	b.WriteString("}\n")
+}
+
+func fieldType(f protoreflect.FieldDescriptor) string {
+	switch {
+	case f.IsMap():
+		return fmt.Sprintf("map<%s, %s>", fieldType(f.MapKey()), fieldType(f.MapValue()))
+	case f.Message() != nil:
+		return string(f.Message().FullName())
+	case f.Enum() != nil:
+		return string(f.Enum().FullName())
+	default:
+		return f.Kind().String()
+	}
+}
+
+// zeroMessage returns the zero value of the message in field order.
+// Only the first field of each oneof is set, and recursive messages are set to null.
+func zeroMessage(md protoreflect.MessageDescriptor, seen map[protoreflect.FullName]struct{}) yaml.MapSlice {
+	seen[md.FullName()] = struct{}{}
+	defer delete(seen, md.FullName())
+	m := yaml.MapSlice{}
+	fs := md.Fields()
+	oneofs := map[protoreflect.FullName]struct{}{}
+	for i := 0; i < fs.Len(); i++ {
+		f := fs.Get(i)
+		if od := f.ContainingOneof(); od != nil && !od.IsSynthetic() {
+			if _, ok := oneofs[od.FullName()]; ok {
+				continue
+			}
+			oneofs[od.FullName()] = struct{}{}
+		}
+		m = append(m, yaml.MapItem{Key: string(f.Name()), Value: zeroValue(f, seen)})
+	}
+	return m
+}
+
+func zeroValue(f protoreflect.FieldDescriptor, seen map[protoreflect.FullName]struct{}) any {
+	switch {
+	case f.IsMap():
+		return yaml.MapSlice{}
+	case f.IsList():
+		return []any{}
+	}
+	switch f.Kind() {
+	case protoreflect.BoolKind:
+		return false
+	case protoreflect.StringKind, protoreflect.BytesKind:
+		return ""
+	case protoreflect.EnumKind:
+		if f.Enum().Values().Len() == 0 {
+			return 0
+		}
+		return string(f.Enum().Values().Get(0).Name())
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return 0.0
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		md := f.Message()
+		if isWellKnownType(md) {
+			return zeroWellKnownType(md)
+		}
+		if _, ok := seen[md.FullName()]; ok {
+			return nil
+		}
+		return zeroMessage(md, seen)
+	default:
+		return 0
+	}
+}
+
+// zeroWellKnownType returns the zero value of the well-known type in JSON mapping ( e.g. "1970-01-01T00:00:00Z" for google.protobuf.Timestamp ).
+func zeroWellKnownType(md protoreflect.MessageDescriptor) any {
+	b, err := protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}.Marshal(dynamicpb.NewMessage(md))
+	if err != nil {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil
+	}
+	return v
+}
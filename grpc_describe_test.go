@@ -0,0 +1,189 @@
+package runn
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tenntenn/golden"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"gopkg.in/yaml.v2"
+)
+
+func TestGRPCMethodsUsingProtos(t *testing.T) {
+	tests := []struct {
+		name        string
+		protos      []string
+		importPaths []string
+		want        []string
+	}{
+		{
+			"grpctest",
+			[]string{"testdata/grpctest.proto"},
+			[]string{"testdata"},
+			[]string{
+				"grpctest.GrpcTestService/Hello unary",
+				"grpctest.GrpcTestService/HelloChat bidi streaming",
+				"grpctest.GrpcTestService/ListHello server streaming",
+				"grpctest.GrpcTestService/MultiHello client streaming",
+			},
+		},
+		{
+			"describe",
+			[]string{"testdata/grpc_describe/describe.proto"},
+			[]string{"testdata/grpc_describe"},
+			[]string{
+				"describetest.DescribeService/Describe unary",
+			},
+		},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The target is not connected because the methods are resolved using proto files
+			methods, err := GRPCMethods(ctx, "localhost:1", []Option{GRPCProtos(tt.protos), GRPCImportPaths(tt.importPaths)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, m := range methods {
+				got = append(got, fmt.Sprintf("%s %s", m.FullName(), m.StreamType()))
+			}
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Error(diff)
+			}
+
+			describe := new(bytes.Buffer)
+			rb := NewRunbook(tt.name)
+			for _, m := range methods {
+				_, _ = fmt.Fprintf(describe, "%s\n%s\n", m.FullName(), m.Describe())
+				if err := rb.AppendGRPCStep("localhost:1", m); err != nil {
+					t.Fatal(err)
+				}
+			}
+			step := new(bytes.Buffer)
+			if err := yaml.NewEncoder(step).Encode(rb); err != nil {
+				t.Fatal(err)
+			}
+			for f, got := range map[string]*bytes.Buffer{
+				fmt.Sprintf("%s.grpc_describe", tt.name): describe,
+				fmt.Sprintf("%s.grpc_step", tt.name):     step,
+			} {
+				if os.Getenv("UPDATE_GOLDEN") != "" {
+					golden.Update(t, "testdata", f, got)
+					continue
+				}
+				if diff := golden.Diff(t, "testdata", f, got); diff != "" {
+					t.Error(diff)
+				}
+			}
+		})
+	}
+}
+
+func TestGRPCMethodsUsingReflection(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	reflection.Register(s)
+	go func() {
+		_ = s.Serve(l)
+	}()
+	t.Cleanup(s.Stop)
+
+	ctx := context.Background()
+	methods, err := GRPCMethods(ctx, l.Addr().String(), []Option{GRPCNoTLS(true)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, m := range methods {
+		got[m.FullName()] = m.StreamType()
+	}
+	want := map[string]string{
+		"grpc.health.v1.Health/Check": GRPCStreamTypeUnary,
+		"grpc.health.v1.Health/Watch": GRPCStreamTypeServerStreaming,
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: got %v\nwant %v", k, got[k], v)
+		}
+	}
+}
+
+func TestGRPCMethodsWithTLS(t *testing.T) {
+	// Use the certificate of httptest for 127.0.0.1
+	ts := httptest.NewUnstartedServer(nil)
+	ts.StartTLS()
+	ts.Close()
+	cacert := filepath.Join(t.TempDir(), "cacert.pem")
+	if err := os.WriteFile(cacert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{Certificates: ts.TLS.Certificates, MinVersion: tls.VersionTLS12})))
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	reflection.Register(s)
+	go func() {
+		_ = s.Serve(l)
+	}()
+	t.Cleanup(s.Stop)
+
+	tests := []struct {
+		name    string
+		opts    []grpcRunnerOption
+		wantErr bool
+	}{
+		{"without cacert", nil, true},
+		{"with cacert", []grpcRunnerOption{CACert(cacert)}, false},
+		{"skip verify", []grpcRunnerOption{GRPCSkipVerify(true)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			_, err := GRPCMethods(ctx, l.Addr().String(), nil, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got %v\nwantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGrpcTargetToDSN(t *testing.T) {
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"grpc.example.com:443", "grpc://grpc.example.com:443"},
+		{"grpc://grpc.example.com:443", "grpc://grpc.example.com:443"},
+		{"unix:///var/run/app.sock", "grpc+unix:///var/run/app.sock"},
+		{"grpc+unix:///var/run/app.sock", "grpc+unix:///var/run/app.sock"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			if got := grpcTargetToDSN(tt.target); got != tt.want {
+				t.Errorf("got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
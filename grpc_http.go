@@ -0,0 +1,661 @@
+package runn
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/k1LoW/runn/version"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	grpcProtocolGRPC    = "grpc"
+	grpcProtocolGRPCWeb = "grpcweb"
+	grpcProtocolConnect = "connect"
+)
+
+const (
+	grpcWebContentType          = "application/grpc-web+proto"
+	connectUnaryContentType     = "application/proto"
+	connectStreamingContentType = "application/connect+proto"
+)
+
+const (
+	// Flag of the message frame that contains trailers ( gRPC-Web ).
+	grpcWebTrailerFlag byte = 0x80
+	// Flag of the message frame that ends the stream ( Connect ).
+	connectEndStreamFlag byte = 0x02
+	// Flag of the compressed message frame ( gRPC-Web and Connect ).
+	compressedFlag byte = 0x01
+)
+
+// grpcHTTPReservedHeaders are not stored in headers and trailers of responses.
+var grpcHTTPReservedHeaders = map[string]struct{}{
+	"content-type":             {},
+	"content-length":           {},
+	"content-encoding":         {},
+	"connection":               {},
+	"keep-alive":               {},
+	"transfer-encoding":        {},
+	"te":                       {},
+	"trailer":                  {},
+	"grpc-status":              {},
+	"grpc-message":             {},
+	"grpc-status-details-bin":  {},
+	"grpc-encoding":            {},
+	"grpc-accept-encoding":     {},
+	"connect-content-encoding": {},
+	"connect-accept-encoding":  {},
+	"connect-protocol-version": {},
+	"connect-timeout-ms":       {},
+	"grpc-timeout":             {},
+	"x-grpc-web":               {},
+	"x-user-agent":             {},
+	"accept-encoding":          {},
+}
+
+var connectCodes = map[string]codes.Code{
+	"canceled":            codes.Canceled,
+	"unknown":             codes.Unknown,
+	"invalid_argument":    codes.InvalidArgument,
+	"deadline_exceeded":   codes.DeadlineExceeded,
+	"not_found":           codes.NotFound,
+	"already_exists":      codes.AlreadyExists,
+	"permission_denied":   codes.PermissionDenied,
+	"resource_exhausted":  codes.ResourceExhausted,
+	"failed_precondition": codes.FailedPrecondition,
+	"aborted":             codes.Aborted,
+	"out_of_range":        codes.OutOfRange,
+	"unimplemented":       codes.Unimplemented,
+	"internal":            codes.Internal,
+	"unavailable":         codes.Unavailable,
+	"data_loss":           codes.DataLoss,
+	"unauthenticated":     codes.Unauthenticated,
+}
+
+func validateGRPCProtocol(protocol string) error {
+	switch protocol {
+	case "", grpcProtocolGRPC, grpcProtocolGRPCWeb, grpcProtocolConnect:
+		return nil
+	default:
+		return fmt.Errorf("invalid gRPC protocol: %s ( grpc, grpcweb or connect )", protocol)
+	}
+}
+
+// isHTTPProtocol returns true if the runner calls methods over HTTP ( gRPC-Web or Connect ) instead of gRPC.
+func (rnr *grpcRunner) isHTTPProtocol() bool {
+	return rnr.protocol == grpcProtocolGRPCWeb || rnr.protocol == grpcProtocolConnect
+}
+
+// grpcHTTPConn is grpc.ClientConnInterface that calls methods using gRPC-Web or Connect protocol.
+// Streams are half-duplex: request messages are sent together when the client closes sending or starts receiving.
+type grpcHTTPConn struct {
+	protocol string
+	endpoint *url.URL
+	client   *http.Client
+}
+
+func (rnr *grpcRunner) newGRPCHTTPConn() (*grpcHTTPConn, error) {
+	var (
+		endpoint *url.URL
+		ts       *http.Transport
+	)
+	switch {
+	case isGRPCUnixSocketTarget(rnr.target):
+		socket := strings.TrimPrefix(rnr.target, unixScheme)
+		switch {
+		case strings.HasPrefix(rnr.target, "unix-abstract:"):
+			socket = "@" + strings.TrimPrefix(rnr.target, "unix-abstract:")
+		case socket == rnr.target:
+			socket = strings.TrimPrefix(rnr.target, "unix:")
+		}
+		ts = unixSocketTransport(socket)
+		scheme := "http"
+		if rnr.tls != nil && *rnr.tls {
+			scheme = "https"
+		}
+		endpoint = &url.URL{Scheme: scheme, Host: unixSocketHost}
+	case strings.HasPrefix(rnr.target, "http://") || strings.HasPrefix(rnr.target, "https://"):
+		u, err := url.Parse(rnr.target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gRPC target: %s: %w", rnr.target, err)
+		}
+		ts = http.DefaultTransport.(*http.Transport).Clone()
+		endpoint = u
+	default:
+		ts = http.DefaultTransport.(*http.Transport).Clone()
+		scheme := "http"
+		if rnr.useTLS() {
+			scheme = "https"
+		}
+		endpoint = &url.URL{Scheme: scheme, Host: rnr.target}
+	}
+	if endpoint.Scheme == "https" {
+		tlsc, err := rnr.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		ts.TLSClientConfig = tlsc
+	}
+	if rnr.dialer != nil && len(rnr.dialer.resolve) > 0 && !isGRPCUnixSocketTarget(rnr.target) {
+		ts.DialContext = rnr.dialer.DialContext
+	}
+	endpoint.Path = strings.TrimSuffix(endpoint.Path, "/")
+	return &grpcHTTPConn{
+		protocol: rnr.protocol,
+		endpoint: endpoint,
+		client:   &http.Client{Transport: ts},
+	}, nil
+}
+
+func (c *grpcHTTPConn) close() {
+	c.client.CloseIdleConnections()
+}
+
+func (c *grpcHTTPConn) Invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	s := c.newStream(ctx, &grpc.StreamDesc{}, method)
+	defer s.setCallOptions(opts)
+	if err := s.SendMsg(args); err != nil {
+		return err
+	}
+	if err := s.CloseSend(); err != nil {
+		return err
+	}
+	if err := s.RecvMsg(reply); err != nil {
+		if errors.Is(err, io.EOF) {
+			return status.Error(codes.Internal, "no response message for unary RPC")
+		}
+		return err
+	}
+	if _, err := s.recv(); !errors.Is(err, io.EOF) {
+		if err == nil {
+			return status.Error(codes.Internal, "more than one response message for unary RPC")
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *grpcHTTPConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return c.newStream(ctx, desc, method), nil
+}
+
+func (c *grpcHTTPConn) newStream(ctx context.Context, desc *grpc.StreamDesc, method string) *grpcHTTPStream {
+	return &grpcHTTPStream{
+		ctx:     ctx,
+		conn:    c,
+		method:  method,
+		unary:   !desc.ClientStreams && !desc.ServerStreams,
+		header:  metadata.MD{},
+		trailer: metadata.MD{},
+	}
+}
+
+// grpcHTTPStream is grpc.ClientStream over HTTP.
+type grpcHTTPStream struct {
+	ctx     context.Context
+	conn    *grpcHTTPConn
+	method  string
+	unary   bool
+	reqBody bytes.Buffer
+	sent    bool
+	body    io.ReadCloser
+	br      *bufio.Reader
+	// Response message of unary RPC using Connect protocol ( not enveloped ).
+	unaryMsg []byte
+	header   metadata.MD
+	trailer  metadata.MD
+	// err is the error returned after the end of the stream ( io.EOF or status error ).
+	err error
+}
+
+func (s *grpcHTTPStream) Header() (metadata.MD, error) {
+	if err := s.send(); err != nil {
+		return nil, err
+	}
+	return s.header, nil
+}
+
+func (s *grpcHTTPStream) Trailer() metadata.MD {
+	return s.trailer
+}
+
+func (s *grpcHTTPStream) CloseSend() error {
+	return s.send()
+}
+
+func (s *grpcHTTPStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *grpcHTTPStream) SendMsg(m any) error {
+	if s.sent {
+		return fmt.Errorf("cannot send messages after receiving messages using %s protocol ( half-duplex )", s.conn.protocol)
+	}
+	pm, ok := m.(proto.Message)
+	if !ok {
+		return fmt.Errorf("invalid message: %T", m)
+	}
+	b, err := proto.Marshal(pm)
+	if err != nil {
+		return err
+	}
+	if s.isConnectUnary() {
+		s.reqBody.Reset()
+		_, _ = s.reqBody.Write(b)
+		return nil
+	}
+	writeEnvelope(&s.reqBody, 0, b)
+	return nil
+}
+
+func (s *grpcHTTPStream) RecvMsg(m any) error {
+	b, err := s.recv()
+	if err != nil {
+		return err
+	}
+	pm, ok := m.(proto.Message)
+	if !ok {
+		return fmt.Errorf("invalid message: %T", m)
+	}
+	if err := proto.Unmarshal(b, pm); err != nil {
+		return status.Errorf(codes.Internal, "failed to unmarshal response message: %v", err)
+	}
+	return nil
+}
+
+func (s *grpcHTTPStream) isConnectUnary() bool {
+	return s.conn.protocol == grpcProtocolConnect && s.unary
+}
+
+func (s *grpcHTTPStream) setCallOptions(opts []grpc.CallOption) {
+	for _, o := range opts {
+		switch v := o.(type) {
+		case grpc.HeaderCallOption:
+			*v.HeaderAddr = s.header
+		case grpc.TrailerCallOption:
+			*v.TrailerAddr = s.trailer
+		}
+	}
+}
+
+// send sends the request with buffered messages if not sent yet.
+func (s *grpcHTTPStream) send() error {
+	if s.sent {
+		return nil
+	}
+	s.sent = true
+	u := *s.conn.endpoint
+	u.Path += s.method
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, u.String(), bytes.NewReader(s.reqBody.Bytes()))
+	if err != nil {
+		return err
+	}
+	if md, ok := metadata.FromOutgoingContext(s.ctx); ok {
+		for k, vs := range md {
+			for _, v := range vs {
+				if strings.HasSuffix(k, "-bin") {
+					v = base64.RawStdEncoding.EncodeToString([]byte(v))
+				}
+				req.Header.Add(k, v)
+			}
+		}
+	}
+	req.Header.Set("User-Agent", fmt.Sprintf("runn/%s", version.Version))
+	deadline, hasDeadline := s.ctx.Deadline()
+	switch {
+	case s.conn.protocol == grpcProtocolGRPCWeb:
+		req.Header.Set("Content-Type", grpcWebContentType)
+		req.Header.Set("Accept", grpcWebContentType)
+		req.Header.Set("X-Grpc-Web", "1")
+		if hasDeadline {
+			req.Header.Set("Grpc-Timeout", fmt.Sprintf("%dm", timeoutMillis(deadline)))
+		}
+	case s.isConnectUnary():
+		req.Header.Set("Content-Type", connectUnaryContentType)
+		req.Header.Set("Connect-Protocol-Version", "1")
+		if hasDeadline {
+			req.Header.Set("Connect-Timeout-Ms", strconv.FormatInt(timeoutMillis(deadline), 10))
+		}
+	default:
+		req.Header.Set("Content-Type", connectStreamingContentType)
+		req.Header.Set("Connect-Protocol-Version", "1")
+		if hasDeadline {
+			req.Header.Set("Connect-Timeout-Ms", strconv.FormatInt(timeoutMillis(deadline), 10))
+		}
+	}
+	res, err := s.conn.client.Do(req)
+	if err != nil {
+		// Errors of the connection are returned when receiving messages as well as gRPC.
+		s.err = toStatusError(err)
+		return nil
+	}
+	s.header = toMetadata(res.Header, "")
+	switch {
+	case s.conn.protocol == grpcProtocolGRPCWeb:
+		if res.StatusCode != http.StatusOK {
+			s.finish(res.Body, status.Errorf(httpStatusToCode(res.StatusCode), "unexpected HTTP status: %s", res.Status))
+			return nil
+		}
+		if res.Header.Get("Grpc-Status") != "" {
+			// Trailers-Only response
+			s.trailer = s.header
+			s.header = metadata.MD{}
+			s.finish(res.Body, grpcWebStatus(res.Header))
+			return nil
+		}
+	case s.isConnectUnary():
+		s.trailer = toMetadata(res.Header, "Trailer-")
+		for k := range s.header {
+			if strings.HasPrefix(k, "trailer-") {
+				delete(s.header, k)
+			}
+		}
+		b, err := io.ReadAll(res.Body)
+		_ = res.Body.Close()
+		if err != nil {
+			s.err = toStatusError(err)
+			return nil
+		}
+		if res.StatusCode != http.StatusOK {
+			s.err = connectError(res.StatusCode, b)
+			return nil
+		}
+		s.unaryMsg = b
+		return nil
+	default:
+		if res.StatusCode != http.StatusOK {
+			b, _ := io.ReadAll(res.Body)
+			s.finish(res.Body, connectError(res.StatusCode, b))
+			return nil
+		}
+	}
+	s.body = res.Body
+	s.br = bufio.NewReader(res.Body)
+	return nil
+}
+
+// recv returns the next response message.
+func (s *grpcHTTPStream) recv() ([]byte, error) {
+	if err := s.send(); err != nil {
+		return nil, err
+	}
+	if s.unaryMsg != nil {
+		b := s.unaryMsg
+		s.unaryMsg = nil
+		s.err = io.EOF
+		return b, nil
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.br == nil {
+		s.err = io.EOF
+		return nil, s.err
+	}
+	flags, b, err := readEnvelope(s.br)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = status.Error(codes.Internal, "the stream ended without status")
+		}
+		s.finish(s.body, toStatusError(err))
+		return nil, s.err
+	}
+	switch {
+	case s.conn.protocol == grpcProtocolGRPCWeb && flags&grpcWebTrailerFlag != 0:
+		h, err := parseGRPCWebTrailer(b)
+		if err != nil {
+			s.finish(s.body, status.Errorf(codes.Internal, "invalid trailer: %v", err))
+			return nil, s.err
+		}
+		s.trailer = toMetadata(h, "")
+		s.finish(s.body, grpcWebStatus(h))
+		return nil, s.err
+	case s.conn.protocol == grpcProtocolConnect && flags&connectEndStreamFlag != 0:
+		trailer, err := parseConnectEndStream(b)
+		s.trailer = trailer
+		if err == nil {
+			err = io.EOF
+		}
+		s.finish(s.body, err)
+		return nil, s.err
+	case flags&compressedFlag != 0:
+		s.finish(s.body, status.Error(codes.Internal, "compressed response messages are not supported"))
+		return nil, s.err
+	}
+	return b, nil
+}
+
+// finish ends the stream with the error ( io.EOF or status error ).
+func (s *grpcHTTPStream) finish(body io.ReadCloser, err error) {
+	if body != nil {
+		_, _ = io.Copy(io.Discard, body)
+		_ = body.Close()
+	}
+	s.body = nil
+	s.br = nil
+	s.err = err
+}
+
+func writeEnvelope(w *bytes.Buffer, flags byte, b []byte) {
+	var prefix [5]byte
+	prefix[0] = flags
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(b))) //nolint:gosec
+	_, _ = w.Write(prefix[:])
+	_, _ = w.Write(b)
+}
+
+func readEnvelope(r io.Reader) (byte, []byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, nil, status.Error(codes.Internal, "invalid message frame")
+		}
+		return 0, nil, err
+	}
+	b := make([]byte, binary.BigEndian.Uint32(prefix[1:]))
+	if _, err := io.ReadFull(r, b); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, nil, status.Error(codes.Internal, "invalid message frame")
+		}
+		return 0, nil, err
+	}
+	return prefix[0], b, nil
+}
+
+// parseGRPCWebTrailer parses the trailer frame of gRPC-Web that is in the format of HTTP/1 headers.
+func parseGRPCWebTrailer(b []byte) (http.Header, error) {
+	h := http.Header{}
+	for _, l := range strings.Split(string(b), "\r\n") {
+		if l == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(l, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid line: %q", l)
+		}
+		h.Add(strings.TrimSpace(k), strings.TrimSpace(v))
+	}
+	return h, nil
+}
+
+// grpcWebStatus returns the error of the status in headers or trailers ( io.EOF if OK ).
+func grpcWebStatus(h http.Header) error {
+	c, err := strconv.Atoi(h.Get("Grpc-Status"))
+	if err != nil {
+		return status.Errorf(codes.Internal, "invalid grpc-status: %q", h.Get("Grpc-Status"))
+	}
+	if codes.Code(c) == codes.OK { //nolint:gosec
+		return io.EOF
+	}
+	msg := h.Get("Grpc-Message")
+	if m, err := url.PathUnescape(msg); err == nil {
+		msg = m
+	}
+	if v := h.Get("Grpc-Status-Details-Bin"); v != "" {
+		if b, err := decodeBinHeader(v); err == nil {
+			st := &spb.Status{}
+			if err := proto.Unmarshal(b, st); err == nil && st.GetCode() == int32(c) { //nolint:gosec
+				return status.ErrorProto(st)
+			}
+		}
+	}
+	return status.Error(codes.Code(c), msg) //nolint:gosec
+}
+
+type connectWireError struct {
+	Code    string                    `json:"code"`
+	Message string                    `json:"message"`
+	Details []*connectWireErrorDetail `json:"details"`
+}
+
+type connectWireErrorDetail struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// connectError returns the error of the response of Connect protocol with the HTTP status other than 200.
+func connectError(statusCode int, b []byte) error {
+	e := &connectWireError{}
+	if err := json.Unmarshal(b, e); err != nil || e.Code == "" {
+		return status.Errorf(httpStatusToCode(statusCode), "unexpected HTTP status: %d %s", statusCode, http.StatusText(statusCode))
+	}
+	return e.status()
+}
+
+func (e *connectWireError) status() error {
+	c, ok := connectCodes[e.Code]
+	if !ok {
+		c = codes.Unknown
+	}
+	st := &spb.Status{
+		Code:    int32(c), //nolint:gosec
+		Message: e.Message,
+	}
+	for _, d := range e.Details {
+		b, err := decodeBinHeader(d.Value)
+		if err != nil {
+			continue
+		}
+		st.Details = append(st.Details, &anypb.Any{
+			TypeUrl: "type.googleapis.com/" + d.Type,
+			Value:   b,
+		})
+	}
+	return status.ErrorProto(st)
+}
+
+// parseConnectEndStream parses the end-stream message of Connect protocol and returns trailers and the error.
+func parseConnectEndStream(b []byte) (metadata.MD, error) {
+	end := struct {
+		Error    *connectWireError   `json:"error"`
+		Metadata map[string][]string `json:"metadata"`
+	}{}
+	if err := json.Unmarshal(b, &end); err != nil {
+		return metadata.MD{}, status.Errorf(codes.Internal, "invalid end-stream message: %v", err)
+	}
+	h := http.Header{}
+	for k, vs := range end.Metadata {
+		for _, v := range vs {
+			h.Add(k, v)
+		}
+	}
+	t := toMetadata(h, "")
+	if end.Error != nil {
+		return t, end.Error.status()
+	}
+	return t, nil
+}
+
+// toMetadata converts HTTP headers ( with the prefix, if specified ) to metadata except reserved headers.
+func toMetadata(h http.Header, prefix string) metadata.MD {
+	md := metadata.MD{}
+	for k, vs := range h {
+		if prefix != "" {
+			if !strings.HasPrefix(k, prefix) {
+				continue
+			}
+			k = strings.TrimPrefix(k, prefix)
+		}
+		k = strings.ToLower(k)
+		if _, ok := grpcHTTPReservedHeaders[k]; ok {
+			continue
+		}
+		for _, v := range vs {
+			if strings.HasSuffix(k, "-bin") {
+				if b, err := decodeBinHeader(v); err == nil {
+					v = string(b)
+				}
+			}
+			md.Append(k, v)
+		}
+	}
+	return md
+}
+
+func decodeBinHeader(v string) ([]byte, error) {
+	if len(v)%4 == 0 {
+		return base64.StdEncoding.DecodeString(v)
+	}
+	return base64.RawStdEncoding.DecodeString(v)
+}
+
+// httpStatusToCode maps the HTTP status to the status code when the response has no status of gRPC.
+// ref: https://github.com/grpc/grpc/blob/master/doc/http-grpc-status-mapping.md
+func httpStatusToCode(s int) codes.Code {
+	switch s {
+	case http.StatusBadRequest:
+		return codes.Internal
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.Unimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return codes.Unavailable
+	default:
+		return codes.Unknown
+	}
+}
+
+// toStatusError converts errors of HTTP requests to status errors in the same way as gRPC.
+func toStatusError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	default:
+		return status.Error(codes.Unavailable, err.Error())
+	}
+}
+
+func timeoutMillis(deadline time.Time) int64 {
+	ms := time.Until(deadline).Milliseconds()
+	if ms < 1 {
+		return 1
+	}
+	return ms
+}
@@ -0,0 +1,289 @@
+package runn
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestGrpcRunnerWithHTTPProtocols(t *testing.T) {
+	ts := httptest.NewServer(newGRPCHTTPTestHandler(t))
+	t.Cleanup(ts.Close)
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := healthProtoset(t)
+
+	tests := []struct {
+		name         string
+		protocol     string
+		target       string
+		method       string
+		service      string
+		wantStatus   int64
+		wantMessages []map[string]any
+		wantMessage  any
+	}{
+		{"grpcweb unary", grpcProtocolGRPCWeb, u.Host, "Check", "", 0, []map[string]any{{"status": float64(1)}}, map[string]any{"status": float64(1)}},
+		{"grpcweb unary error", grpcProtocolGRPCWeb, u.Host, "Check", "unknown", 5, nil, "unknown service: unknown"},
+		{"grpcweb server streaming", grpcProtocolGRPCWeb, u.Host, "Watch", "", 0, []map[string]any{{"status": float64(1)}, {"status": float64(2)}}, map[string]any{"status": float64(2)}},
+		{"grpcweb server streaming error", grpcProtocolGRPCWeb, u.Host, "Watch", "unknown", 5, nil, "unknown service: unknown"},
+		{"connect unary", grpcProtocolConnect, ts.URL, "Check", "", 0, []map[string]any{{"status": float64(1)}}, map[string]any{"status": float64(1)}},
+		{"connect unary error", grpcProtocolConnect, ts.URL, "Check", "unknown", 5, nil, "unknown service: unknown"},
+		{"connect server streaming", grpcProtocolConnect, ts.URL, "Watch", "", 0, []map[string]any{{"status": float64(1)}, {"status": float64(2)}}, map[string]any{"status": float64(2)}},
+		{"connect server streaming error", grpcProtocolConnect, ts.URL, "Watch", "unknown", 5, nil, "unknown service: unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := New(GrpcRunnerWithOptions("greq", tt.target, TLS(false), Protosets([]string{p}), GRPCProtocol(tt.protocol)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, ok := o.grpcRunners["greq"]
+			if !ok {
+				t.Fatal("grpc runner not found")
+			}
+			r.operator = o
+			t.Cleanup(func() {
+				_ = r.Close()
+			})
+			req := &grpcRequest{
+				service: "grpc.health.v1.Health",
+				method:  tt.method,
+				headers: metadata.MD{"x-test-header": []string{"hello"}},
+				messages: []*grpcMessage{
+					{
+						op:     GRPCOpMessage,
+						params: map[string]any{"service": tt.service},
+					},
+				},
+			}
+			if err := r.Run(context.Background(), req); err != nil {
+				t.Fatal(err)
+			}
+			res, ok := o.store.latest()[grpcStoreResponseKey].(map[string]any)
+			if !ok {
+				t.Fatalf("invalid res: %#v", o.store.latest()[grpcStoreResponseKey])
+			}
+			var got int64
+			switch v := res[grpcStoreStatusKey].(type) {
+			case int:
+				got = int64(v)
+			case int64:
+				got = v
+			}
+			if got != tt.wantStatus {
+				t.Errorf("got %v\nwant %v", got, tt.wantStatus)
+			}
+			if diff := cmp.Diff(res[grpcStoreMessageKey], tt.wantMessage); diff != "" {
+				t.Error(diff)
+			}
+			if tt.wantMessages != nil {
+				if diff := cmp.Diff(res[grpcStoreMessagesKey], tt.wantMessages); diff != "" {
+					t.Error(diff)
+				}
+			}
+			if diff := cmp.Diff(res[grpcStoreHeaderKey].(metadata.MD).Get("x-test-header"), []string{"hello"}); diff != "" {
+				t.Error(diff)
+			}
+			if diff := cmp.Diff(res[grpcStoreTrailerKey].(metadata.MD).Get("x-test-trailer"), []string{"bye"}); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestGrpcRunnerWithHTTPProtocolWithoutProtos(t *testing.T) {
+	o, err := New(GrpcRunnerWithOptions("greq", "127.0.0.1:1", TLS(false), GRPCProtocol(grpcProtocolConnect)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := o.grpcRunners["greq"]
+	r.operator = o
+	req := &grpcRequest{
+		service:  "grpc.health.v1.Health",
+		method:   "Check",
+		headers:  metadata.MD{},
+		messages: []*grpcMessage{{op: GRPCOpMessage, params: map[string]any{}}},
+	}
+	if err := r.Run(context.Background(), req); err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestParseRunnerWithGRPCProtocol(t *testing.T) {
+	tests := []struct {
+		protocol string
+		wantErr  bool
+	}{
+		{"", false},
+		{"grpc", false},
+		{"grpcweb", false},
+		{"connect", false},
+		{"grpc-web", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.protocol, func(t *testing.T) {
+			bk := newBook()
+			if err := bk.parseRunner("greq", map[string]any{
+				"addr":     "grpc.example.com:443",
+				"protocol": tt.protocol,
+			}); err != nil {
+				if !tt.wantErr {
+					t.Error(err)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Error("want error")
+				return
+			}
+			if got := bk.grpcRunners["greq"].protocol; got != tt.protocol {
+				t.Errorf("got %v\nwant %v", got, tt.protocol)
+			}
+		})
+	}
+}
+
+func TestGRPCHTTPStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+		want       string
+	}{
+		{"connect error", http.StatusNotFound, `{"code":"not_found","message":"not found"}`, "rpc error: code = NotFound desc = not found"},
+		{"unknown connect code", http.StatusBadRequest, `{"code":"strange","message":"strange"}`, "rpc error: code = Unknown desc = strange"},
+		{"not connect error", http.StatusBadGateway, `<html></html>`, "rpc error: code = Unavailable desc = unexpected HTTP status: 502 Bad Gateway"},
+		{"unauthorized", http.StatusUnauthorized, ``, "rpc error: code = Unauthenticated desc = unexpected HTTP status: 401 Unauthorized"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := connectError(tt.statusCode, []byte(tt.body)).Error()
+			if got != tt.want {
+				t.Errorf("got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func healthProtoset(t *testing.T) string {
+	t.Helper()
+	set := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(healthpb.File_grpc_health_v1_health_proto)},
+	}
+	b, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := filepath.Join(t.TempDir(), "health.protoset")
+	if err := os.WriteFile(p, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// newGRPCHTTPTestHandler returns the handler of grpc.health.v1.Health using gRPC-Web and Connect protocol.
+// Check returns SERVING, and Watch returns SERVING and NOT_SERVING. Both return NotFound for the service "unknown".
+func newGRPCHTTPTestHandler(t *testing.T) http.Handler {
+	t.Helper()
+	handle := func(w http.ResponseWriter, r *http.Request, res []*healthpb.HealthCheckResponse) {
+		ct := r.Header.Get("Content-Type")
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if ct != connectUnaryContentType {
+			_, b, err = readEnvelope(bytes.NewReader(b))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		req := &healthpb.HealthCheckRequest{}
+		if err := proto.Unmarshal(b, req); err != nil {
+			t.Error(err)
+			return
+		}
+		w.Header().Set("X-Test-Header", r.Header.Get("X-Test-Header"))
+		var errMsg string
+		if req.GetService() == "unknown" {
+			errMsg = "unknown service: unknown"
+			res = nil
+		}
+		buf := new(bytes.Buffer)
+		switch ct {
+		case grpcWebContentType:
+			w.Header().Set("Content-Type", grpcWebContentType)
+			for _, m := range res {
+				mb, _ := proto.Marshal(m)
+				writeEnvelope(buf, 0, mb)
+			}
+			trailer := "grpc-status: 0\r\nx-test-trailer: bye\r\n"
+			if errMsg != "" {
+				trailer = fmt.Sprintf("grpc-status: 5\r\ngrpc-message: %s\r\nx-test-trailer: bye\r\n", url.PathEscape(errMsg))
+			}
+			writeEnvelope(buf, grpcWebTrailerFlag, []byte(trailer))
+		case connectUnaryContentType:
+			w.Header().Set("Trailer-X-Test-Trailer", "bye")
+			if errMsg != "" {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = fmt.Fprintf(w, `{"code":"not_found","message":%q}`, errMsg)
+				return
+			}
+			w.Header().Set("Content-Type", connectUnaryContentType)
+			mb, _ := proto.Marshal(res[0])
+			buf.Write(mb)
+		case connectStreamingContentType:
+			w.Header().Set("Content-Type", connectStreamingContentType)
+			for _, m := range res {
+				mb, _ := proto.Marshal(m)
+				writeEnvelope(buf, 0, mb)
+			}
+			end := `{"metadata":{"x-test-trailer":["bye"]}}`
+			if errMsg != "" {
+				end = fmt.Sprintf(`{"error":{"code":"not_found","message":%q},"metadata":{"x-test-trailer":["bye"]}}`, errMsg)
+			}
+			writeEnvelope(buf, connectEndStreamFlag, []byte(end))
+		default:
+			t.Errorf("invalid content-type: %s", ct)
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		_, _ = w.Write(buf.Bytes())
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/grpc.health.v1.Health/Check", func(w http.ResponseWriter, r *http.Request) {
+		handle(w, r, []*healthpb.HealthCheckResponse{
+			{Status: healthpb.HealthCheckResponse_SERVING},
+		})
+	})
+	mux.HandleFunc("/grpc.health.v1.Health/Watch", func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc-web") && r.Header.Get("Content-Type") != connectStreamingContentType {
+			t.Errorf("invalid content-type for streaming: %s", r.Header.Get("Content-Type"))
+		}
+		handle(w, r, []*healthpb.HealthCheckResponse{
+			{Status: healthpb.HealthCheckResponse_SERVING},
+			{Status: healthpb.HealthCheckResponse_NOT_SERVING},
+		})
+	})
+	return mux
+}
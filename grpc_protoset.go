@@ -0,0 +1,179 @@
+package runn
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func (rnr *grpcRunner) resolveAllMethodsUsingProtosets() error {
+	paths, err := fetchPaths(strings.Join(rnr.protosets, string(os.PathListSeparator)))
+	if err != nil {
+		return err
+	}
+	fds, err := loadProtosets(paths...)
+	if err != nil {
+		return err
+	}
+	if err := registerFiles(fds); err != nil {
+		return err
+	}
+	for _, fd := range fds {
+		rnr.addMethods(fd)
+	}
+	return nil
+}
+
+// loadProtosets loads file descriptors from FileDescriptorSet files ( protoset, e.g. `protoc --descriptor_set_out` ) and buf images ( e.g. `buf build -o` ).
+// Binary ( default ) and JSON ( .json ) encodings are supported, optionally compressed with gzip ( .gz ) or zstd ( .zst ).
+func loadProtosets(paths ...string) ([]protoreflect.FileDescriptor, error) {
+	var sets []*descriptorpb.FileDescriptorSet
+	for _, p := range paths {
+		set, err := readProtoset(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load protoset %s: %w", p, err)
+		}
+		sets = append(sets, set)
+	}
+	return buildFileDescriptorSets(sets...)
+}
+
+// buildFileDescriptorSets builds file descriptors from FileDescriptorSets. Files with the same name are built only once.
+func buildFileDescriptorSets(sets ...*descriptorpb.FileDescriptorSet) ([]protoreflect.FileDescriptor, error) {
+	fdps := map[string]*descriptorpb.FileDescriptorProto{}
+	var names []string
+	for _, set := range sets {
+		for _, fdp := range set.GetFile() {
+			if _, ok := fdps[fdp.GetName()]; ok {
+				continue
+			}
+			fdps[fdp.GetName()] = fdp
+			names = append(names, fdp.GetName())
+		}
+	}
+	r := &protosetResolver{
+		fdps:  fdps,
+		files: new(protoregistry.Files),
+	}
+	var fds []protoreflect.FileDescriptor
+	for _, name := range names {
+		fd, err := r.build(name)
+		if err != nil {
+			return nil, err
+		}
+		fds = append(fds, fd)
+	}
+	return fds, nil
+}
+
+func readProtoset(p string) (*descriptorpb.FileDescriptorSet, error) {
+	b, err := os.ReadFile(filepath.Clean(p))
+	if err != nil {
+		return nil, err
+	}
+	ext := strings.ToLower(filepath.Ext(p))
+	switch ext {
+	case ".gz":
+		zr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		b, err = io.ReadAll(zr)
+		if err != nil {
+			return nil, err
+		}
+		ext = strings.ToLower(filepath.Ext(strings.TrimSuffix(p, filepath.Ext(p))))
+	case ".zst":
+		zr, err := zstd.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		b, err = io.ReadAll(zr)
+		if err != nil {
+			return nil, err
+		}
+		ext = strings.ToLower(filepath.Ext(strings.TrimSuffix(p, filepath.Ext(p))))
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	if ext == ".json" {
+		// buf images have fields not in FileDescriptorSet ( e.g. buf_extensions )
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(b, set); err != nil {
+			return nil, err
+		}
+		return set, nil
+	}
+	// buf images in binary are wire compatible with FileDescriptorSet
+	if err := proto.Unmarshal(b, set); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// protosetResolver builds file descriptors in the order of dependencies.
+// Dependencies not contained in protosets ( e.g. well-known types ) are resolved from protoregistry.GlobalFiles.
+type protosetResolver struct {
+	fdps  map[string]*descriptorpb.FileDescriptorProto
+	files *protoregistry.Files
+}
+
+func (r *protosetResolver) build(name string) (protoreflect.FileDescriptor, error) {
+	if fd, err := r.files.FindFileByPath(name); err == nil {
+		return fd, nil
+	}
+	fdp, ok := r.fdps[name]
+	if !ok {
+		return protoregistry.GlobalFiles.FindFileByPath(name)
+	}
+	// Prevent infinite recursion on circular imports
+	delete(r.fdps, name)
+	for _, dep := range fdp.GetDependency() {
+		if _, err := r.build(dep); err != nil {
+			return nil, fmt.Errorf("failed to resolve %s imported by %s: %w", dep, name, err)
+		}
+	}
+	fd, err := protodesc.NewFile(fdp, r)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.files.RegisterFile(fd); err != nil {
+		return nil, err
+	}
+	return fd, nil
+}
+
+func (r *protosetResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	fd, err := r.files.FindFileByPath(path)
+	if err == nil {
+		return fd, nil
+	}
+	if !errors.Is(err, protoregistry.NotFound) {
+		return nil, err
+	}
+	return protoregistry.GlobalFiles.FindFileByPath(path)
+}
+
+func (r *protosetResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	d, err := r.files.FindDescriptorByName(name)
+	if err == nil {
+		return d, nil
+	}
+	if !errors.Is(err, protoregistry.NotFound) {
+		return nil, err
+	}
+	return protoregistry.GlobalFiles.FindDescriptorByName(name)
+}
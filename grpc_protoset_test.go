@@ -0,0 +1,220 @@
+package runn
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestLoadProtosets(t *testing.T) {
+	b, err := os.ReadFile("testdata/grpctest.protoset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(b, set); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	write := func(t *testing.T, name string, b []byte) string {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, b, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	// buf image in JSON has fields not in FileDescriptorSet
+	jb, err := protojson.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var image map[string]any
+	if err := json.Unmarshal(jb, &image); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range image["file"].([]any) {
+		f.(map[string]any)["bufExtensions"] = map[string]any{"isImport": false}
+	}
+	jb, err = json.Marshal(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gz := new(bytes.Buffer)
+	gw := gzip.NewWriter(gz)
+	if _, err := gw.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zw, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zst := zw.EncodeAll(jb, nil)
+	_ = zw.Close()
+
+	// protoset without imports ( google/protobuf/timestamp.proto )
+	var withoutImports *descriptorpb.FileDescriptorSet
+	for _, f := range set.GetFile() {
+		if f.GetName() == "grpctest.proto" {
+			withoutImports = &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{f}}
+		}
+	}
+	wb, err := proto.Marshal(withoutImports)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{"binary", "testdata/grpctest.protoset", false},
+		{"buf image json", write(t, "image.json", jb), false},
+		{"gzip", write(t, "grpctest.protoset.gz", gz.Bytes()), false},
+		{"zstd json", write(t, "image.json.zst", zst), false},
+		{"without imports", write(t, "grpctest_without_imports.protoset", wb), false},
+		{"invalid", write(t, "invalid.protoset", []byte("invalid")), true},
+		{"not found", filepath.Join(dir, "notfound.protoset"), true},
+	}
+	want := []string{
+		"grpctest.GrpcTestService/Hello",
+		"grpctest.GrpcTestService/HelloChat",
+		"grpctest.GrpcTestService/ListHello",
+		"grpctest.GrpcTestService/MultiHello",
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fds, err := loadProtosets(tt.path)
+			if err != nil {
+				if !tt.wantErr {
+					t.Error(err)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Error("want error")
+			}
+			var got []string
+			for _, fd := range fds {
+				for i := 0; i < fd.Services().Len(); i++ {
+					svc := fd.Services().Get(i)
+					for j := 0; j < svc.Methods().Len(); j++ {
+						got = append(got, string(svc.FullName())+"/"+string(svc.Methods().Get(j).Name()))
+					}
+				}
+			}
+			sort.Strings(got)
+			if diff := cmp.Diff(got, want); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestParseRunnerWithProtosets(t *testing.T) {
+	bk := newBook()
+	if err := bk.parseRunner("greq", map[string]any{
+		"addr":      "grpc.example.com:443",
+		"protosets": []any{"testdata/grpctest.protoset"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	r, ok := bk.grpcRunners["greq"]
+	if !ok {
+		t.Fatal("grpc runner not found")
+	}
+	if diff := cmp.Diff(r.protosets, []string{"testdata/grpctest.protoset"}); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestGrpcRunnerWithProtosetWithoutReflection(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go func() {
+		_ = s.Serve(l)
+	}()
+	t.Cleanup(s.Stop)
+
+	set := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(healthpb.File_grpc_health_v1_health_proto)},
+	}
+	b, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := filepath.Join(t.TempDir(), "health.protoset")
+	if err := os.WriteFile(p, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := New(GrpcRunnerWithOptions("greq", l.Addr().String(), TLS(false), Protosets([]string{p})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, ok := o.grpcRunners["greq"]
+	if !ok {
+		t.Fatal("grpc runner not found")
+	}
+	r.operator = o
+	t.Cleanup(func() {
+		_ = r.Close()
+	})
+	req := &grpcRequest{
+		service: "grpc.health.v1.Health",
+		method:  "Check",
+		headers: metadata.MD{},
+		messages: []*grpcMessage{
+			{
+				op:     GRPCOpMessage,
+				params: map[string]any{},
+			},
+		},
+	}
+	if err := r.Run(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	res, ok := o.store.latest()[grpcStoreResponseKey].(map[string]any)
+	if !ok {
+		t.Fatalf("invalid res: %#v", o.store.latest()[grpcStoreResponseKey])
+	}
+	if got := res[grpcStoreStatusKey]; got != 0 {
+		t.Errorf("got %v\nwant %v", got, 0)
+	}
+
+	methods, err := GRPCMethods(context.Background(), l.Addr().String(), []Option{GRPCNoTLS(true), GRPCProtosets([]string{p})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(methods) == 0 {
+		t.Error("want methods")
+	}
+}
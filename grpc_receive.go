@@ -0,0 +1,190 @@
+package runn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/k1LoW/duration"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	grpcReceiveUntilKey   = "until"
+	grpcReceiveCountKey   = "count"
+	grpcReceiveTimeoutKey = "timeout"
+)
+
+// grpcReceive is the condition to stop receiving messages from the stream.
+// Receiving stops when the `until` condition holds, `count` messages are received or the stream ends.
+type grpcReceive struct {
+	until   string
+	count   int
+	timeout time.Duration
+}
+
+func parseGrpcReceive(v any, expand func(any) (any, error)) (*grpcReceive, error) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid receive: %v", v)
+	}
+	rc := &grpcReceive{}
+	for k, vv := range m {
+		switch k {
+		case grpcReceiveUntilKey:
+			// `until:` is evaluated against each received message so not here
+			s, ok := vv.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid receive.until: %v", vv)
+			}
+			rc.until = s
+		case grpcReceiveCountKey:
+			e, err := expand(vv)
+			if err != nil {
+				return nil, err
+			}
+			switch c := e.(type) {
+			case int:
+				rc.count = c
+			case int64:
+				rc.count = int(c)
+			case uint64:
+				rc.count = int(c) //nolint:gosec
+			case float64:
+				rc.count = int(c)
+			case string:
+				rc.count, err = strconv.Atoi(c)
+				if err != nil {
+					return nil, fmt.Errorf("invalid receive.count: %w", err)
+				}
+			default:
+				return nil, fmt.Errorf("invalid receive.count: %v", e)
+			}
+			if rc.count < 0 {
+				return nil, fmt.Errorf("invalid receive.count: %d", rc.count)
+			}
+		case grpcReceiveTimeoutKey:
+			e, err := expand(vv)
+			if err != nil {
+				return nil, err
+			}
+			s, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid receive.timeout: %v", e)
+			}
+			rc.timeout, err = duration.Parse(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid receive.timeout: %w", err)
+			}
+		default:
+			return nil, fmt.Errorf("invalid receive: unknown key %q", k)
+		}
+	}
+	return rc, nil
+}
+
+// receiveWithCondition receives messages from the stream until the condition of the receive holds.
+// cancel cancels the stream when the timeout is exceeded.
+// It returns the received messages and whether the stream has ended.
+func (rnr *grpcRunner) receiveWithCondition(stream grpc.ClientStream, md protoreflect.MethodDescriptor, rc *grpcReceive, cancel context.CancelFunc, d map[string]any, messages []map[string]any) ([]map[string]any, bool, error) {
+	var timedOut atomic.Bool
+	if rc.timeout > 0 {
+		t := time.AfterFunc(rc.timeout, func() {
+			timedOut.Store(true)
+			cancel()
+		})
+		defer t.Stop()
+	}
+	received := 0
+	for {
+		res := dynamicpb.NewMessage(md.Output())
+		err := stream.RecvMsg(res)
+		if err != nil && timedOut.Load() {
+			return messages, true, rnr.receiveTimeoutError(rc, received, d, messages)
+		}
+		if errors.Is(err, io.EOF) {
+			return messages, true, nil
+		}
+		stat, ok := status.FromError(err)
+		if !ok {
+			return messages, true, err
+		}
+		d[grpcStoreStatusKey] = int64(stat.Code())
+
+		rnr.operator.capturers.captureGRPCResponseStatus(stat)
+
+		if stat.Code() != codes.OK {
+			d[grpcStoreMessageKey] = stat.Message()
+			d[grpcStoreDetailsKey] = grpcStatusDetails(stat)
+			return messages, true, nil
+		}
+		b, err := protojson.MarshalOptions{UseProtoNames: true, UseEnumNumbers: true, EmitUnpopulated: true}.Marshal(res)
+		if err != nil {
+			return messages, true, err
+		}
+		var msg map[string]any
+		if err := json.Unmarshal(b, &msg); err != nil {
+			return messages, true, err
+		}
+		d[grpcStoreMessageKey] = msg
+
+		rnr.operator.capturers.captureGRPCResponseMessage(msg)
+
+		messages = append(messages, msg)
+		received++
+		if rc.count > 0 && received >= rc.count {
+			return messages, false, nil
+		}
+		if rc.until != "" {
+			tf, err := EvalCond(rc.until, rnr.receiveStore(d, messages))
+			if err != nil {
+				return messages, true, fmt.Errorf("receive failed: %w", err)
+			}
+			if tf {
+				return messages, false, nil
+			}
+		}
+	}
+}
+
+// receiveStore returns the store to evaluate the condition of the receive, in which `current.res` has the response received so far.
+func (rnr *grpcRunner) receiveStore(d map[string]any, messages []map[string]any) map[string]any {
+	res := map[string]any{}
+	for k, v := range d {
+		res[k] = v
+	}
+	res[grpcStoreMessagesKey] = messages
+	store := rnr.operator.store.toMap()
+	store[storeIncludedKey] = rnr.operator.included
+	store[storePreviousKey] = rnr.operator.store.latest()
+	store[storeCurrentKey] = map[string]any{
+		grpcStoreResponseKey: res,
+	}
+	return store
+}
+
+func (rnr *grpcRunner) receiveTimeoutError(rc *grpcReceive, received int, d map[string]any, messages []map[string]any) error {
+	switch {
+	case rc.until != "":
+		bt, err := buildTree(rc.until, rnr.receiveStore(d, messages))
+		if err != nil {
+			return fmt.Errorf("receive timeout (%v) exceeded: %w", rc.timeout, err)
+		}
+		return fmt.Errorf("receive timeout (%v) exceeded: (%s) is not true\n%s", rc.timeout, rc.until, bt)
+	case rc.count > 0:
+		return fmt.Errorf("receive timeout (%v) exceeded: received %d of %d messages", rc.timeout, received, rc.count)
+	default:
+		// Without conditions, the timeout is the time to receive messages
+		return nil
+	}
+}
@@ -0,0 +1,205 @@
+package runn
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestGrpcRunnerReceiveWithCondition(t *testing.T) {
+	target, p := watchServer(t)
+	tests := []struct {
+		name         string
+		method       string
+		receive      *grpcReceive
+		bidi         bool
+		wantMessages int
+		wantErr      string
+	}{
+		{"count", "Watch", &grpcReceive{count: 3}, false, 3, ""},
+		{"until message", "Watch", &grpcReceive{until: "current.res.message.status == 2"}, false, 2, ""},
+		{"until messages", "Watch", &grpcReceive{until: "len(current.res.messages) >= 4"}, false, 4, ""},
+		{"count and until", "Watch", &grpcReceive{count: 5, until: "len(current.res.messages) >= 4"}, false, 4, ""},
+		{"until not true", "Watch", &grpcReceive{until: "current.res.message.status == 3", timeout: 100 * time.Millisecond}, false, 0, "receive timeout (100ms) exceeded: (current.res.message.status == 3) is not true"},
+		{"count not reached", "Watch", &grpcReceive{count: 10000, timeout: 100 * time.Millisecond}, false, 0, "receive timeout (100ms) exceeded: received"},
+		{"timeout only", "Watch", &grpcReceive{timeout: 100 * time.Millisecond}, false, -1, ""},
+		{"bidi count", "Chat", &grpcReceive{count: 3}, true, 3, ""},
+		{"bidi until", "Chat", &grpcReceive{until: "current.res.message.status == 2"}, true, 2, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := New(GrpcRunnerWithOptions("greq", target, TLS(false), Protosets([]string{p})))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := o.grpcRunners["greq"]
+			r.operator = o
+			t.Cleanup(func() {
+				_ = r.Close()
+			})
+			req := &grpcRequest{
+				service: "grpc.health.v1.Health",
+				method:  tt.method,
+				headers: metadata.MD{},
+				messages: []*grpcMessage{
+					{op: GRPCOpMessage, params: map[string]any{}},
+				},
+				receive: tt.receive,
+			}
+			if tt.bidi {
+				req.service = "receivetest.ReceiveTestService"
+				req.receive = nil
+				req.messages = append(req.messages, &grpcMessage{op: GRPCOpReceive, receive: tt.receive}, &grpcMessage{op: GRPCOpClose})
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := r.Run(ctx, req); err != nil {
+				if tt.wantErr == "" || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("got %v\nwant %q", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Fatalf("want error %q", tt.wantErr)
+			}
+			res, ok := o.store.latest()[grpcStoreResponseKey].(map[string]any)
+			if !ok {
+				t.Fatalf("invalid res: %#v", o.store.latest()[grpcStoreResponseKey])
+			}
+			if got := res[grpcStoreStatusKey]; got != int64(0) {
+				t.Errorf("got %v\nwant %v", got, 0)
+			}
+			messages, ok := res[grpcStoreMessagesKey].([]map[string]any)
+			if !ok {
+				t.Fatalf("invalid messages: %#v", res[grpcStoreMessagesKey])
+			}
+			if tt.wantMessages < 0 {
+				if len(messages) == 0 {
+					t.Error("want messages")
+				}
+				return
+			}
+			if len(messages) != tt.wantMessages {
+				t.Errorf("got %v\nwant %v", len(messages), tt.wantMessages)
+			}
+		})
+	}
+}
+
+func TestGrpcRunnerReceiveOnlyForServerStreaming(t *testing.T) {
+	target, p := watchServer(t)
+	o, err := New(GrpcRunnerWithOptions("greq", target, TLS(false), Protosets([]string{p})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := o.grpcRunners["greq"]
+	r.operator = o
+	t.Cleanup(func() {
+		_ = r.Close()
+	})
+	req := &grpcRequest{
+		service:  "grpc.health.v1.Health",
+		method:   "Check",
+		headers:  metadata.MD{},
+		messages: []*grpcMessage{{op: GRPCOpMessage, params: map[string]any{}}},
+		receive:  &grpcReceive{count: 1},
+	}
+	if err := r.Run(context.Background(), req); err == nil {
+		t.Error("want error")
+	}
+}
+
+// watchServer starts the server of infinite streams that send SERVING and NOT_SERVING alternately, and returns the target and the protoset.
+// grpc.health.v1.Health/Watch is server streaming, and receivetest.ReceiveTestService/Chat is bidirectional streaming.
+func watchServer(t *testing.T) (string, string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer(grpc.UnknownServiceHandler(func(_ any, stream grpc.ServerStream) error {
+		req := &healthpb.HealthCheckRequest{}
+		if err := stream.RecvMsg(req); err != nil {
+			return err
+		}
+		return sendAlternately(stream.Context(), stream.SendMsg)
+	}))
+	healthpb.RegisterHealthServer(s, &watchHealthServer{})
+	go func() {
+		_ = s.Serve(l)
+	}()
+	t.Cleanup(s.Stop)
+
+	hfd := protodesc.ToFileDescriptorProto(healthpb.File_grpc_health_v1_health_proto)
+	cfd := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("receivetest/chat.proto"),
+		Package:    proto.String("receivetest"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{hfd.GetName()},
+		Service: []*descriptorpb.ServiceDescriptorProto{
+			{
+				Name: proto.String("ReceiveTestService"),
+				Method: []*descriptorpb.MethodDescriptorProto{
+					{
+						Name:            proto.String("Chat"),
+						InputType:       proto.String(".grpc.health.v1.HealthCheckRequest"),
+						OutputType:      proto.String(".grpc.health.v1.HealthCheckResponse"),
+						ClientStreaming: proto.Bool(true),
+						ServerStreaming: proto.Bool(true),
+					},
+				},
+			},
+		},
+	}
+	b, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{hfd, cfd}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := filepath.Join(t.TempDir(), "receivetest.protoset")
+	if err := os.WriteFile(p, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return l.Addr().String(), p
+}
+
+type watchHealthServer struct {
+	healthpb.UnimplementedHealthServer
+}
+
+func (s *watchHealthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func (s *watchHealthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	return sendAlternately(stream.Context(), func(m any) error {
+		return stream.Send(m.(*healthpb.HealthCheckResponse))
+	})
+}
+
+func sendAlternately(ctx context.Context, send func(m any) error) error {
+	for i := 0; ; i++ {
+		st := healthpb.HealthCheckResponse_SERVING
+		if i%2 == 1 {
+			st = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		if err := send(&healthpb.HealthCheckResponse{Status: st}); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package runn
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"github.com/goccy/go-json"
+	_ "google.golang.org/genproto/googleapis/rpc/errdetails" // Register the standard error details ( e.g. google.rpc.BadRequest ).
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const grpcStatusDetailTypeKey = "@type"
+
+// grpcStatusDetails returns the details of the status as JSON-like maps in the JSON mapping of google.protobuf.Any ( e.g. {"@type": "type.googleapis.com/google.rpc.ErrorInfo", "reason": "..."} ).
+// The types of details are resolved from the standard error details and the descriptors resolved from proto files, protosets or server reflection.
+// Details of unresolved types are returned with the base64-encoded value.
+func grpcStatusDetails(s *status.Status) []any {
+	details := []any{}
+	for _, a := range s.Proto().GetDetails() {
+		details = append(details, grpcStatusDetail(a))
+	}
+	return details
+}
+
+func grpcStatusDetail(a *anypb.Any) map[string]any {
+	d := map[string]any{
+		grpcStatusDetailTypeKey: a.GetTypeUrl(),
+	}
+	mt, err := findMessageTypeByURL(a.GetTypeUrl())
+	if err != nil {
+		d["value"] = base64.StdEncoding.EncodeToString(a.GetValue())
+		return d
+	}
+	m := mt.New().Interface()
+	if err := proto.Unmarshal(a.GetValue(), m); err != nil {
+		d["value"] = base64.StdEncoding.EncodeToString(a.GetValue())
+		return d
+	}
+	b, err := protojson.MarshalOptions{UseProtoNames: true, UseEnumNumbers: true, EmitUnpopulated: true}.Marshal(m)
+	if err != nil {
+		d["value"] = base64.StdEncoding.EncodeToString(a.GetValue())
+		return d
+	}
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		d["value"] = base64.StdEncoding.EncodeToString(a.GetValue())
+		return d
+	}
+	fields, ok := v.(map[string]any)
+	if !ok {
+		// Well-known types with special JSON mapping ( e.g. google.protobuf.StringValue )
+		d["value"] = v
+		return d
+	}
+	for k, v := range fields {
+		d[k] = v
+	}
+	return d
+}
+
+func findMessageTypeByURL(url string) (protoreflect.MessageType, error) {
+	name := url
+	if i := strings.LastIndex(url, "/"); i >= 0 {
+		name = url[i+1:]
+	}
+	if mt, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(name)); err == nil {
+		return mt, nil
+	}
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, err
+	}
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, protoregistry.NotFound
+	}
+	return dynamicpb.NewMessageType(md), nil
+}
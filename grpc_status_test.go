@@ -0,0 +1,231 @@
+package runn
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestGRPCStatusDetails(t *testing.T) {
+	md := registerCustomDetail(t)
+	custom := dynamicpb.NewMessage(md)
+	custom.Set(md.Fields().ByName("reason"), protoreflect.ValueOfString("too many"))
+	custom.Set(md.Fields().ByName("count"), protoreflect.ValueOfInt32(3))
+
+	st, err := status.New(codes.InvalidArgument, "invalid").WithDetails(
+		&errdetails.ResourceInfo{ResourceType: "user", ResourceName: "alice", Owner: "example.com", Description: "not found"},
+		&errdetails.ErrorInfo{Reason: "INVALID_NAME", Domain: "example.com", Metadata: map[string]string{"key": "value"}},
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(5 * time.Second)},
+		custom,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := st.Proto()
+	p.Details = append(p.Details, &anypb.Any{TypeUrl: "type.googleapis.com/unknown.Detail", Value: []byte("unknown")})
+	st = status.FromProto(p)
+
+	got := grpcStatusDetails(st)
+	want := []any{
+		map[string]any{
+			"@type":         "type.googleapis.com/google.rpc.ResourceInfo",
+			"resource_type": "user",
+			"resource_name": "alice",
+			"owner":         "example.com",
+			"description":   "not found",
+		},
+		map[string]any{
+			"@type":    "type.googleapis.com/google.rpc.ErrorInfo",
+			"reason":   "INVALID_NAME",
+			"domain":   "example.com",
+			"metadata": map[string]any{"key": "value"},
+		},
+		map[string]any{
+			"@type":       "type.googleapis.com/google.rpc.RetryInfo",
+			"retry_delay": "5s",
+		},
+		map[string]any{
+			"@type":  "type.googleapis.com/statusdetailtest.CustomDetail",
+			"reason": "too many",
+			"count":  float64(3),
+		},
+		map[string]any{
+			"@type": "type.googleapis.com/unknown.Detail",
+			"value": "dW5rbm93bg==",
+		},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Error(diff)
+	}
+
+	out := new(bytes.Buffer)
+	NewDebugger(out).CaptureGRPCResponseStatus(st)
+	for _, w := range []string{
+		"InvalidArgument (3): invalid\ndetails:\n",
+		`- {"@type":"type.googleapis.com/google.rpc.ErrorInfo","domain":"example.com","metadata":{"key":"value"},"reason":"INVALID_NAME"}`,
+		`- {"@type":"type.googleapis.com/statusdetailtest.CustomDetail","count":3,"reason":"too many"}`,
+	} {
+		if !strings.Contains(out.String(), w) {
+			t.Errorf("debugger output should contain %q\ngot: %s", w, out.String())
+		}
+	}
+}
+
+func TestGrpcRunnerStatusDetails(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	healthpb.RegisterHealthServer(s, &errorHealthServer{})
+	go func() {
+		_ = s.Serve(l)
+	}()
+	t.Cleanup(s.Stop)
+	p := healthProtoset(t)
+
+	o, err := New(GrpcRunnerWithOptions("greq", l.Addr().String(), TLS(false), Protosets([]string{p})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := o.grpcRunners["greq"]
+	r.operator = o
+	t.Cleanup(func() {
+		_ = r.Close()
+	})
+	req := &grpcRequest{
+		service:  "grpc.health.v1.Health",
+		method:   "Check",
+		headers:  metadata.MD{},
+		messages: []*grpcMessage{{op: GRPCOpMessage, params: map[string]any{}}},
+	}
+	if err := r.Run(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	res, ok := o.store.latest()[grpcStoreResponseKey].(map[string]any)
+	if !ok {
+		t.Fatalf("invalid res: %#v", o.store.latest()[grpcStoreResponseKey])
+	}
+	want := []any{
+		map[string]any{
+			"@type":  "type.googleapis.com/google.rpc.ErrorInfo",
+			"reason": "UNHEALTHY",
+			"domain": "example.com",
+			"metadata": map[string]any{
+				"service": "",
+			},
+		},
+	}
+	if diff := cmp.Diff(res[grpcStoreDetailsKey], want); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestConnectErrorDetails(t *testing.T) {
+	b, err := proto.Marshal(&errdetails.ErrorInfo{Reason: "UNHEALTHY"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `{"code":"unavailable","message":"unhealthy","details":[{"type":"google.rpc.ErrorInfo","value":"` + base64.RawStdEncoding.EncodeToString(b) + `"}]}`
+	st, ok := status.FromError(connectError(503, []byte(body)))
+	if !ok {
+		t.Fatal("want status error")
+	}
+	want := []any{
+		map[string]any{
+			"@type":    "type.googleapis.com/google.rpc.ErrorInfo",
+			"reason":   "UNHEALTHY",
+			"domain":   "",
+			"metadata": map[string]any{},
+		},
+	}
+	if diff := cmp.Diff(grpcStatusDetails(st), want); diff != "" {
+		t.Error(diff)
+	}
+}
+
+type errorHealthServer struct {
+	healthpb.UnimplementedHealthServer
+}
+
+func (s *errorHealthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	st, err := status.New(codes.Unavailable, "unhealthy").WithDetails(&errdetails.ErrorInfo{
+		Reason:   "UNHEALTHY",
+		Domain:   "example.com",
+		Metadata: map[string]string{"service": req.GetService()},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return nil, st.Err()
+}
+
+// registerCustomDetail registers the service file that imports the file of the custom detail type, as proto files resolved by the runner.
+func registerCustomDetail(t *testing.T) protoreflect.MessageDescriptor {
+	t.Helper()
+	detail, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("statusdetailtest/detail.proto"),
+		Package: proto.String("statusdetailtest"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("CustomDetail"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{Name: proto.String("reason"), JsonName: proto.String("reason"), Number: proto.Int32(1), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()},
+					{Name: proto.String("count"), JsonName: proto.String("count"), Number: proto.Int32(2), Type: descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()},
+				},
+			},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := new(protoregistry.Files)
+	if err := files.RegisterFile(detail); err != nil {
+		t.Fatal(err)
+	}
+	svc, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("statusdetailtest/service.proto"),
+		Package:    proto.String("statusdetailtest"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"statusdetailtest/detail.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("Empty")},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{
+			{
+				Name: proto.String("StatusDetailService"),
+				Method: []*descriptorpb.MethodDescriptorProto{
+					{Name: proto.String("Call"), InputType: proto.String(".statusdetailtest.Empty"), OutputType: proto.String(".statusdetailtest.Empty")},
+				},
+			},
+		},
+	}, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := registerFiles([]protoreflect.FileDescriptor{svc}); err != nil {
+		t.Fatal(err)
+	}
+	return detail.Messages().ByName("CustomDetail")
+}
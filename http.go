@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
-	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
@@ -12,6 +11,7 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/http/httptrace"
 	"net/textproto"
 	"net/url"
 	"os"
@@ -19,9 +19,6 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
-
-	"github.com/ajg/form"
-	"github.com/goccy/go-json"
 )
 
 const (
@@ -29,6 +26,15 @@ const (
 	MediaTypeTextPlain                 = "text/plain"
 	MediaTypeApplicationFormUrlencoded = "application/x-www-form-urlencoded"
 	MediaTypeMultipartFormData         = "multipart/form-data"
+	MediaTypeApplicationXML            = "application/xml"
+	MediaTypeTextXML                   = "text/xml"
+	MediaTypeApplicationYAML           = "application/yaml"
+	MediaTypeTextCSV                   = "text/csv"
+	MediaTypeApplicationMsgpack        = "application/msgpack"
+	MediaTypeApplicationOctetStream    = "application/octet-stream"
+	MediaTypeApplicationNDJSON         = "application/x-ndjson"
+	MediaTypeApplicationProtobuf       = "application/x-protobuf"
+	MediaTypeTextEventStream           = "text/event-stream"
 )
 
 const (
@@ -37,18 +43,32 @@ const (
 	httpStoreRawBodyKey  = "rawBody"
 	httpStoreHeaderKey   = "headers"
 	httpStoreCookieKey   = "cookies"
+	httpStoreTraceKey    = "trace"
+	httpStoreMessagesKey = "messages"
 	httpStoreResponseKey = "res"
 )
 
+// handlerDefaultEndpoint is the endpoint of HTTP runner with http.Handler ( same as the host of httptest.NewRequest ).
+const handlerDefaultEndpoint = "http://example.com"
+
 var notFollowRedirectFn = func(req *http.Request, via []*http.Request) error {
 	return http.ErrUseLastResponse
 }
 
+// maxRedirectsFn returns the function to stop following redirects after n redirects.
+func maxRedirectsFn(n int) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) >= n {
+			return fmt.Errorf("stopped after %d redirects", n)
+		}
+		return nil
+	}
+}
+
 type httpRunner struct {
 	name              string
 	endpoint          *url.URL
 	client            *http.Client
-	handler           http.Handler
 	operator          *operator
 	validator         httpValidator
 	multipartBoundary string
@@ -57,23 +77,56 @@ type httpRunner struct {
 	key               []byte
 	skipVerify        bool
 	useCookie         *bool
+	decoders          map[string]httpResponseDecodeFunc
+	encoders          map[string]httpRequestEncodeFunc
+	auth              *httpAuth
+	sign              *httpSignConfig
+	signer            func(req *http.Request) error
+	maxBodySize       uint64
+	middlewares       []HTTPMiddleware
+	dialer            *dialer
+	// socket is the path of the unix domain socket ( http+unix:// )
+	socket string
 }
 
 type httpRequest struct {
-	path      string
-	method    string
-	headers   map[string]string
-	mediaType string
-	body      any
-	useCookie *bool
+	path       string
+	method     string
+	headers    http.Header
+	query      url.Values
+	mediaType  string
+	body       any
+	useCookie  *bool
+	stream     *httpStream
+	graphql    *httpGraphQL
+	saveTo     string
+	jsonSchema string
+	compress   string
 
 	multipartWriter   *multipart.Writer
 	multipartBoundary string
+	encoders          map[string]httpRequestEncodeFunc
 	// operator.root
 	root string
 }
 
 func newHTTPRunner(name, endpoint string) (*httpRunner, error) {
+	if isHTTPUnixSocketEndpoint(endpoint) {
+		socket, u, err := parseHTTPUnixSocketEndpoint(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		return &httpRunner{
+			name:     name,
+			endpoint: u,
+			socket:   socket,
+			client: &http.Client{
+				Transport: unixSocketTransport(socket),
+				Timeout:   time.Second * 30,
+			},
+			validator: newNopValidator(),
+		}, nil
+	}
 	u, err := url.Parse(endpoint)
 	if err != nil {
 		return nil, err
@@ -90,14 +143,84 @@ func newHTTPRunner(name, endpoint string) (*httpRunner, error) {
 }
 
 func newHTTPRunnerWithHandler(name string, h http.Handler) (*httpRunner, error) {
+	u, err := url.Parse(handlerDefaultEndpoint)
+	if err != nil {
+		return nil, err
+	}
 	return &httpRunner{
-		name:      name,
-		handler:   h,
+		name:     name,
+		endpoint: u,
+		client: &http.Client{
+			Transport: &handlerTransport{handler: h},
+			Timeout:   time.Second * 30,
+		},
 		validator: newNopValidator(),
 	}, nil
 }
 
+// handlerTransport is the http.RoundTripper that serves requests using http.Handler in-process.
+// Since requests are sent via http.Client, HTTP runner with http.Handler behaves the same as HTTP runner with the server ( cookies, redirects, Host, etc. ).
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t *handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Convert the client request to the server request like httptest.NewRequest
+	sreq := req.Clone(req.Context())
+	sreq.URL = &url.URL{
+		Path:     req.URL.Path,
+		RawPath:  req.URL.RawPath,
+		RawQuery: req.URL.RawQuery,
+	}
+	sreq.RequestURI = req.URL.RequestURI()
+	sreq.Proto = "HTTP/1.1"
+	sreq.ProtoMajor = 1
+	sreq.ProtoMinor = 1
+	if sreq.Host == "" {
+		sreq.Host = req.URL.Host
+	}
+	if sreq.Body == nil {
+		sreq.Body = http.NoBody
+	}
+	sreq.RemoteAddr = "192.0.2.1:1234"
+	if req.URL.Scheme == "https" {
+		sreq.TLS = &tls.ConnectionState{
+			Version:           tls.VersionTLS12,
+			HandshakeComplete: true,
+			ServerName:        sreq.Host,
+		}
+	}
+	w := httptest.NewRecorder()
+	t.handler.ServeHTTP(w, sreq)
+	if trace := httptrace.ContextClientTrace(req.Context()); trace != nil && trace.GotFirstResponseByte != nil {
+		trace.GotFirstResponseByte()
+	}
+	res := w.Result()
+	res.Request = req
+	return res, nil
+}
+
 func (r *httpRequest) validate() error {
+	if r.saveTo != "" && (r.stream != nil || r.graphql != nil) {
+		return errors.New("saveTo cannot be used with stream or graphql")
+	}
+	if r.jsonSchema != "" && (r.stream != nil || r.saveTo != "") {
+		return errors.New("jsonSchema cannot be used with stream or saveTo")
+	}
+	if r.compress != "" && !contains(supportedContentEncodings, r.compress) {
+		return fmt.Errorf("unsupported compress: %s (supported: %s)", r.compress, strings.Join(supportedContentEncodings, ", "))
+	}
+	if r.graphql != nil {
+		switch r.method {
+		case http.MethodGet, http.MethodPost:
+		default:
+			return fmt.Errorf("graphql does not support %s method", r.method)
+		}
+		if r.body != nil {
+			return errors.New("graphql and body cannot be used together")
+		}
+		return nil
+	}
 	switch r.method {
 	case http.MethodPost, http.MethodPatch:
 		if r.mediaType == "" {
@@ -107,12 +230,10 @@ func (r *httpRequest) validate() error {
 			return fmt.Errorf("%s method requires body", r.method)
 		}
 	}
-	if r.isMultipartFormDataMediaType() {
+	if r.mediaType == "" || r.isMultipartFormDataMediaType() {
 		return nil
 	}
-	switch r.mediaType {
-	case MediaTypeApplicationJSON, MediaTypeTextPlain, MediaTypeApplicationFormUrlencoded, "":
-	default:
+	if _, ok := r.encoder(); !ok {
 		return fmt.Errorf("unsupported mediaType: %s", r.mediaType)
 	}
 	return nil
@@ -125,32 +246,11 @@ func (r *httpRequest) encodeBody() (io.Reader, error) {
 	if r.isMultipartFormDataMediaType() {
 		return r.encodeMultipart()
 	}
-	switch r.mediaType {
-	case MediaTypeApplicationJSON:
-		b, err := json.Marshal(r.body)
-		if err != nil {
-			return nil, err
-		}
-		return bytes.NewBuffer(b), nil
-	case MediaTypeApplicationFormUrlencoded:
-		values, ok := r.body.(map[string]any)
-		if !ok {
-			return nil, fmt.Errorf("invalid body: %v", r.body)
-		}
-		buf := new(bytes.Buffer)
-		if err := form.NewEncoder(buf).Encode(values); err != nil {
-			return nil, err
-		}
-		return buf, nil
-	case MediaTypeTextPlain:
-		s, ok := r.body.(string)
-		if !ok {
-			return nil, fmt.Errorf("invalid body: %v", r.body)
-		}
-		return strings.NewReader(s), nil
-	default:
+	enc, ok := r.encoder()
+	if !ok {
 		return nil, fmt.Errorf("unsupported mediaType: %s", r.mediaType)
 	}
+	return enc(r)
 }
 
 func (r *httpRequest) isMultipartFormDataMediaType() bool {
@@ -274,6 +374,32 @@ func (r *httpRequest) setCookieHeader(req *http.Request, cookies map[string]map[
 	}
 }
 
+func (r *httpRequest) setHeaders(req *http.Request) {
+	for k, v := range r.headers {
+		req.Header.Del(k)
+		for _, vv := range v {
+			req.Header.Add(k, vv)
+		}
+		if k == "Host" && len(v) > 0 {
+			req.Host = v[0]
+		}
+	}
+}
+
+// mergeQuery merges `query:` of the request into the query of u.
+func (r *httpRequest) mergeQuery(u *url.URL) {
+	if len(r.query) == 0 {
+		return
+	}
+	q := u.Query()
+	for k, vs := range r.query {
+		for _, v := range vs {
+			q.Add(k, v)
+		}
+	}
+	u.RawQuery = q.Encode()
+}
+
 func isLocalhost(domain string) (bool, error) {
 	ips, err := net.LookupIP(domain)
 	if err != nil {
@@ -290,142 +416,117 @@ func isLocalhost(domain string) (bool, error) {
 
 func (rnr *httpRunner) Run(ctx context.Context, r *httpRequest) error {
 	r.multipartBoundary = rnr.multipartBoundary
+	r.encoders = rnr.encoders
 	r.root = rnr.operator.root
-	reqBody, err := r.encodeBody()
+	if r.graphql != nil {
+		if err := r.setGraphQL(); err != nil {
+			return err
+		}
+	}
+
+	tracer := newHTTPTracer()
+	req, res, err := rnr.do(ctx, r, tracer, true)
 	if err != nil {
 		return err
 	}
+	if res.StatusCode == http.StatusUnauthorized && rnr.auth != nil && rnr.auth.unauthorized(res) {
+		// Retry once with new credentials without capturing so that the step is captured as one request and response
+		_ = res.Body.Close()
+		tracer = newHTTPTracer()
+		req, res, err = rnr.do(ctx, r, tracer, false)
+		if err != nil {
+			return err
+		}
+	}
+	cb, err := rnr.decompressBody(res)
+	if err != nil {
+		_ = res.Body.Close()
+		return err
+	}
+	defer res.Body.Close()
+	rnr.hitCoverage(req, res)
 
-	var (
-		req *http.Request
-		res *http.Response
-	)
-	switch {
-	case rnr.client != nil:
-		if rnr.client.Transport == nil {
-			rnr.client.Transport = http.DefaultTransport.(*http.Transport).Clone()
+	var gerr error
+	d := map[string]any{}
+	d[httpStoreStatusKey] = res.StatusCode
+	d[httpStoreHeaderKey] = res.Header
+	if r.stream != nil {
+		// Capture only the status and headers because the body is read as a stream
+		cres := *res
+		cres.Body = http.NoBody
+		rnr.operator.capturers.captureHTTPResponse(rnr.name, &cres)
+
+		messages, raw, err := rnr.readStream(ctx, r.stream, res, d)
+		if err != nil {
+			return err
 		}
-		if ts, ok := rnr.client.Transport.(*http.Transport); ok {
-			existingConfig := ts.TLSClientConfig
-			if existingConfig != nil {
-				ts.TLSClientConfig = existingConfig.Clone()
-			} else {
-				ts.TLSClientConfig = new(tls.Config)
-			}
-			ts.TLSClientConfig.InsecureSkipVerify = rnr.skipVerify
+		d[httpStoreMessagesKey] = messages
+		d[httpStoreBodyKey] = nil
+		d[httpStoreRawBodyKey] = string(raw)
+	} else if r.saveTo != "" {
+		// Capture only the status and headers because the body is saved to the file
+		cres := *res
+		cres.Body = http.NoBody
+		rnr.operator.capturers.captureHTTPResponse(rnr.name, &cres)
+		rnr.operator.Debugf("Skip validate response due to saving the body to the file: %s\n", r.saveTo)
+
+		f, err := rnr.saveBody(r.saveTo, res)
+		if err != nil {
+			return err
 		}
-		if len(rnr.cacert) != 0 {
-			certpool, err := x509.SystemCertPool()
-			if err != nil {
-				// FIXME for Windows
-				// ref: https://github.com/golang/go/issues/18609
-				certpool = x509.NewCertPool()
-			}
-			if !certpool.AppendCertsFromPEM(rnr.cacert) {
+		d[httpStoreFileKey] = f
+		d[httpStoreBodyKey] = nil
+		d[httpStoreRawBodyKey] = ""
+	} else {
+		rnr.limitBody(res)
+		rnr.operator.capturers.captureHTTPResponse(rnr.name, res)
+
+		if err := rnr.validator.ValidateResponse(ctx, req, res); err != nil {
+			var target *UnsupportedError
+			if errors.As(err, &target) {
+				rnr.operator.Debugf("Skip validate response due to unsupported format: %s", err.Error())
+			} else {
 				return err
 			}
-			ts, ok := rnr.client.Transport.(*http.Transport)
-			if !ok {
-				return fmt.Errorf("could not set cacert: interface conversion error: http.RoundTripper is %#v, not *http.Transport", rnr.client.Transport)
-			}
-			ts.TLSClientConfig.RootCAs = certpool
 		}
-		if len(rnr.cert) != 0 && len(rnr.key) != 0 {
-			cert, err := tls.X509KeyPair(rnr.cert, rnr.key)
+		if r.jsonSchema != "" {
+			s, err := loadJSONSchema(r.jsonSchema, rnr.operator.root)
 			if err != nil {
 				return err
 			}
-			ts, ok := rnr.client.Transport.(*http.Transport)
-			if !ok {
-				return fmt.Errorf("could not set certificates: interface conversion error: http.RoundTripper is %#v, not *http.Transport", rnr.client.Transport)
+			// Non-JSON bodies are skipped in the same way as the JSON Schema of the runner
+			if err := s.validateResponse(res); err != nil {
+				var target *UnsupportedError
+				if errors.As(err, &target) {
+					rnr.operator.Debugf("Skip validate response due to unsupported format: %s", err.Error())
+				} else {
+					return err
+				}
 			}
-			ts.TLSClientConfig.Certificates = []tls.Certificate{cert}
 		}
 
-		u, err := mergeURL(rnr.endpoint, r.path)
+		resBody, err := io.ReadAll(res.Body)
 		if err != nil {
 			return err
 		}
-		req, err = http.NewRequestWithContext(ctx, r.method, u.String(), reqBody)
+		b, err := rnr.decodeBody(res.Header.Get("Content-Type"), resBody)
 		if err != nil {
-			return err
-		}
-		r.setContentTypeHeader(req)
-
-		// Override useCookie
-		if r.useCookie == nil && rnr.useCookie != nil && *rnr.useCookie {
-			r.useCookie = rnr.useCookie
-		}
-		r.setCookieHeader(req, rnr.operator.store.cookies)
-		for k, v := range r.headers {
-			req.Header.Set(k, v)
-			if k == "Host" {
-				req.Host = v
+			if isJSONContentType(res.Header.Get("Content-Type")) {
+				return err
 			}
-		}
-
-		rnr.operator.capturers.captureHTTPRequest(rnr.name, req)
-
-		if err := rnr.validator.ValidateRequest(ctx, req); err != nil {
-			return err
-		}
-
-		res, err = rnr.client.Do(req)
-		if err != nil {
-			return err
-		}
-		defer res.Body.Close()
-	case rnr.handler != nil:
-		req = httptest.NewRequest(r.method, r.path, reqBody)
-		if r.mediaType != "" {
-			req.Header.Set("Content-Type", r.mediaType)
-		}
-		for k, v := range r.headers {
-			req.Header.Set(k, v)
-		}
-
-		rnr.operator.capturers.captureHTTPRequest(rnr.name, req)
-
-		if err := rnr.validator.ValidateRequest(ctx, req); err != nil {
-			return err
-		}
-		w := httptest.NewRecorder()
-		rnr.handler.ServeHTTP(w, req)
-		res = w.Result()
-		defer res.Body.Close()
-	default:
-		return fmt.Errorf("invalid http runner: %s", rnr.name)
-	}
-
-	rnr.operator.capturers.captureHTTPResponse(rnr.name, res)
-
-	if err := rnr.validator.ValidateResponse(ctx, req, res); err != nil {
-		var target *UnsupportedError
-		if errors.As(err, &target) {
-			rnr.operator.Debugf("Skip validate response due to unsupported format: %s", err.Error())
-		} else {
-			return err
-		}
-	}
-
-	resBody, err := io.ReadAll(res.Body)
-	if err != nil {
-		return err
-	}
-
-	d := map[string]any{}
-	d[httpStoreStatusKey] = res.StatusCode
-	if strings.Contains(res.Header.Get("Content-Type"), "json") && len(resBody) > 0 {
-		var b any
-		if err := json.Unmarshal(resBody, &b); err != nil {
-			return err
+			// Keep the raw body of the response that can not be decoded ( e.g. an HTML error page with Content-Type: application/xml )
+			rnr.operator.Debugf("Skip decoding response body: %s\n", err.Error())
 		}
 		d[httpStoreBodyKey] = b
-	} else {
-		d[httpStoreBodyKey] = nil
+		d[httpStoreRawBodyKey] = string(resBody)
+		if r.graphql != nil {
+			gerr = r.graphql.record(b, d)
+		}
 	}
-	d[httpStoreRawBodyKey] = string(resBody)
-	d[httpStoreHeaderKey] = res.Header
+	tracer.finish()
+	trace := tracer.result()
+	rnr.operator.capturers.captureHTTPTrace(rnr.name, trace)
+	d[httpStoreTraceKey] = trace.toMap()
 
 	cookies := res.Cookies()
 
@@ -446,11 +547,118 @@ func (rnr *httpRunner) Run(ctx context.Context, r *httpRequest) error {
 		d[httpStoreCookieKey] = map[string]*http.Cookie{}
 	}
 
+	if cb != nil {
+		d[httpStoreCompressionKey] = cb.toMap()
+	}
+
 	rnr.operator.record(map[string]any{
 		string(httpStoreResponseKey): d,
 	})
 
-	return nil
+	// Return the GraphQL errors after recording so that the response can be referred to
+	return gerr
+}
+
+// do sends the HTTP request and returns the request sent and the response.
+// The request is captured only if capture is true.
+func (rnr *httpRunner) do(ctx context.Context, r *httpRequest, tracer *httpTracer, capture bool) (*http.Request, *http.Response, error) {
+	reqBody, err := r.encodeBody()
+	if err != nil {
+		return nil, nil, err
+	}
+	if r.compress != "" && reqBody != nil {
+		b, err := compress(r.compress, reqBody)
+		if err != nil {
+			return nil, nil, err
+		}
+		reqBody = b
+	}
+
+	var (
+		req *http.Request
+		res *http.Response
+	)
+	switch {
+	case rnr.client != nil:
+		if rnr.client.Transport == nil {
+			rnr.client.Transport = http.DefaultTransport.(*http.Transport).Clone()
+		}
+		if err := rnr.configureTLS(); err != nil {
+			return nil, nil, err
+		}
+
+		u, err := mergeURL(rnr.endpoint, r.path)
+		if err != nil {
+			return nil, nil, err
+		}
+		r.mergeQuery(u)
+		req, err = http.NewRequestWithContext(httptrace.WithClientTrace(ctx, tracer.clientTrace()), r.method, u.String(), reqBody)
+		if err != nil {
+			return nil, nil, err
+		}
+		r.setContentTypeHeader(req)
+
+		// Override useCookie
+		if r.useCookie == nil && rnr.useCookie != nil && *rnr.useCookie {
+			r.useCookie = rnr.useCookie
+		}
+		r.setCookieHeader(req, rnr.operator.store.cookies)
+		r.setHeaders(req)
+		if r.compress != "" && reqBody != nil && req.Header.Get("Content-Encoding") == "" {
+			req.Header.Set("Content-Encoding", r.compress)
+		}
+		rnr.setAcceptEncoding(req)
+
+		// Capture the request before authorization and signing so that credentials are not captured
+		if capture {
+			rnr.operator.capturers.captureHTTPRequest(rnr.name, req)
+		}
+
+		if rnr.operator.cassette != nil {
+			// Record and match the request in the cassette without credentials and signatures
+			req = req.WithContext(withCassetteHeaders(req.Context(), req.Header.Clone()))
+		}
+
+		if err := rnr.authorize(ctx, req); err != nil {
+			return nil, nil, err
+		}
+		if err := rnr.signRequest(req); err != nil {
+			return nil, nil, err
+		}
+
+		if err := rnr.validator.ValidateRequest(ctx, req); err != nil {
+			return nil, nil, err
+		}
+
+		client := rnr.httpClient()
+		tracer.begin()
+		if r.stream != nil {
+			res, err = doStream(client, req)
+		} else {
+			res, err = client.Do(req)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, fmt.Errorf("invalid http runner: %s", rnr.name)
+	}
+	return req, res, nil
+}
+
+// httpClient returns the client of the runner that sends requests through the middlewares and the cassette.
+func (rnr *httpRunner) httpClient() *http.Client {
+	cst := rnr.operator.cassette
+	if cst == nil && len(rnr.middlewares) == 0 {
+		return rnr.client
+	}
+	c := *rnr.client
+	rt := rnr.client.Transport
+	if cst != nil {
+		rt = cst.httpTransport(rnr.name, rt)
+	}
+	c.Transport = chainHTTPMiddlewares(rt, rnr.middlewares)
+	return &c
 }
 
 func mergeURL(u *url.URL, p string) (*url.URL, error) {
@@ -0,0 +1,508 @@
+package runn
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/spf13/cast"
+)
+
+const (
+	httpAuthTypeBasic  = "basic"
+	httpAuthTypeBearer = "bearer"
+	httpAuthTypeDigest = "digest"
+	httpAuthTypeOAuth2 = "oauth2"
+
+	oauth2GrantTypeClientCredentials = "client_credentials"
+	oauth2GrantTypePassword          = "password"
+	oauth2GrantTypeRefreshToken      = "refresh_token"
+)
+
+// oauth2TokenExpiryDelta is how earlier a token should be considered expired than its actual expiration time.
+const oauth2TokenExpiryDelta = 10 * time.Second
+
+// oauth2Tokens is the cache of OAuth 2.0 access tokens shared between runners with the same credentials.
+var oauth2Tokens = &oauth2TokenCache{entries: map[string]*oauth2TokenEntry{}}
+
+type httpAuthConfig struct {
+	Type         string   `yaml:"type"`
+	Username     string   `yaml:"username,omitempty"`
+	Password     string   `yaml:"password,omitempty"`
+	Token        string   `yaml:"token,omitempty"`
+	GrantType    string   `yaml:"grantType,omitempty"`
+	TokenURL     string   `yaml:"tokenURL,omitempty"`
+	ClientID     string   `yaml:"clientID,omitempty"`
+	ClientSecret string   `yaml:"clientSecret,omitempty"`
+	Scopes       []string `yaml:"scopes,omitempty"`
+}
+
+type httpAuth struct {
+	config *httpAuthConfig
+	// digest is the latest challenge of Digest access authentication.
+	digest *digestChallenge
+	// tokenKey is the key of the cached OAuth 2.0 access token used for the latest request.
+	tokenKey string
+	mu       sync.Mutex
+}
+
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+	nc        int
+}
+
+type oauth2Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+
+	expiry time.Time
+}
+
+type oauth2TokenCache struct {
+	entries map[string]*oauth2TokenEntry
+	mu      sync.Mutex
+}
+
+// oauth2TokenEntry is the cached token of the credentials.
+// Tokens are fetched holding the lock of the entry, so that fetching a token for some credentials does not block runners using other credentials.
+type oauth2TokenEntry struct {
+	token *oauth2Token
+	mu    sync.Mutex
+}
+
+func (c *httpAuthConfig) validate() error {
+	switch c.Type {
+	case httpAuthTypeBasic, httpAuthTypeDigest:
+		if c.Username == "" {
+			return fmt.Errorf("invalid auth: %s auth requires username", c.Type)
+		}
+	case httpAuthTypeBearer:
+		if c.Token == "" {
+			return errors.New("invalid auth: bearer auth requires token")
+		}
+	case httpAuthTypeOAuth2:
+		if c.TokenURL == "" {
+			return errors.New("invalid auth: oauth2 auth requires tokenURL")
+		}
+		switch c.GrantType {
+		case "", oauth2GrantTypeClientCredentials:
+		case oauth2GrantTypePassword:
+			if c.Username == "" {
+				return errors.New("invalid auth: oauth2 auth with password grant requires username")
+			}
+		default:
+			return fmt.Errorf("invalid auth: unsupported grantType: %s", c.GrantType)
+		}
+	case "":
+		return errors.New("invalid auth: type is required")
+	default:
+		return fmt.Errorf("invalid auth: unsupported type: %s", c.Type)
+	}
+	return nil
+}
+
+// expand expands `{{ }}` in the values of the config.
+func (c *httpAuthConfig) expand(expandFn func(any) (any, error)) (*httpAuthConfig, error) {
+	e := *c
+	for _, s := range []*string{&e.Username, &e.Password, &e.Token, &e.TokenURL, &e.ClientID, &e.ClientSecret} {
+		v, err := expandFn(*s)
+		if err != nil {
+			return nil, err
+		}
+		if *s, err = cast.ToStringE(v); err != nil {
+			return nil, err
+		}
+	}
+	e.Scopes = make([]string, 0, len(c.Scopes))
+	for _, s := range c.Scopes {
+		v, err := expandFn(s)
+		if err != nil {
+			return nil, err
+		}
+		ss, err := cast.ToStringE(v)
+		if err != nil {
+			return nil, err
+		}
+		e.Scopes = append(e.Scopes, ss)
+	}
+	return &e, nil
+}
+
+func newHTTPAuth(c *httpAuthConfig) (*httpAuth, error) {
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	return &httpAuth{config: c}, nil
+}
+
+// authorize sets the credentials of the runner to the request.
+func (rnr *httpRunner) authorize(ctx context.Context, req *http.Request) error {
+	if rnr.auth == nil {
+		return nil
+	}
+	a := rnr.auth
+	c, err := a.config.expand(rnr.operator.expandBeforeRecord)
+	if err != nil {
+		return fmt.Errorf("invalid auth: %w", err)
+	}
+	switch c.Type {
+	case httpAuthTypeBasic:
+		req.SetBasicAuth(c.Username, c.Password)
+	case httpAuthTypeBearer:
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	case httpAuthTypeDigest:
+		h, err := a.digestAuthorization(c, req)
+		if err != nil {
+			return err
+		}
+		if h != "" {
+			req.Header.Set("Authorization", h)
+		}
+	case httpAuthTypeOAuth2:
+		// Send the token request in the same way as the requests of steps ( middlewares, record and replay )
+		client := http.DefaultClient
+		if rnr.client != nil {
+			client = rnr.httpClient()
+		}
+		t, err := a.oauth2Token(ctx, client, c)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", t.authorization())
+	}
+	return nil
+}
+
+// unauthorized updates the state of the authentication with the 401 response and reports whether the request should be retried.
+func (a *httpAuth) unauthorized(res *http.Response) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switch a.config.Type {
+	case httpAuthTypeDigest:
+		for _, v := range res.Header.Values("WWW-Authenticate") {
+			dc, ok := parseDigestChallenge(v)
+			if !ok {
+				continue
+			}
+			a.digest = dc
+			return true
+		}
+		return false
+	case httpAuthTypeOAuth2:
+		if a.tokenKey == "" {
+			return false
+		}
+		oauth2Tokens.delete(a.tokenKey)
+		return true
+	default:
+		return false
+	}
+}
+
+func (a *httpAuth) digestAuthorization(c *httpAuthConfig, req *http.Request) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.digest == nil {
+		// Wait for the challenge from the server
+		return "", nil
+	}
+	a.digest.nc++
+	return a.digest.authorization(c.Username, c.Password, req.Method, req.URL.RequestURI())
+}
+
+func (a *httpAuth) oauth2Token(ctx context.Context, client *http.Client, c *httpAuthConfig) (*oauth2Token, error) {
+	key := oauth2TokenKey(c)
+	a.mu.Lock()
+	a.tokenKey = key
+	a.mu.Unlock()
+	return oauth2Tokens.token(key, func(cached *oauth2Token) (*oauth2Token, error) {
+		if cached != nil && cached.RefreshToken != "" {
+			t, err := fetchOAuth2Token(ctx, client, c, url.Values{
+				"grant_type":    {oauth2GrantTypeRefreshToken},
+				"refresh_token": {cached.RefreshToken},
+			})
+			if err == nil {
+				return t, nil
+			}
+			// Fall back to the original grant
+		}
+		v := url.Values{}
+		switch c.GrantType {
+		case oauth2GrantTypePassword:
+			v.Set("grant_type", oauth2GrantTypePassword)
+			v.Set("username", c.Username)
+			v.Set("password", c.Password)
+		default:
+			v.Set("grant_type", oauth2GrantTypeClientCredentials)
+		}
+		if len(c.Scopes) > 0 {
+			v.Set("scope", strings.Join(c.Scopes, " "))
+		}
+		return fetchOAuth2Token(ctx, client, c, v)
+	})
+}
+
+func fetchOAuth2Token(ctx context.Context, client *http.Client, c *httpAuthConfig, v url.Values) (*oauth2Token, error) {
+	// Credentials and tokens are not recorded in the cassette
+	ctx = withCassetteRedaction(ctx, &cassetteRedaction{
+		requestFields:  []string{"password", "refresh_token", "client_secret"},
+		responseFields: []string{"access_token", "refresh_token", "id_token"},
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.TokenURL, strings.NewReader(v.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", MediaTypeApplicationFormUrlencoded)
+	req.Header.Set("Accept", MediaTypeApplicationJSON)
+	if c.ClientID != "" {
+		req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch oauth2 token: %w", err)
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch oauth2 token: %w", err)
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, fmt.Errorf("failed to fetch oauth2 token: %s: %s", res.Status, string(b))
+	}
+	t := &oauth2Token{}
+	if err := json.Unmarshal(b, t); err != nil {
+		return nil, fmt.Errorf("failed to fetch oauth2 token: %w", err)
+	}
+	if t.AccessToken == "" {
+		return nil, errors.New("failed to fetch oauth2 token: access_token not found in the response")
+	}
+	if t.ExpiresIn > 0 {
+		t.expiry = time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+	}
+	return t, nil
+}
+
+// oauth2TokenKey returns the key of the token cache for the credentials.
+func oauth2TokenKey(c *httpAuthConfig) string {
+	h := sha256.New()
+	for _, s := range []string{c.TokenURL, c.GrantType, c.ClientID, c.ClientSecret, c.Username, c.Password, strings.Join(c.Scopes, " ")} {
+		_, _ = h.Write([]byte(s))
+		_, _ = h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (t *oauth2Token) valid() bool {
+	if t == nil || t.AccessToken == "" {
+		return false
+	}
+	if t.expiry.IsZero() {
+		return true
+	}
+	return time.Now().Add(oauth2TokenExpiryDelta).Before(t.expiry)
+}
+
+func (t *oauth2Token) authorization() string {
+	typ := t.TokenType
+	if typ == "" || strings.EqualFold(typ, "bearer") {
+		typ = "Bearer"
+	}
+	return fmt.Sprintf("%s %s", typ, t.AccessToken)
+}
+
+// token returns the cached token if it is valid. Otherwise, it fetches a new token using fetchFn.
+// Only one token is fetched at a time for the same key.
+func (c *oauth2TokenCache) token(key string, fetchFn func(cached *oauth2Token) (*oauth2Token, error)) (*oauth2Token, error) {
+	e := c.entry(key)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	cached := e.token
+	if cached.valid() {
+		return cached, nil
+	}
+	t, err := fetchFn(cached)
+	if err != nil {
+		return nil, err
+	}
+	if t.RefreshToken == "" && cached != nil {
+		t.RefreshToken = cached.RefreshToken
+	}
+	e.token = t
+	return t, nil
+}
+
+// delete invalidates the access token but keeps the refresh token.
+func (c *oauth2TokenCache) delete(key string) {
+	e := c.entry(key)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.token == nil {
+		return
+	}
+	e.token = &oauth2Token{RefreshToken: e.token.RefreshToken}
+}
+
+func (c *oauth2TokenCache) entry(key string) *oauth2TokenEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		e = &oauth2TokenEntry{}
+		c.entries[key] = e
+	}
+	return e
+}
+
+// parseDigestChallenge parses the challenge of Digest access authentication ( RFC 7616 ) in WWW-Authenticate header.
+func parseDigestChallenge(h string) (*digestChallenge, bool) {
+	s := strings.TrimSpace(h)
+	if len(s) < 7 || !strings.EqualFold(s[:7], "digest ") {
+		return nil, false
+	}
+	params := parseAuthParams(s[7:])
+	dc := &digestChallenge{
+		realm:     params["realm"],
+		nonce:     params["nonce"],
+		opaque:    params["opaque"],
+		algorithm: params["algorithm"],
+	}
+	if dc.nonce == "" {
+		return nil, false
+	}
+	if qop, ok := params["qop"]; ok {
+		for _, q := range strings.Split(qop, ",") {
+			if strings.TrimSpace(q) == "auth" {
+				dc.qop = "auth"
+				break
+			}
+		}
+		if dc.qop == "" {
+			// Only qop=auth is supported
+			return nil, false
+		}
+	}
+	return dc, true
+}
+
+// parseAuthParams parses comma-separated auth-params such as `realm="example", qop="auth,auth-int"`.
+func parseAuthParams(s string) map[string]string {
+	params := map[string]string{}
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return params
+		}
+		i := strings.Index(s, "=")
+		if i < 0 {
+			return params
+		}
+		k := strings.ToLower(strings.TrimSpace(s[:i]))
+		s = strings.TrimLeft(s[i+1:], " \t")
+		var v string
+		if strings.HasPrefix(s, `"`) {
+			b := new(strings.Builder)
+			j := 1
+			for ; j < len(s); j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+					_ = b.WriteByte(s[j])
+					continue
+				}
+				if s[j] == '"' {
+					break
+				}
+				_ = b.WriteByte(s[j])
+			}
+			v = b.String()
+			if j < len(s) {
+				j++
+			}
+			s = s[j:]
+		} else {
+			j := strings.Index(s, ",")
+			if j < 0 {
+				j = len(s)
+			}
+			v = strings.TrimSpace(s[:j])
+			s = s[j:]
+		}
+		params[k] = v
+	}
+}
+
+func (dc *digestChallenge) authorization(username, password, method, uri string) (string, error) {
+	var newHash func() hash.Hash
+	algorithm := strings.ToUpper(dc.algorithm)
+	switch algorithm {
+	case "", "MD5", "MD5-SESS":
+		newHash = md5.New
+	case "SHA-256", "SHA-256-SESS":
+		newHash = sha256.New
+	default:
+		return "", fmt.Errorf("unsupported digest algorithm: %s", dc.algorithm)
+	}
+	h := func(s string) string {
+		hh := newHash()
+		_, _ = hh.Write([]byte(s))
+		return hex.EncodeToString(hh.Sum(nil))
+	}
+	cnonce, err := digestCnonce()
+	if err != nil {
+		return "", err
+	}
+	nc := fmt.Sprintf("%08x", dc.nc)
+	ha1 := h(fmt.Sprintf("%s:%s:%s", username, dc.realm, password))
+	if strings.HasSuffix(algorithm, "-SESS") {
+		ha1 = h(fmt.Sprintf("%s:%s:%s", ha1, dc.nonce, cnonce))
+	}
+	ha2 := h(fmt.Sprintf("%s:%s", method, uri))
+	var response string
+	if dc.qop == "" {
+		response = h(fmt.Sprintf("%s:%s:%s", ha1, dc.nonce, ha2))
+	} else {
+		response = h(fmt.Sprintf("%s:%s:%s:%s:%s:%s", ha1, dc.nonce, nc, cnonce, dc.qop, ha2))
+	}
+	params := []string{
+		fmt.Sprintf(`username="%s"`, username), //nostyle:useq
+		fmt.Sprintf(`realm="%s"`, dc.realm),    //nostyle:useq
+		fmt.Sprintf(`nonce="%s"`, dc.nonce),    //nostyle:useq
+		fmt.Sprintf(`uri="%s"`, uri),           //nostyle:useq
+	}
+	if dc.algorithm != "" {
+		params = append(params, fmt.Sprintf("algorithm=%s", dc.algorithm))
+	}
+	if dc.qop != "" {
+		params = append(params, fmt.Sprintf("qop=%s", dc.qop), fmt.Sprintf("nc=%s", nc), fmt.Sprintf(`cnonce="%s"`, cnonce)) //nostyle:useq
+	}
+	params = append(params, fmt.Sprintf(`response="%s"`, response)) //nostyle:useq
+	if dc.opaque != "" {
+		params = append(params, fmt.Sprintf(`opaque="%s"`, dc.opaque)) //nostyle:useq
+	}
+	return "Digest " + strings.Join(params, ", "), nil
+}
+
+func digestCnonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,560 @@
+package runn
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5" //nolint:gosec
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHTTPAuth(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  httpRunnerOption
+		vars map[string]any
+		want string
+	}{
+		{
+			"basic",
+			HTTPBasicAuth("alice", "passw0rd"),
+			nil,
+			"Basic YWxpY2U6cGFzc3cwcmQ=",
+		},
+		{
+			"bearer",
+			HTTPBearerAuth("xxxxx"),
+			nil,
+			"Bearer xxxxx",
+		},
+		{
+			"bearer from var",
+			HTTPBearerAuth("{{ vars.token }}"),
+			map[string]any{"token": "yyyyy"},
+			"Bearer yyyyy",
+		},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Get("Authorization")
+				w.WriteHeader(http.StatusOK)
+			})
+			opts := []Option{HTTPRunnerWithHandler("req", h, tt.opt)}
+			for k, v := range tt.vars {
+				opts = append(opts, Var(k, v))
+			}
+			o, err := New(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := o.httpRunners["req"]
+			r.operator = o
+			if err := r.Run(ctx, &httpRequest{path: "/", method: http.MethodGet}); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPAuthDigest(t *testing.T) {
+	const (
+		realm    = "runn@example.com"
+		nonce    = "dcd98b7102dd2f0e8b11d0f600bfb0c093"
+		username = "alice"
+		password = "passw0rd"
+	)
+	h := func(s string) string {
+		b := md5.Sum([]byte(s)) //nolint:gosec
+		return hex.EncodeToString(b[:])
+	}
+	var (
+		challenged int
+		ncs        []string
+	)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := map[string]string{}
+		if a := r.Header.Get("Authorization"); len(a) > 7 && a[:7] == "Digest " {
+			params = parseAuthParams(a[7:])
+		}
+		ha1 := h(fmt.Sprintf("%s:%s:%s", username, realm, password))
+		ha2 := h(fmt.Sprintf("%s:%s", r.Method, params["uri"]))
+		want := h(fmt.Sprintf("%s:%s:%s:%s:%s:%s", ha1, nonce, params["nc"], params["cnonce"], params["qop"], ha2))
+		if params["response"] == "" || params["response"] != want || params["uri"] != r.URL.RequestURI() {
+			challenged++
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm="%s", qop="auth,auth-int", nonce="%s", opaque="5ccc069c403ebaf9f0171e9517f40e41"`, realm, nonce)) //nostyle:useq
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		ncs = append(ncs, params["nc"])
+		w.WriteHeader(http.StatusOK)
+	})
+	ctx := context.Background()
+	o, err := New(HTTPRunnerWithHandler("req", handler, HTTPDigestAuth(username, password)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := o.httpRunners["req"]
+	r.operator = o
+	for i := 0; i < 2; i++ {
+		if err := r.Run(ctx, &httpRequest{path: "/dir/index.html?page=1", method: http.MethodGet}); err != nil {
+			t.Fatal(err)
+		}
+		res, ok := o.store.latest()["res"].(map[string]any)
+		if !ok {
+			t.Fatalf("invalid res: %#v", o.store.latest()["res"])
+		}
+		if res["status"] != http.StatusOK {
+			t.Errorf("got %v\nwant %v", res["status"], http.StatusOK)
+		}
+	}
+	if challenged != 1 {
+		t.Errorf("got %v\nwant %v", challenged, 1)
+	}
+	if diff := cmp.Diff(ncs, []string{"00000001", "00000002"}); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestHTTPAuthOAuth2(t *testing.T) {
+	tests := []struct {
+		name      string
+		opt       func(tokenURL string) httpRunnerOption
+		expiresIn int
+		wantForm  map[string]string
+		wantFetch int
+	}{
+		{
+			"client credentials",
+			func(tokenURL string) httpRunnerOption {
+				return HTTPOAuth2ClientCredentials(tokenURL, "client", "secret", "read", "write")
+			},
+			3600,
+			map[string]string{"grant_type": "client_credentials", "scope": "read write"},
+			1,
+		},
+		{
+			"password",
+			func(tokenURL string) httpRunnerOption {
+				return HTTPOAuth2Password(tokenURL, "client", "secret", "alice", "passw0rd")
+			},
+			3600,
+			map[string]string{"grant_type": "password", "username": "alice", "password": "passw0rd"},
+			1,
+		},
+		{
+			"expired",
+			func(tokenURL string) httpRunnerOption {
+				return HTTPOAuth2ClientCredentials(tokenURL, "client", "secret", "expired")
+			},
+			1,
+			map[string]string{"grant_type": "client_credentials", "scope": "expired"},
+			2,
+		},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu      sync.Mutex
+				fetched int
+				form    map[string]string
+			)
+			mux := http.NewServeMux()
+			mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				if id, secret, ok := r.BasicAuth(); !ok || id != "client" || secret != "secret" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				if err := r.ParseForm(); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				form = map[string]string{}
+				for k := range r.PostForm {
+					form[k] = r.PostForm.Get(k)
+				}
+				fetched++
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":%d}`, fetched, tt.expiresIn)
+			})
+			mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				if r.Header.Get("Authorization") != fmt.Sprintf("Bearer token-%d", fetched) {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			})
+			ts := httptest.NewServer(mux)
+			t.Cleanup(func() {
+				ts.Close()
+			})
+			o, err := New(HTTPRunner("req", ts.URL, ts.Client(), tt.opt(ts.URL+"/token")))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := o.httpRunners["req"]
+			r.operator = o
+			for i := 0; i < 2; i++ {
+				if err := r.Run(ctx, &httpRequest{path: "/users", method: http.MethodGet}); err != nil {
+					t.Fatal(err)
+				}
+				res, ok := o.store.latest()["res"].(map[string]any)
+				if !ok {
+					t.Fatalf("invalid res: %#v", o.store.latest()["res"])
+				}
+				if res["status"] != http.StatusOK {
+					t.Errorf("got %v\nwant %v", res["status"], http.StatusOK)
+				}
+			}
+			if fetched != tt.wantFetch {
+				t.Errorf("got %v\nwant %v", fetched, tt.wantFetch)
+			}
+			if diff := cmp.Diff(form, tt.wantForm); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestHTTPAuthOAuth2RefreshOnUnauthorized(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		fetched int
+		revoked bool
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fetched++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer"}`, fetched)
+	})
+	mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if revoked || r.Header.Get("Authorization") != fmt.Sprintf("Bearer token-%d", fetched) {
+			revoked = false
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	ts := httptest.NewServer(mux)
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	ctx := context.Background()
+	o, err := New(HTTPRunner("req", ts.URL, ts.Client(), HTTPOAuth2ClientCredentials(ts.URL+"/token", "client", "secret", "refresh")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := o.httpRunners["req"]
+	r.operator = o
+	for i := 0; i < 3; i++ {
+		if i == 1 {
+			mu.Lock()
+			revoked = true
+			mu.Unlock()
+		}
+		if err := r.Run(ctx, &httpRequest{path: "/users", method: http.MethodGet}); err != nil {
+			t.Fatal(err)
+		}
+		res, ok := o.store.latest()["res"].(map[string]any)
+		if !ok {
+			t.Fatalf("invalid res: %#v", o.store.latest()["res"])
+		}
+		if res["status"] != http.StatusOK {
+			t.Errorf("got %v\nwant %v", res["status"], http.StatusOK)
+		}
+	}
+	if fetched != 2 {
+		t.Errorf("got %v\nwant %v", fetched, 2)
+	}
+}
+
+func TestHTTPAuthCapture(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		fetched int
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fetched++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer"}`, fetched)
+	})
+	mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		// The first token is revoked
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	ts := httptest.NewServer(mux)
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	out := new(bytes.Buffer)
+	o, err := New(HTTPRunner("req", ts.URL, ts.Client(), HTTPOAuth2ClientCredentials(ts.URL+"/token", "client", "secret")), Capture(NewDebugger(out)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := o.httpRunners["req"]
+	r.operator = o
+	if err := r.Run(context.Background(), &httpRequest{path: "/users", method: http.MethodGet}); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	if strings.Contains(got, "Authorization") || strings.Contains(got, "token-") {
+		t.Errorf("credentials should not be captured\n%s", got)
+	}
+	if c := strings.Count(got, "-----START HTTP REQUEST-----"); c != 1 {
+		t.Errorf("got %v requests captured\nwant %v", c, 1)
+	}
+	if c := strings.Count(got, "-----START HTTP RESPONSE-----"); c != 1 {
+		t.Errorf("got %v responses captured\nwant %v", c, 1)
+	}
+	if !strings.Contains(got, "200 OK") || strings.Contains(got, "401") {
+		t.Errorf("only the response of the retry should be captured\n%s", got)
+	}
+}
+
+func TestHTTPAuthOAuth2TokenCacheLock(t *testing.T) {
+	c := &oauth2TokenCache{entries: map[string]*oauth2TokenEntry{}}
+	fetching := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = c.token("slow", func(_ *oauth2Token) (*oauth2Token, error) {
+			close(fetching)
+			<-release
+			return &oauth2Token{AccessToken: "slow"}, nil
+		})
+	}()
+	<-fetching
+
+	// Fetching a token for other credentials is not blocked
+	got := make(chan *oauth2Token)
+	go func() {
+		t, _ := c.token("fast", func(_ *oauth2Token) (*oauth2Token, error) {
+			return &oauth2Token{AccessToken: "fast"}, nil
+		})
+		got <- t
+	}()
+	select {
+	case tk := <-got:
+		if tk.AccessToken != "fast" {
+			t.Errorf("got %v\nwant %v", tk.AccessToken, "fast")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("fetching a token for other credentials is blocked")
+	}
+	close(release)
+	<-done
+
+	// The token for the same credentials is fetched only once
+	tk, err := c.token("slow", func(_ *oauth2Token) (*oauth2Token, error) {
+		return nil, errors.New("should not be fetched")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tk.AccessToken != "slow" {
+		t.Errorf("got %v\nwant %v", tk.AccessToken, "slow")
+	}
+}
+
+func TestHTTPAuthOAuth2Cassette(t *testing.T) {
+	var fetched, count int64
+	h := http.NewServeMux()
+	h.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&fetched, 1)
+		w.Header().Set("Content-Type", MediaTypeApplicationJSON)
+		_, _ = w.Write([]byte(`{"access_token":"access-token","refresh_token":"refresh-token","token_type":"Bearer","expires_in":3600}`))
+	})
+	h.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&count, 1)
+		w.Header().Set("Content-Type", MediaTypeApplicationJSON)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":1}`))
+	})
+	h.HandleFunc("/users/1", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&count, 1)
+		w.Header().Set("Content-Type", MediaTypeApplicationJSON)
+		_, _ = w.Write([]byte(`{"id":1,"name":"alice"}`))
+	})
+	var (
+		mu    sync.Mutex
+		paths []string
+	)
+	opts := func(password string) []httpRunnerOption {
+		return []httpRunnerOption{
+			HTTPOAuth2Password("https://example.com/token", "client", "client-secret", "alice", password),
+			HTTPMiddlewares(func(next http.RoundTripper) http.RoundTripper {
+				return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					mu.Lock()
+					paths = append(paths, req.URL.Path)
+					mu.Unlock()
+					return next.RoundTrip(req)
+				})
+			}),
+		}
+	}
+	book := "testdata/book/cassette.yml"
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	o, err := New(Book(book), HTTPRunnerWithHandler("req", h, opts("passw0rd")...), Record(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(paths, []string{"/token", "/users", "/users/1"}); diff != "" {
+		t.Error(diff)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, o.id+cassetteExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"client-secret", "passw0rd", "access-token", "refresh-token"} {
+		if strings.Contains(string(b), s) {
+			t.Errorf("cassette should not contain %q\ngot: %s", s, string(b))
+		}
+	}
+
+	// Replay with other credentials that are not cached yet
+	atomic.StoreInt64(&fetched, 0)
+	atomic.StoreInt64(&count, 0)
+	o, err = New(Book(book), HTTPRunnerWithHandler("req", h, opts("another")...), Replay(dir), ReplayStrict(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Run(ctx); err != nil {
+		t.Error(err)
+	}
+	if got := atomic.LoadInt64(&fetched); got != 0 {
+		t.Errorf("got %v\nwant %v", got, 0)
+	}
+	if got := atomic.LoadInt64(&count); got != 0 {
+		t.Errorf("got %v\nwant %v", got, 0)
+	}
+}
+
+func TestParseRunnerWithAuth(t *testing.T) {
+	tests := []struct {
+		v       any
+		want    *httpAuthConfig
+		wantErr bool
+	}{
+		{
+			map[string]any{
+				"endpoint": "https://example.com",
+				"auth": map[string]any{
+					"type":     "basic",
+					"username": "alice",
+					"password": "passw0rd",
+				},
+			},
+			&httpAuthConfig{Type: "basic", Username: "alice", Password: "passw0rd"},
+			false,
+		},
+		{
+			map[string]any{
+				"endpoint": "https://example.com",
+				"auth": map[string]any{
+					"type":         "oauth2",
+					"grantType":    "client_credentials",
+					"tokenURL":     "https://auth.example.com/token",
+					"clientID":     "client",
+					"clientSecret": "secret",
+					"scopes":       []any{"read"},
+				},
+			},
+			&httpAuthConfig{Type: "oauth2", GrantType: "client_credentials", TokenURL: "https://auth.example.com/token", ClientID: "client", ClientSecret: "secret", Scopes: []string{"read"}},
+			false,
+		},
+		{
+			map[string]any{
+				"endpoint": "https://example.com",
+				"auth": map[string]any{
+					"type": "bearer",
+				},
+			},
+			nil,
+			true,
+		},
+		{
+			map[string]any{
+				"endpoint": "https://example.com",
+				"auth": map[string]any{
+					"type":      "oauth2",
+					"grantType": "implicit",
+					"tokenURL":  "https://auth.example.com/token",
+				},
+			},
+			nil,
+			true,
+		},
+		{
+			map[string]any{
+				"endpoint": "https://example.com",
+				"auth": map[string]any{
+					"type": "ntlm",
+				},
+			},
+			nil,
+			true,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			bk := newBook()
+			err := bk.parseRunner("req", tt.v)
+			if err != nil {
+				if !tt.wantErr {
+					t.Errorf("got error: %v", err)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Error("want error")
+				return
+			}
+			got := bk.httpRunners["req"].auth.config
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package runn
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	httpStoreCompressionKey                 = "compression"
+	httpStoreCompressionEncodingKey         = "encoding"
+	httpStoreCompressionCompressedSizeKey   = "compressedSize"
+	httpStoreCompressionUncompressedSizeKey = "uncompressedSize"
+)
+
+const (
+	contentEncodingGzip     = "gzip"
+	contentEncodingDeflate  = "deflate"
+	contentEncodingBrotli   = "br"
+	contentEncodingZstd     = "zstd"
+	contentEncodingIdentity = "identity"
+)
+
+var supportedContentEncodings = []string{contentEncodingGzip, contentEncodingDeflate, contentEncodingBrotli, contentEncodingZstd}
+
+// countReader counts the number of bytes read.
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// decompressedBody is the response body decoded according to Content-Encoding.
+type decompressedBody struct {
+	encoding     string
+	compressed   *countReader
+	uncompressed *countReader
+	closers      []io.Closer
+}
+
+func (b *decompressedBody) Read(p []byte) (int, error) {
+	return b.uncompressed.Read(p)
+}
+
+func (b *decompressedBody) Close() error {
+	var err error
+	for i := len(b.closers) - 1; i >= 0; i-- {
+		if cerr := b.closers[i].Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// toMap returns the encoding and the sizes of the body read so far.
+func (b *decompressedBody) toMap() map[string]any {
+	m := map[string]any{
+		httpStoreCompressionEncodingKey:         b.encoding,
+		httpStoreCompressionUncompressedSizeKey: b.uncompressed.n,
+	}
+	if b.compressed != nil {
+		m[httpStoreCompressionCompressedSizeKey] = b.compressed.n
+	}
+	return m
+}
+
+// decompressBody replaces the response body with the body decoded according to Content-Encoding.
+// It returns nil if the body is not encoded or the encoding is not supported.
+func (rnr *httpRunner) decompressBody(res *http.Response) (*decompressedBody, error) {
+	if res.Uncompressed {
+		// Already decompressed by http.Transport not found by setAcceptEncoding ( e.g. wrapped by custom transports ), so the compressed size is unknown
+		b := &decompressedBody{
+			encoding:     contentEncodingGzip,
+			uncompressed: &countReader{r: res.Body},
+			closers:      []io.Closer{res.Body},
+		}
+		res.Body = b
+		return b, nil
+	}
+	ce := res.Header.Get("Content-Encoding")
+	if ce == "" {
+		return nil, nil
+	}
+	var encodings []string
+	for _, e := range strings.Split(ce, ",") {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if e == "" || e == contentEncodingIdentity {
+			continue
+		}
+		if !contains(supportedContentEncodings, e) {
+			rnr.operator.Debugf("Skip decompressing the response body due to unsupported Content-Encoding: %s\n", ce)
+			return nil, nil
+		}
+		encodings = append(encodings, e)
+	}
+	if len(encodings) == 0 {
+		return nil, nil
+	}
+	b := &decompressedBody{
+		encoding:   strings.Join(encodings, ", "),
+		compressed: &countReader{r: res.Body},
+		closers:    []io.Closer{res.Body},
+	}
+	br := bufio.NewReader(b.compressed)
+	if _, err := br.Peek(1); errors.Is(err, io.EOF) {
+		// Empty body ( e.g. 204 No Content ) cannot be decompressed
+		b.uncompressed = &countReader{r: br}
+		res.Body = b
+		return b, nil
+	}
+	var r io.Reader = br
+	// Encodings are listed in the order in which they were applied
+	for i := len(encodings) - 1; i >= 0; i-- {
+		dr, err := newDecompressReader(encodings[i], r)
+		if err != nil {
+			_ = b.Close()
+			return nil, fmt.Errorf("failed to decompress the response body (%s): %w", encodings[i], err)
+		}
+		b.closers = append(b.closers, dr)
+		r = dr
+	}
+	b.uncompressed = &countReader{r: r}
+	res.Body = b
+	return b, nil
+}
+
+// setAcceptEncoding sets Accept-Encoding: gzip to the request in the same way as http.Transport does.
+// Since http.Transport does not decompress the response transparently when Accept-Encoding is set explicitly, runn decompresses the response itself and records the compressed size.
+func (rnr *httpRunner) setAcceptEncoding(req *http.Request) {
+	ts, ok := baseTransport(rnr.client.Transport)
+	if !ok || ts.DisableCompression {
+		return
+	}
+	if req.Header.Get("Accept-Encoding") != "" || req.Header.Get("Range") != "" || req.Method == http.MethodHead {
+		return
+	}
+	req.Header.Set("Accept-Encoding", contentEncodingGzip)
+}
+
+func newDecompressReader(encoding string, r io.Reader) (io.ReadCloser, error) {
+	switch encoding {
+	case contentEncodingGzip:
+		return gzip.NewReader(r)
+	case contentEncodingDeflate:
+		// "deflate" should be zlib-wrapped, but some servers send raw deflate
+		br := bufio.NewReader(r)
+		h, err := br.Peek(2)
+		if err == nil && isZlibHeader(h) {
+			return zlib.NewReader(br)
+		}
+		return flate.NewReader(br), nil
+	case contentEncodingBrotli:
+		return io.NopCloser(brotli.NewReader(r)), nil
+	case contentEncodingZstd:
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", encoding)
+	}
+}
+
+// isZlibHeader reports whether h is the header of zlib ( RFC 1950 ).
+func isZlibHeader(h []byte) bool {
+	return h[0]&0x0f == 8 && (uint16(h[0])<<8|uint16(h[1]))%31 == 0
+}
+
+// compress encodes the request body using the encoding.
+func compress(encoding string, body io.Reader) (*bytes.Buffer, error) {
+	buf := new(bytes.Buffer)
+	var (
+		w   io.WriteCloser
+		err error
+	)
+	switch encoding {
+	case contentEncodingGzip:
+		w = gzip.NewWriter(buf)
+	case contentEncodingDeflate:
+		w = zlib.NewWriter(buf)
+	case contentEncodingBrotli:
+		w = brotli.NewWriter(buf)
+	case contentEncodingZstd:
+		w, err = zstd.NewWriter(buf)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported compress: %s", encoding)
+	}
+	if body != nil {
+		if _, err := io.Copy(w, body); err != nil {
+			return nil, fmt.Errorf("failed to compress the request body: %w", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress the request body: %w", err)
+	}
+	return buf, nil
+}
@@ -0,0 +1,296 @@
+package runn
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHTTPRunnerDecompressBody(t *testing.T) {
+	const body = `{"name":"alice"}`
+	encode := func(t *testing.T, encodings ...string) []byte {
+		t.Helper()
+		var r io.Reader = strings.NewReader(body)
+		for _, e := range encodings {
+			if e == "rawdeflate" {
+				buf := new(bytes.Buffer)
+				w, err := flate.NewWriter(buf, flate.DefaultCompression)
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, _ = io.Copy(w, r)
+				_ = w.Close()
+				r = buf
+				continue
+			}
+			buf, err := compress(e, r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r = buf
+		}
+		b, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	tests := []struct {
+		name            string
+		contentEncoding string
+		body            []byte
+		wantCompression bool
+		wantEncoding    string
+	}{
+		{"gzip", "gzip", encode(t, "gzip"), true, "gzip"},
+		{"deflate", "deflate", encode(t, "deflate"), true, "deflate"},
+		{"raw deflate", "deflate", encode(t, "rawdeflate"), true, "deflate"},
+		{"br", "br", encode(t, "br"), true, "br"},
+		{"zstd", "zstd", encode(t, "zstd"), true, "zstd"},
+		{"multiple encodings", "gzip, br", encode(t, "gzip", "br"), true, "gzip, br"},
+		{"uppercase", "GZIP", encode(t, "gzip"), true, "gzip"},
+		{"identity", "identity", []byte(body), false, ""},
+		{"unsupported", "compress", []byte(body), false, ""},
+		{"empty body", "gzip", []byte{}, true, "gzip"},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", MediaTypeApplicationJSON)
+				w.Header().Set("Content-Encoding", tt.contentEncoding)
+				_, _ = w.Write(tt.body)
+			})
+			o, err := New(HTTPRunnerWithHandler("req", h))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, ok := o.httpRunners["req"]
+			if !ok {
+				t.Fatal("http runner not found")
+			}
+			r.operator = o
+			req := &httpRequest{
+				path:    "/",
+				method:  http.MethodGet,
+				headers: http.Header{},
+			}
+			if err := r.Run(ctx, req); err != nil {
+				t.Fatal(err)
+			}
+			res, ok := o.store.latest()["res"].(map[string]any)
+			if !ok {
+				t.Fatalf("invalid res: %#v", o.store.latest()["res"])
+			}
+			c, ok := res[httpStoreCompressionKey].(map[string]any)
+			if ok != tt.wantCompression {
+				t.Fatalf("got %v\nwant %v", res[httpStoreCompressionKey], tt.wantCompression)
+			}
+			if !tt.wantCompression {
+				return
+			}
+			want := body
+			if len(tt.body) == 0 {
+				want = ""
+			}
+			if got := res["rawBody"]; got != want {
+				t.Errorf("got %v\nwant %v", got, want)
+			}
+			wantc := map[string]any{
+				httpStoreCompressionEncodingKey:         tt.wantEncoding,
+				httpStoreCompressionCompressedSizeKey:   int64(len(tt.body)),
+				httpStoreCompressionUncompressedSizeKey: int64(len(want)),
+			}
+			if diff := cmp.Diff(c, wantc); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestHTTPRunnerAcceptEncoding(t *testing.T) {
+	const body = `{"name":"alice"}`
+	gz, err := compress(contentEncodingGzip, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed := gz.Bytes()
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", MediaTypeApplicationJSON)
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), contentEncodingGzip) {
+			_, _ = w.Write([]byte(body))
+			return
+		}
+		w.Header().Set("Content-Encoding", contentEncodingGzip)
+		_, _ = w.Write(compressed)
+	})
+	ts := httptest.NewServer(h)
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	tests := []struct {
+		name               string
+		disableCompression bool
+		want               map[string]any
+	}{
+		{
+			"gzip is requested and decompressed by runn",
+			false,
+			map[string]any{
+				httpStoreCompressionEncodingKey:         contentEncodingGzip,
+				httpStoreCompressionCompressedSizeKey:   int64(len(compressed)),
+				httpStoreCompressionUncompressedSizeKey: int64(len(body)),
+			},
+		},
+		{
+			"DisableCompression",
+			true,
+			nil,
+		},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := ts.Client()
+			tr, ok := client.Transport.(*http.Transport)
+			if !ok {
+				t.Fatalf("invalid transport: %T", client.Transport)
+			}
+			tr = tr.Clone()
+			tr.DisableCompression = tt.disableCompression
+			client.Transport = tr
+			o, err := New(HTTPRunner("req", ts.URL, client))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := o.httpRunners["req"]
+			r.operator = o
+			req := &httpRequest{
+				path:    "/",
+				method:  http.MethodGet,
+				headers: http.Header{},
+			}
+			if err := r.Run(ctx, req); err != nil {
+				t.Fatal(err)
+			}
+			res, ok := o.store.latest()["res"].(map[string]any)
+			if !ok {
+				t.Fatalf("invalid res: %#v", o.store.latest()["res"])
+			}
+			if got := res["rawBody"]; got != body {
+				t.Errorf("got %v\nwant %v", got, body)
+			}
+			got, _ := res[httpStoreCompressionKey].(map[string]any)
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestHTTPRunnerDecompressBodyInvalid(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		_, _ = w.Write([]byte("not gzip"))
+	})
+	o, err := New(HTTPRunnerWithHandler("req", h))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := o.httpRunners["req"]
+	r.operator = o
+	req := &httpRequest{
+		path:    "/",
+		method:  http.MethodGet,
+		headers: http.Header{},
+	}
+	if err := r.Run(context.Background(), req); err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestHTTPRunnerCompressRequestBody(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in io.Reader = r.Body
+		if ce := r.Header.Get("Content-Encoding"); ce != "" {
+			dr, err := newDecompressReader(ce, r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			defer dr.Close()
+			in = dr
+		}
+		got, err := io.ReadAll(in)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", MediaTypeTextPlain)
+		_, _ = w.Write([]byte(r.Header.Get("Content-Encoding") + ":" + string(got)))
+	})
+	tests := []struct {
+		compress string
+		headers  http.Header
+		want     string
+	}{
+		{"", http.Header{}, `:{"name":"alice"}`},
+		{"gzip", http.Header{}, `gzip:{"name":"alice"}`},
+		{"deflate", http.Header{}, `deflate:{"name":"alice"}`},
+		{"br", http.Header{}, `br:{"name":"alice"}`},
+		{"zstd", http.Header{}, `zstd:{"name":"alice"}`},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.compress, func(t *testing.T) {
+			o, err := New(HTTPRunnerWithHandler("req", h))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := o.httpRunners["req"]
+			r.operator = o
+			req := &httpRequest{
+				path:      "/",
+				method:    http.MethodPost,
+				headers:   tt.headers,
+				mediaType: MediaTypeApplicationJSON,
+				body:      map[string]any{"name": "alice"},
+				compress:  tt.compress,
+			}
+			if err := req.validate(); err != nil {
+				t.Fatal(err)
+			}
+			if err := r.Run(ctx, req); err != nil {
+				t.Fatal(err)
+			}
+			res, ok := o.store.latest()["res"].(map[string]any)
+			if !ok {
+				t.Fatalf("invalid res: %#v", o.store.latest()["res"])
+			}
+			if got := res["rawBody"]; got != tt.want {
+				t.Errorf("got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPRequestValidateCompress(t *testing.T) {
+	req := &httpRequest{
+		path:      "/",
+		method:    http.MethodPost,
+		headers:   http.Header{},
+		mediaType: MediaTypeApplicationJSON,
+		body:      map[string]any{"name": "alice"},
+		compress:  "lzma",
+	}
+	if err := req.validate(); err == nil {
+		t.Error("want error")
+	}
+}
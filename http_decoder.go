@@ -0,0 +1,233 @@
+package runn
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/goccy/go-yaml"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	xmlAttrPrefix = "@"
+	xmlTextKey    = "#text"
+)
+
+// httpResponseDecodeFunc decodes HTTP response body to the value to be recorded as `res.body`.
+type httpResponseDecodeFunc func(b []byte) (any, error)
+
+// defaultHTTPResponseDecoders is the registry of response decoders keyed on media type.
+var defaultHTTPResponseDecoders = map[string]httpResponseDecodeFunc{
+	MediaTypeApplicationJSON:           decodeJSONBody,
+	MediaTypeApplicationXML:            decodeXMLBody,
+	MediaTypeTextXML:                   decodeXMLBody,
+	MediaTypeApplicationYAML:           decodeYAMLBody,
+	"application/x-yaml":               decodeYAMLBody,
+	"text/yaml":                        decodeYAMLBody,
+	"text/x-yaml":                      decodeYAMLBody,
+	MediaTypeApplicationFormUrlencoded: decodeFormUrlencodedBody,
+	MediaTypeTextCSV:                   decodeCSVBody,
+	MediaTypeApplicationMsgpack:        decodeMsgpackBody,
+	"application/x-msgpack":            decodeMsgpackBody,
+}
+
+// structuredSyntaxSuffixes maps structured syntax suffixes ( RFC 6839 ) to the media type of the decoder.
+var structuredSyntaxSuffixes = map[string]string{
+	"+json":    MediaTypeApplicationJSON,
+	"+xml":     MediaTypeApplicationXML,
+	"+yaml":    MediaTypeApplicationYAML,
+	"+msgpack": MediaTypeApplicationMsgpack,
+}
+
+func (rnr *httpRunner) decodeBody(contentType string, b []byte) (any, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	dec, ok := rnr.lookupDecoder(contentType)
+	if !ok {
+		return nil, nil
+	}
+	v, err := dec(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode response body (%s): %w", contentType, err)
+	}
+	return v, nil
+}
+
+// isJSONContentType reports whether the content type is JSON ( including structured syntax suffix +json ).
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+	return strings.Contains(mediaType, "json")
+}
+
+func (rnr *httpRunner) lookupDecoder(contentType string) (httpResponseDecodeFunc, bool) {
+	if contentType == "" {
+		return nil, false
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+	if dec, ok := rnr.decoder(mediaType); ok {
+		return dec, true
+	}
+	for suffix, mt := range structuredSyntaxSuffixes {
+		if strings.HasSuffix(mediaType, suffix) {
+			return rnr.decoder(mt)
+		}
+	}
+	// Keep compatibility with media types such as `application/json-patch+json` or `text/x-json`
+	if strings.Contains(mediaType, "json") {
+		return rnr.decoder(MediaTypeApplicationJSON)
+	}
+	return nil, false
+}
+
+func (rnr *httpRunner) decoder(mediaType string) (httpResponseDecodeFunc, bool) {
+	if dec, ok := rnr.decoders[mediaType]; ok {
+		return dec, true
+	}
+	dec, ok := defaultHTTPResponseDecoders[mediaType]
+	return dec, ok
+}
+
+func decodeJSONBody(b []byte) (any, error) {
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func decodeYAMLBody(b []byte) (any, error) {
+	var v any
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func decodeFormUrlencodedBody(b []byte) (any, error) {
+	values, err := url.ParseQuery(string(b))
+	if err != nil {
+		return nil, err
+	}
+	v := map[string]any{}
+	for k, vs := range values {
+		if len(vs) == 1 {
+			v[k] = vs[0]
+			continue
+		}
+		s := make([]any, 0, len(vs))
+		for _, vv := range vs {
+			s = append(s, vv)
+		}
+		v[k] = s
+	}
+	return v, nil
+}
+
+func decodeCSVBody(b []byte) (any, error) {
+	r := csv.NewReader(bytes.NewReader(b))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	v := make([]any, 0, len(records))
+	for _, record := range records {
+		row := make([]any, 0, len(record))
+		for _, f := range record {
+			row = append(row, f)
+		}
+		v = append(v, row)
+	}
+	return v, nil
+}
+
+func decodeMsgpackBody(b []byte) (any, error) {
+	dec := msgpack.NewDecoder(bytes.NewReader(b))
+	dec.UseLooseInterfaceDecoding(true)
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// decodeXMLBody decodes XML to map.
+// Attributes are stored with the prefix `@`, and text of the element that has attributes or child elements is stored in `#text`.
+// Child elements with the same name are stored as a list.
+func decodeXMLBody(b []byte) (any, error) {
+	d := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, errors.New("root element not found")
+			}
+			return nil, err
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		v, err := decodeXMLElement(d, se)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{se.Name.Local: v}, nil
+	}
+}
+
+func decodeXMLElement(d *xml.Decoder, start xml.StartElement) (any, error) {
+	m := map[string]any{}
+	for _, a := range start.Attr {
+		m[xmlAttrPrefix+a.Name.Local] = a.Value
+	}
+	text := new(strings.Builder)
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			v, err := decodeXMLElement(d, t)
+			if err != nil {
+				return nil, err
+			}
+			k := t.Name.Local
+			switch e := m[k].(type) {
+			case nil:
+				m[k] = v
+			case []any:
+				m[k] = append(e, v)
+			default:
+				m[k] = []any{e, v}
+			}
+		case xml.CharData:
+			_, _ = text.Write(t)
+		case xml.EndElement:
+			s := strings.TrimSpace(text.String())
+			if len(m) == 0 {
+				return s, nil
+			}
+			if s != "" {
+				m[xmlTextKey] = s
+			}
+			return m, nil
+		}
+	}
+}
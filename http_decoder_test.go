@@ -0,0 +1,196 @@
+package runn
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestHTTPRunnerDecodeResponseBody(t *testing.T) {
+	mp, err := msgpack.Marshal(map[string]any{"username": "alice", "age": 20, "tags": []string{"a", "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		contentType string
+		body        []byte
+		want        any
+	}{
+		{
+			"application/json",
+			[]byte(`{"username":"alice"}`),
+			map[string]any{"username": "alice"},
+		},
+		{
+			"application/problem+json; charset=utf-8",
+			[]byte(`{"title":"Not Found"}`),
+			map[string]any{"title": "Not Found"},
+		},
+		{
+			"application/xml",
+			[]byte(`<?xml version="1.0" encoding="UTF-8"?><user id="1"><name>alice</name><tag>a</tag><tag>b</tag></user>`),
+			map[string]any{"user": map[string]any{"@id": "1", "name": "alice", "tag": []any{"a", "b"}}},
+		},
+		{
+			"text/xml; charset=utf-8",
+			[]byte(`<message lang="en">hello</message>`),
+			map[string]any{"message": map[string]any{"@lang": "en", "#text": "hello"}},
+		},
+		{
+			"application/yaml",
+			[]byte("username: alice\ntags:\n  - a\n  - b\n"),
+			map[string]any{"username": "alice", "tags": []any{"a", "b"}},
+		},
+		{
+			"application/x-www-form-urlencoded",
+			[]byte("username=alice&tag=a&tag=b"),
+			map[string]any{"username": "alice", "tag": []any{"a", "b"}},
+		},
+		{
+			"text/csv",
+			[]byte("id,name\n1,alice\n2,bob\n"),
+			[]any{[]any{"id", "name"}, []any{"1", "alice"}, []any{"2", "bob"}},
+		},
+		{
+			"application/msgpack",
+			mp,
+			map[string]any{"username": "alice", "age": int64(20), "tags": []any{"a", "b"}},
+		},
+		{
+			"text/plain",
+			[]byte("hello"),
+			nil,
+		},
+		{
+			"application/xml",
+			[]byte{},
+			nil,
+		},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			o, err := New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write(tt.body)
+			})
+			r, err := newHTTPRunnerWithHandler("req", h)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.operator = o
+			req := &httpRequest{
+				path:   "/",
+				method: http.MethodGet,
+			}
+			if err := r.Run(ctx, req); err != nil {
+				t.Fatal(err)
+			}
+			res, ok := r.operator.store.latest()["res"].(map[string]any)
+			if !ok {
+				t.Fatalf("invalid res: %#v", r.operator.store.latest()["res"])
+			}
+			got := res["body"]
+			if diff := cmp.Diff(got, tt.want, nil); diff != "" {
+				t.Error(diff)
+			}
+			if res["rawBody"] != string(tt.body) {
+				t.Errorf("got %v\nwant %v", res["rawBody"], string(tt.body))
+			}
+		})
+	}
+}
+
+func TestHTTPRunnerDecodeResponseBodyError(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+		wantErr     bool
+	}{
+		{"application/xml", `<user><name>alice</user>`, false},
+		{"text/csv", "\"name,alice", false},
+		{"application/json", `{"name":`, true},
+		{"application/problem+json", `{"name":`, true},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			o, err := New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write([]byte(tt.body))
+			})
+			r, err := newHTTPRunnerWithHandler("req", h)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.operator = o
+			if err := r.Run(ctx, &httpRequest{path: "/", method: http.MethodGet}); err != nil {
+				if !tt.wantErr {
+					t.Error(err)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("want error")
+			}
+			// The raw body is kept even if the body can not be decoded
+			res, ok := o.store.latest()["res"].(map[string]any)
+			if !ok {
+				t.Fatalf("invalid res: %#v", o.store.latest()["res"])
+			}
+			if res["body"] != nil {
+				t.Errorf("got %v\nwant %v", res["body"], nil)
+			}
+			if res["rawBody"] != tt.body {
+				t.Errorf("got %v\nwant %v", res["rawBody"], tt.body)
+			}
+		})
+	}
+}
+
+func TestHTTPResponseDecoder(t *testing.T) {
+	ctx := context.Background()
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("alice,bob"))
+	})
+	dec := func(b []byte) (any, error) {
+		var v []any
+		for _, s := range strings.Split(string(b), ",") {
+			v = append(v, s)
+		}
+		return v, nil
+	}
+	o, err := New(HTTPRunnerWithHandler("req", h, HTTPResponseDecoder("Text/Plain", dec)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := o.httpRunners["req"]
+	r.operator = o
+	if err := r.Run(ctx, &httpRequest{path: "/", method: http.MethodGet}); err != nil {
+		t.Fatal(err)
+	}
+	res, ok := o.store.latest()["res"].(map[string]any)
+	if !ok {
+		t.Fatalf("invalid res: %#v", o.store.latest()["res"])
+	}
+	want := []any{"alice", "bob"}
+	if diff := cmp.Diff(res["body"], want, nil); diff != "" {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,207 @@
+package runn
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"sort"
+	"strings"
+
+	"github.com/ajg/form"
+	"github.com/goccy/go-json"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// httpRequestEncodeFunc encodes `body` of HTTP request.
+type httpRequestEncodeFunc func(r *httpRequest) (io.Reader, error)
+
+// defaultHTTPRequestEncoders is the registry of request body encoders keyed on media type.
+var defaultHTTPRequestEncoders = map[string]httpRequestEncodeFunc{
+	MediaTypeApplicationJSON:           encodeJSONBody,
+	MediaTypeApplicationFormUrlencoded: encodeFormUrlencodedBody,
+	MediaTypeTextPlain:                 encodeTextPlainBody,
+	MediaTypeApplicationXML:            encodeXMLBody,
+	MediaTypeTextXML:                   encodeXMLBody,
+	MediaTypeApplicationOctetStream:    encodeFileBody,
+	MediaTypeApplicationNDJSON:         encodeNDJSONBody,
+	MediaTypeApplicationMsgpack:        encodeMsgpackBody,
+	"application/x-msgpack":            encodeMsgpackBody,
+	MediaTypeApplicationProtobuf:       encodeFileBody,
+}
+
+func (r *httpRequest) encoder() (httpRequestEncodeFunc, bool) {
+	mediaType, _, err := mime.ParseMediaType(r.mediaType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(strings.Split(r.mediaType, ";")[0]))
+	}
+	if enc, ok := r.lookupEncoder(mediaType); ok {
+		return enc, true
+	}
+	for suffix, mt := range structuredSyntaxSuffixes {
+		if strings.HasSuffix(mediaType, suffix) {
+			return r.lookupEncoder(mt)
+		}
+	}
+	return nil, false
+}
+
+func (r *httpRequest) lookupEncoder(mediaType string) (httpRequestEncodeFunc, bool) {
+	if enc, ok := r.encoders[mediaType]; ok {
+		return enc, true
+	}
+	enc, ok := defaultHTTPRequestEncoders[mediaType]
+	return enc, ok
+}
+
+func encodeJSONBody(r *httpRequest) (io.Reader, error) {
+	b, err := json.Marshal(r.body)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewBuffer(b), nil
+}
+
+func encodeFormUrlencodedBody(r *httpRequest) (io.Reader, error) {
+	values, ok := r.body.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid body: %v", r.body)
+	}
+	buf := new(bytes.Buffer)
+	if err := form.NewEncoder(buf).Encode(values); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func encodeTextPlainBody(r *httpRequest) (io.Reader, error) {
+	s, ok := r.body.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid body: %v", r.body)
+	}
+	return strings.NewReader(s), nil
+}
+
+// encodeFileBody reads the file specified by `body` and sends its contents as is.
+func encodeFileBody(r *httpRequest) (io.Reader, error) {
+	switch v := r.body.(type) {
+	case string:
+		b, err := readFile(fp(v, r.root))
+		if err != nil {
+			return nil, fmt.Errorf("invalid body: %w", err)
+		}
+		return bytes.NewReader(b), nil
+	case []byte:
+		return bytes.NewReader(v), nil
+	default:
+		return nil, fmt.Errorf("invalid body: %s requires file path: %v", r.mediaType, r.body)
+	}
+}
+
+// encodeNDJSONBody encodes each element of `body` into a line of JSON.
+func encodeNDJSONBody(r *httpRequest) (io.Reader, error) {
+	values, ok := r.body.([]any)
+	if !ok {
+		values = []any{r.body}
+	}
+	buf := new(bytes.Buffer)
+	for _, v := range values {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		_, _ = buf.Write(b)
+		_ = buf.WriteByte('\n')
+	}
+	return buf, nil
+}
+
+func encodeMsgpackBody(r *httpRequest) (io.Reader, error) {
+	b, err := msgpack.Marshal(r.body)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(b), nil
+}
+
+// encodeXMLBody encodes `body` into XML using the same structure as the response decoder.
+// A string body is sent as is.
+func encodeXMLBody(r *httpRequest) (io.Reader, error) {
+	switch v := r.body.(type) {
+	case string:
+		return strings.NewReader(v), nil
+	case map[string]any:
+		if len(v) != 1 {
+			return nil, fmt.Errorf("invalid body: XML requires a single root element: %v", r.body)
+		}
+		buf := new(bytes.Buffer)
+		enc := xml.NewEncoder(buf)
+		for k, vv := range v {
+			if err := encodeXMLElement(enc, k, vv); err != nil {
+				return nil, err
+			}
+		}
+		if err := enc.Flush(); err != nil {
+			return nil, err
+		}
+		return buf, nil
+	default:
+		return nil, fmt.Errorf("invalid body: %v", r.body)
+	}
+}
+
+func encodeXMLElement(enc *xml.Encoder, name string, v any) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	switch vv := v.(type) {
+	case []any:
+		for _, e := range vv {
+			if err := encodeXMLElement(enc, name, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]any:
+		keys := make([]string, 0, len(vv))
+		for k := range vv {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var children []string
+		for _, k := range keys {
+			switch {
+			case strings.HasPrefix(k, xmlAttrPrefix):
+				start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: strings.TrimPrefix(k, xmlAttrPrefix)}, Value: fmt.Sprintf("%v", vv[k])})
+			case k != xmlTextKey:
+				children = append(children, k)
+			}
+		}
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		if t, ok := vv[xmlTextKey]; ok {
+			if err := enc.EncodeToken(xml.CharData(fmt.Sprintf("%v", t))); err != nil {
+				return err
+			}
+		}
+		for _, k := range children {
+			if err := encodeXMLElement(enc, k, vv[k]); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	case nil:
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		return enc.EncodeToken(start.End())
+	default:
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		if err := enc.EncodeToken(xml.CharData(fmt.Sprintf("%v", vv))); err != nil {
+			return err
+		}
+		return enc.EncodeToken(start.End())
+	}
+}
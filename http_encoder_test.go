@@ -0,0 +1,147 @@
+package runn
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestRequestBodyFromFile(t *testing.T) {
+	dummy, err := os.ReadFile("testdata/dummy.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		mediaType string
+		body      any
+		want      []byte
+		wantErr   bool
+	}{
+		{MediaTypeApplicationOctetStream, "testdata/dummy.png", dummy, false},
+		{MediaTypeApplicationProtobuf, "testdata/dummy.png", dummy, false},
+		{MediaTypeApplicationOctetStream, []byte("raw"), []byte("raw"), false},
+		{MediaTypeApplicationOctetStream, "testdata/notexist.bin", nil, true},
+		{MediaTypeApplicationOctetStream, map[string]any{"one": "ichi"}, nil, true},
+	}
+	for _, tt := range tests {
+		r := &httpRequest{
+			mediaType: tt.mediaType,
+			body:      tt.body,
+		}
+		body, err := r.encodeBody()
+		if err != nil {
+			if !tt.wantErr {
+				t.Error(err)
+			}
+			continue
+		}
+		if tt.wantErr {
+			t.Error("want error")
+			continue
+		}
+		got, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("got %v\nwant %v", got, tt.want)
+		}
+	}
+}
+
+func TestRequestBodyMsgpack(t *testing.T) {
+	body := map[string]any{"username": "alice", "tags": []any{"a", "b"}}
+	r := &httpRequest{
+		mediaType: MediaTypeApplicationMsgpack,
+		body:      body,
+	}
+	b, err := r.encodeBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got any
+	if err := msgpack.NewDecoder(b).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(got, any(body), nil); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestRequestBodyUnsupportedMediaType(t *testing.T) {
+	r := &httpRequest{
+		mediaType: "application/unknown",
+		body:      "text",
+	}
+	if _, err := r.encodeBody(); err == nil || !strings.Contains(err.Error(), "unsupported mediaType: application/unknown") {
+		t.Errorf("got %v\nwant unsupported mediaType error", err)
+	}
+}
+
+func TestHTTPRequestEncoder(t *testing.T) {
+	c := &httpRunnerConfig{}
+	enc := func(body any) ([]byte, error) {
+		return []byte(strings.ToUpper(body.(string))), nil
+	}
+	if err := HTTPRequestEncoder("Application/X-Upper", enc)(c); err != nil {
+		t.Fatal(err)
+	}
+	r := &httpRequest{
+		mediaType: "application/x-upper",
+		body:      "text",
+		encoders:  c.encoders,
+	}
+	b, err := r.encodeBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "TEXT"; string(got) != want {
+		t.Errorf("got %v\nwant %v", string(got), want)
+	}
+}
+
+func TestParseHTTPRequestMediaType(t *testing.T) {
+	c := &httpRunnerConfig{}
+	if err := HTTPRequestEncoder("application/x-upper", func(body any) ([]byte, error) {
+		return []byte(strings.ToUpper(body.(string))), nil
+	})(c); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		mediaType string
+		encoders  map[string]httpRequestEncodeFunc
+		wantErr   bool
+	}{
+		{MediaTypeApplicationXML, nil, false},
+		{"application/vnd.api+json", nil, false},
+		{"application/unknown", nil, true},
+		{"application/x-upper", nil, true},
+		{"application/x-upper", c.encoders, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mediaType, func(t *testing.T) {
+			v := map[string]any{
+				"/users": map[string]any{
+					"post": map[string]any{
+						"body": map[string]any{
+							tt.mediaType: "text",
+						},
+					},
+				},
+			}
+			_, err := parseHTTPRequest(v, tt.encoders)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got %v\nwantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package runn
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/goccy/go-json"
+)
+
+const (
+	graphqlQueryKey         = "query"
+	graphqlVariablesKey     = "variables"
+	graphqlOperationNameKey = "operationName"
+)
+
+const (
+	httpStoreGraphQLDataKey   = "data"
+	httpStoreGraphQLErrorsKey = "errors"
+)
+
+// httpGraphQL is the GraphQL request sent by HTTP runner.
+type httpGraphQL struct {
+	query         string
+	file          string
+	variables     map[string]any
+	operationName string
+	failOnErrors  bool
+}
+
+func parseHTTPGraphQL(v any) (*httpGraphQL, error) {
+	vv, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid graphql: %v", v)
+	}
+	g := &httpGraphQL{}
+	for k, vvv := range vv {
+		switch k {
+		case "query":
+			s, ok := vvv.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid graphql query: %v", vvv)
+			}
+			g.query = s
+		case "file":
+			s, ok := vvv.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid graphql file: %v", vvv)
+			}
+			g.file = s
+		case "variables":
+			switch vars := vvv.(type) {
+			case nil:
+			case map[string]any:
+				g.variables = vars
+			default:
+				return nil, fmt.Errorf("invalid graphql variables: %v", vvv)
+			}
+		case "operationName":
+			s, ok := vvv.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid graphql operationName: %v", vvv)
+			}
+			g.operationName = s
+		case "failOnErrors":
+			b, ok := vvv.(bool)
+			if !ok {
+				return nil, fmt.Errorf("invalid graphql failOnErrors: %v", vvv)
+			}
+			g.failOnErrors = b
+		default:
+			return nil, fmt.Errorf("invalid graphql key: %s", k)
+		}
+	}
+	switch {
+	case g.query == "" && g.file == "":
+		return nil, errors.New("graphql requires query or file")
+	case g.query != "" && g.file != "":
+		return nil, errors.New("graphql query and file cannot be used together")
+	}
+	return g, nil
+}
+
+// setGraphQL sets the GraphQL request to the body ( POST ) or the query string ( GET ) of the HTTP request.
+func (r *httpRequest) setGraphQL() error {
+	g := r.graphql
+	q := g.query
+	if g.file != "" {
+		b, err := readFile(fp(g.file, r.root))
+		if err != nil {
+			return fmt.Errorf("invalid graphql file: %w", err)
+		}
+		q = string(b)
+	}
+	switch r.method {
+	case http.MethodGet:
+		if r.query == nil {
+			r.query = url.Values{}
+		}
+		r.query.Set(graphqlQueryKey, q)
+		if g.variables != nil {
+			b, err := json.Marshal(g.variables)
+			if err != nil {
+				return fmt.Errorf("invalid graphql variables: %w", err)
+			}
+			r.query.Set(graphqlVariablesKey, string(b))
+		}
+		if g.operationName != "" {
+			r.query.Set(graphqlOperationNameKey, g.operationName)
+		}
+	default:
+		body := map[string]any{
+			graphqlQueryKey: q,
+		}
+		if g.variables != nil {
+			body[graphqlVariablesKey] = g.variables
+		}
+		if g.operationName != "" {
+			body[graphqlOperationNameKey] = g.operationName
+		}
+		r.mediaType = MediaTypeApplicationJSON
+		r.body = body
+	}
+	return nil
+}
+
+// record sets `data` and `errors` of the GraphQL response to `d`.
+// If failOnErrors is set, it returns an error when `errors` is not empty.
+func (g *httpGraphQL) record(body any, d map[string]any) error {
+	d[httpStoreGraphQLDataKey] = nil
+	d[httpStoreGraphQLErrorsKey] = []any{}
+	m, ok := body.(map[string]any)
+	if !ok {
+		if g.failOnErrors {
+			return fmt.Errorf("invalid GraphQL response: %v", body)
+		}
+		return nil
+	}
+	d[httpStoreGraphQLDataKey] = m["data"]
+	errs, ok := m["errors"].([]any)
+	if !ok || len(errs) == 0 {
+		return nil
+	}
+	d[httpStoreGraphQLErrorsKey] = errs
+	if !g.failOnErrors {
+		return nil
+	}
+	var msgs []string
+	for _, e := range errs {
+		if em, ok := e.(map[string]any); ok {
+			if msg, ok := em["message"].(string); ok {
+				msgs = append(msgs, msg)
+				continue
+			}
+		}
+		msgs = append(msgs, fmt.Sprintf("%v", e))
+	}
+	return fmt.Errorf("GraphQL response has errors: %s", strings.Join(msgs, ", "))
+}
@@ -0,0 +1,179 @@
+package runn
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHTTPRunnerGraphQL(t *testing.T) {
+	file, err := os.ReadFile("testdata/graphql/user.graphql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query         string         `json:"query"`
+			Variables     map[string]any `json:"variables"`
+			OperationName string         `json:"operationName"`
+		}
+		switch r.Method {
+		case http.MethodGet:
+			req.Query = r.URL.Query().Get("query")
+			req.OperationName = r.URL.Query().Get("operationName")
+			if v := r.URL.Query().Get("variables"); v != "" {
+				if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+			}
+		default:
+			if r.Header.Get("Content-Type") != MediaTypeApplicationJSON {
+				w.WriteHeader(http.StatusUnsupportedMediaType)
+				return
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", MediaTypeApplicationJSON)
+		// GraphQL errors are returned with 200 OK
+		if strings.Contains(req.Query, "invalid") {
+			_, _ = w.Write([]byte(`{"data":null,"errors":[{"message":"Cannot query field \"invalid\""},{"message":"unknown"}]}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{
+				"query":         req.Query,
+				"variables":     req.Variables,
+				"operationName": req.OperationName,
+			},
+		})
+	})
+
+	tests := []struct {
+		name       string
+		req        *httpRequest
+		wantData   any
+		wantErrors []any
+		wantErr    bool
+	}{
+		{
+			"post",
+			&httpRequest{
+				path:    "/graphql",
+				method:  http.MethodPost,
+				headers: http.Header{},
+				graphql: &httpGraphQL{
+					query:         "{ users { name } }",
+					variables:     map[string]any{"id": 1},
+					operationName: "ListUsers",
+				},
+			},
+			map[string]any{"query": "{ users { name } }", "variables": map[string]any{"id": float64(1)}, "operationName": "ListUsers"},
+			[]any{},
+			false,
+		},
+		{
+			"get",
+			&httpRequest{
+				path:    "/graphql",
+				method:  http.MethodGet,
+				headers: http.Header{},
+				graphql: &httpGraphQL{
+					query:     "{ users { name } }",
+					variables: map[string]any{"id": 1},
+				},
+			},
+			map[string]any{"query": "{ users { name } }", "variables": map[string]any{"id": float64(1)}, "operationName": ""},
+			[]any{},
+			false,
+		},
+		{
+			"file",
+			&httpRequest{
+				path:    "/graphql",
+				method:  http.MethodPost,
+				headers: http.Header{},
+				graphql: &httpGraphQL{
+					file: "testdata/graphql/user.graphql",
+				},
+			},
+			map[string]any{"query": string(file), "variables": nil, "operationName": ""},
+			[]any{},
+			false,
+		},
+		{
+			"errors",
+			&httpRequest{
+				path:    "/graphql",
+				method:  http.MethodPost,
+				headers: http.Header{},
+				graphql: &httpGraphQL{
+					query: "{ invalid }",
+				},
+			},
+			nil,
+			[]any{
+				map[string]any{"message": `Cannot query field "invalid"`},
+				map[string]any{"message": "unknown"},
+			},
+			false,
+		},
+		{
+			"fail on errors",
+			&httpRequest{
+				path:    "/graphql",
+				method:  http.MethodPost,
+				headers: http.Header{},
+				graphql: &httpGraphQL{
+					query:        "{ invalid }",
+					failOnErrors: true,
+				},
+			},
+			nil,
+			[]any{
+				map[string]any{"message": `Cannot query field "invalid"`},
+				map[string]any{"message": "unknown"},
+			},
+			true,
+		},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := newHTTPRunnerWithHandler("req", h)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.operator = o
+			if err := r.Run(ctx, tt.req); (err != nil) != tt.wantErr {
+				t.Errorf("got %v\nwantErr %v", err, tt.wantErr)
+			}
+			// The response is recorded even if the GraphQL response has errors
+			res, ok := o.store.latest()["res"].(map[string]any)
+			if !ok {
+				t.Fatalf("invalid res: %#v", o.store.latest()["res"])
+			}
+			if got := res["status"]; got != http.StatusOK {
+				t.Errorf("got %v\nwant %v", got, http.StatusOK)
+			}
+			if diff := cmp.Diff(res["data"], tt.wantData); diff != "" {
+				t.Error(diff)
+			}
+			if diff := cmp.Diff(res["errors"], tt.wantErrors); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
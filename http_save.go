@@ -0,0 +1,96 @@
+package runn
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+const (
+	httpStoreFileKey            = "file"
+	httpStoreFilePathKey        = "path"
+	httpStoreFileSizeKey        = "size"
+	httpStoreFileSHA256Key      = "sha256"
+	httpStoreFileContentTypeKey = "contentType"
+)
+
+// sniffLen is the number of bytes used by http.DetectContentType.
+const sniffLen = 512
+
+// sniffWriter keeps the head of the written data to detect the content type.
+type sniffWriter struct {
+	buf []byte
+}
+
+func (w *sniffWriter) Write(p []byte) (int, error) {
+	if rest := sniffLen - len(w.buf); rest > 0 {
+		if len(p) < rest {
+			rest = len(p)
+		}
+		w.buf = append(w.buf, p[:rest]...)
+	}
+	return len(p), nil
+}
+
+// saveBody streams the response body to the file of path p and returns the information of the saved file.
+func (rnr *httpRunner) saveBody(p string, res *http.Response) (map[string]any, error) {
+	p = fp(p, rnr.operator.root)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to save the response body: %w", err)
+	}
+	f, err := os.Create(filepath.Clean(p))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save the response body: %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	sw := &sniffWriter{}
+	size, err := io.Copy(io.MultiWriter(f, h, sw), res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save the response body: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("failed to save the response body: %w", err)
+	}
+	return map[string]any{
+		httpStoreFilePathKey:        p,
+		httpStoreFileSizeKey:        size,
+		httpStoreFileSHA256Key:      hex.EncodeToString(h.Sum(nil)),
+		httpStoreFileContentTypeKey: http.DetectContentType(sw.buf),
+	}, nil
+}
+
+// limitBody limits the response body to maxBodySize.
+// It is applied before capturing and validating the response so that the body exceeding maxBodySize is never read into memory by them.
+func (rnr *httpRunner) limitBody(res *http.Response) {
+	if rnr.maxBodySize == 0 {
+		return
+	}
+	res.Body = &maxBodyReader{ReadCloser: res.Body, max: rnr.maxBodySize}
+}
+
+// maxBodyReader is the response body that fails once more than max bytes are read.
+// The error is kept so that readers after the first one ( capturers, validators and the step ) fail in the same way.
+type maxBodyReader struct {
+	io.ReadCloser
+	max  uint64
+	read uint64
+	err  error
+}
+
+func (r *maxBodyReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.read += uint64(n) //nolint:gosec
+	if r.read > r.max {
+		r.err = fmt.Errorf("response body exceeds maxBodySize (%d bytes): use saveTo to save the body to a file", r.max)
+		return 0, r.err
+	}
+	return n, err
+}
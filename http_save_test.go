@@ -0,0 +1,182 @@
+package runn
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHTTPRunnerSaveTo(t *testing.T) {
+	img, err := os.ReadFile("testdata/dummy.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(img)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", MediaTypeApplicationOctetStream)
+		_, _ = w.Write(img)
+	})
+	dir := t.TempDir()
+	tests := []struct {
+		name   string
+		saveTo string
+		want   string
+	}{
+		{"absolute path", filepath.Join(dir, "dummy.png"), filepath.Join(dir, "dummy.png")},
+		{"create directory", filepath.Join(dir, "path", "to", "dummy.png"), filepath.Join(dir, "path", "to", "dummy.png")},
+		{"relative path", "dummy.png", filepath.Join(dir, "dummy.png")},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			o.root = dir
+			r, err := newHTTPRunnerWithHandler("req", h)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.operator = o
+			req := &httpRequest{
+				path:    "/dummy.png",
+				method:  http.MethodGet,
+				headers: http.Header{},
+				saveTo:  tt.saveTo,
+			}
+			if err := r.Run(ctx, req); err != nil {
+				t.Fatal(err)
+			}
+			res, ok := o.store.latest()["res"].(map[string]any)
+			if !ok {
+				t.Fatalf("invalid res: %#v", o.store.latest()["res"])
+			}
+			if res["body"] != nil {
+				t.Errorf("got %v\nwant %v", res["body"], nil)
+			}
+			want := map[string]any{
+				"path":        tt.want,
+				"size":        int64(len(img)),
+				"sha256":      hex.EncodeToString(sum[:]),
+				"contentType": "image/png",
+			}
+			if diff := cmp.Diff(res["file"], want); diff != "" {
+				t.Error(diff)
+			}
+			got, err := os.ReadFile(tt.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, img) {
+				t.Error("saved file does not match the response body")
+			}
+		})
+	}
+}
+
+func TestHTTPRunnerMaxBodySize(t *testing.T) {
+	body := bytes.Repeat([]byte("a"), 2000)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", MediaTypeTextPlain)
+		_, _ = w.Write(body)
+	})
+	tests := []struct {
+		maxBodySize string
+		wantErr     bool
+	}{
+		{"", false},
+		{"2000B", false},
+		{"1KB", true},
+		{"1MB", false},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.maxBodySize, func(t *testing.T) {
+			var opts []httpRunnerOption
+			if tt.maxBodySize != "" {
+				opts = append(opts, MaxBodySize(tt.maxBodySize))
+			}
+			o, err := New(HTTPRunnerWithHandler("req", h, opts...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, ok := o.httpRunners["req"]
+			if !ok {
+				t.Fatal("http runner not found")
+			}
+			r.operator = o
+			req := &httpRequest{
+				path:    "/",
+				method:  http.MethodGet,
+				headers: http.Header{},
+			}
+			if err := r.Run(ctx, req); (err != nil) != tt.wantErr {
+				t.Errorf("got %v\nwantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHTTPRunnerMaxBodySizeBeforeCaptureAndValidation(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", MediaTypeApplicationJSON)
+		_, _ = w.Write([]byte("["))
+		for i := 0; i < 100000; i++ {
+			if i > 0 {
+				_, _ = w.Write([]byte(","))
+			}
+			_, _ = w.Write([]byte(`{"username":"alice"}`))
+		}
+		_, _ = w.Write([]byte("]"))
+	}))
+	t.Cleanup(ts.Close)
+	tests := []struct {
+		name       string
+		opts       []httpRunnerOption
+		jsonSchema string
+	}{
+		{"debug", nil, ""},
+		{"openapi3", []httpRunnerOption{OpenApi3("testdata/openapi3.yml")}, ""},
+		{"runner jsonSchema", []httpRunnerOption{HTTPJSONSchema("testdata/jsonschema/user.json")}, ""},
+		{"step jsonSchema", nil, "json://testdata/jsonschema/user.json"},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := new(bytes.Buffer)
+			opts := append([]httpRunnerOption{MaxBodySize("1KB")}, tt.opts...)
+			o, err := New(Runner("req", ts.URL, opts...), Capture(NewDebugger(out)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, ok := o.httpRunners["req"]
+			if !ok {
+				t.Fatal("http runner not found")
+			}
+			r.operator = o
+			req := &httpRequest{
+				path:       "/users",
+				method:     http.MethodGet,
+				headers:    http.Header{},
+				jsonSchema: tt.jsonSchema,
+			}
+			err = r.Run(ctx, req)
+			if err == nil || !strings.Contains(err.Error(), "exceeds maxBodySize") {
+				t.Errorf("got %v\nwant maxBodySize error", err)
+			}
+			if strings.Contains(out.String(), `"username"`) {
+				t.Errorf("the body exceeding maxBodySize should not be captured: %d bytes", out.Len())
+			}
+		})
+	}
+}
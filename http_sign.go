@@ -0,0 +1,398 @@
+package runn
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+const (
+	httpSignTypeSigV4 = "sigv4"
+	httpSignTypeHMAC  = "hmac"
+)
+
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat = "20060102T150405Z"
+	sigV4DateFormat = "20060102"
+)
+
+const (
+	defaultHMACSignatureHeader = "X-Signature"
+	defaultHMACTemplate        = "{method}\n{path}\n{query}\n{timestamp}\n{bodySHA256}"
+)
+
+// sigV4IgnoredHeaders are headers that are not signed because they may be changed by proxies or the transport.
+var sigV4IgnoredHeaders = map[string]struct{}{
+	"authorization":   {},
+	"user-agent":      {},
+	"x-amzn-trace-id": {},
+	"expect":          {},
+}
+
+var hmacTemplatePlaceholderRe = regexp.MustCompile(`\{([a-zA-Z0-9]+)(?::([^{}]+))?\}`)
+
+type httpSignConfig struct {
+	Type string `yaml:"type"`
+
+	// AWS Signature Version 4
+	Region          string `yaml:"region,omitempty"`
+	Service         string `yaml:"service,omitempty"`
+	AccessKeyID     string `yaml:"accessKeyID,omitempty"`
+	SecretAccessKey string `yaml:"secretAccessKey,omitempty"`
+	SessionToken    string `yaml:"sessionToken,omitempty"`
+
+	// HMAC
+	Algorithm       string `yaml:"algorithm,omitempty"`
+	Key             string `yaml:"key,omitempty"`
+	Template        string `yaml:"template,omitempty"`
+	Header          string `yaml:"header,omitempty"`
+	Prefix          string `yaml:"prefix,omitempty"`
+	Encoding        string `yaml:"encoding,omitempty"`
+	TimestampHeader string `yaml:"timestampHeader,omitempty"`
+}
+
+func (c *httpSignConfig) validate() error {
+	switch c.Type {
+	case httpSignTypeSigV4:
+		if c.Service == "" {
+			return errors.New("invalid sign: sigv4 requires service")
+		}
+	case httpSignTypeHMAC:
+		if c.Key == "" {
+			return errors.New("invalid sign: hmac requires key")
+		}
+		if _, err := hmacHashFunc(c.Algorithm); err != nil {
+			return fmt.Errorf("invalid sign: %w", err)
+		}
+		switch c.Encoding {
+		case "", "hex", "base64":
+		default:
+			return fmt.Errorf("invalid sign: unsupported encoding: %s", c.Encoding)
+		}
+	case "":
+		return errors.New("invalid sign: type is required")
+	default:
+		return fmt.Errorf("invalid sign: unsupported type: %s", c.Type)
+	}
+	return nil
+}
+
+// expand expands `{{ }}` in the values of the config. The template is not expanded.
+func (c *httpSignConfig) expand(expandFn func(any) (any, error)) (*httpSignConfig, error) {
+	e := *c
+	for _, s := range []*string{&e.Region, &e.Service, &e.AccessKeyID, &e.SecretAccessKey, &e.SessionToken, &e.Key} {
+		v, err := expandFn(*s)
+		if err != nil {
+			return nil, err
+		}
+		if *s, err = cast.ToStringE(v); err != nil {
+			return nil, err
+		}
+	}
+	return &e, nil
+}
+
+// signRequest signs the request that headers, cookies and credentials have been applied to.
+func (rnr *httpRunner) signRequest(req *http.Request) error {
+	if rnr.sign != nil {
+		c, err := rnr.sign.expand(rnr.operator.expandBeforeRecord)
+		if err != nil {
+			return fmt.Errorf("invalid sign: %w", err)
+		}
+		switch c.Type {
+		case httpSignTypeSigV4:
+			if err := signSigV4(req, c, time.Now()); err != nil {
+				return err
+			}
+		case httpSignTypeHMAC:
+			if err := signHMAC(req, c, time.Now()); err != nil {
+				return err
+			}
+		}
+	}
+	if rnr.signer != nil {
+		if err := rnr.signer(req); err != nil {
+			return fmt.Errorf("failed to sign request: %w", err)
+		}
+	}
+	return nil
+}
+
+// signSigV4 signs the request with AWS Signature Version 4.
+// Credentials and the region not specified in the config are taken from the environment variables.
+func signSigV4(req *http.Request, c *httpSignConfig, now time.Time) error {
+	accessKeyID := c.AccessKeyID
+	secretAccessKey := c.SecretAccessKey
+	sessionToken := c.SessionToken
+	if accessKeyID == "" && secretAccessKey == "" {
+		accessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		secretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		if sessionToken == "" {
+			sessionToken = os.Getenv("AWS_SESSION_TOKEN")
+		}
+	}
+	if accessKeyID == "" || secretAccessKey == "" {
+		return errors.New("failed to sign request: sigv4 credentials not found")
+	}
+	region := c.Region
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		return errors.New("failed to sign request: sigv4 region not found")
+	}
+
+	body, err := requestBody(req)
+	if err != nil {
+		return fmt.Errorf("failed to sign request: %w", err)
+	}
+	payloadHash := sha256Hex(body)
+	t := now.UTC()
+	amzDate := t.Format(sigV4TimeFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", sessionToken)
+	}
+	if c.Service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	signedHeaders, canonicalHeaders := sigV4CanonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		sigV4CanonicalURI(req, c.Service),
+		sigV4CanonicalQuery(req),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := strings.Join([]string{t.Format(sigV4DateFormat), region, c.Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), []byte(t.Format(sigV4DateFormat)))
+	key = hmacSHA256(key, []byte(region))
+	key = hmacSHA256(key, []byte(c.Service))
+	key = hmacSHA256(key, []byte("aws4_request"))
+	signature := hex.EncodeToString(hmacSHA256(key, []byte(stringToSign)))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s", sigV4Algorithm, accessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+func sigV4CanonicalURI(req *http.Request, service string) string {
+	p := req.URL.EscapedPath()
+	if p == "" {
+		return "/"
+	}
+	if service == "s3" {
+		return p
+	}
+	// Each path segment is URI-encoded twice except for Amazon S3
+	segs := strings.Split(p, "/")
+	for i, s := range segs {
+		segs[i] = sigV4Escape(s)
+	}
+	return strings.Join(segs, "/")
+}
+
+func sigV4CanonicalQuery(req *http.Request) string {
+	q := req.URL.Query()
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, k := range keys {
+		vs := append([]string{}, q[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			pairs = append(pairs, fmt.Sprintf("%s=%s", sigV4Escape(k), sigV4Escape(v)))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func sigV4CanonicalHeaders(req *http.Request) (string, string) {
+	headers := map[string][]string{}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers["host"] = []string{host}
+	for k, vs := range req.Header {
+		lk := strings.ToLower(k)
+		if _, ok := sigV4IgnoredHeaders[lk]; ok {
+			continue
+		}
+		if lk == "host" {
+			continue
+		}
+		headers[lk] = append(headers[lk], vs...)
+	}
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	b := new(strings.Builder)
+	for _, k := range keys {
+		vs := make([]string, 0, len(headers[k]))
+		for _, v := range headers[k] {
+			vs = append(vs, strings.Join(strings.Fields(v), " "))
+		}
+		_, _ = fmt.Fprintf(b, "%s:%s\n", k, strings.Join(vs, ","))
+	}
+	return strings.Join(keys, ";"), b.String()
+}
+
+// sigV4Escape encodes s using the URI encoding of AWS Signature Version 4 ( RFC 3986 unreserved characters only ).
+func sigV4Escape(s string) string {
+	b := new(strings.Builder)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' {
+			_ = b.WriteByte(c)
+			continue
+		}
+		_, _ = fmt.Fprintf(b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// signHMAC signs the request with HMAC of the canonical string built from the template.
+func signHMAC(req *http.Request, c *httpSignConfig, now time.Time) error {
+	newHash, err := hmacHashFunc(c.Algorithm)
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	if c.TimestampHeader != "" {
+		req.Header.Set(c.TimestampHeader, timestamp)
+	}
+	body, err := requestBody(req)
+	if err != nil {
+		return fmt.Errorf("failed to sign request: %w", err)
+	}
+	tmpl := c.Template
+	if tmpl == "" {
+		tmpl = defaultHMACTemplate
+	}
+	var rerr error
+	s := hmacTemplatePlaceholderRe.ReplaceAllStringFunc(tmpl, func(m string) string {
+		sm := hmacTemplatePlaceholderRe.FindStringSubmatch(m)
+		switch sm[1] {
+		case "method":
+			return req.Method
+		case "host":
+			if req.Host != "" {
+				return req.Host
+			}
+			return req.URL.Host
+		case "path":
+			return req.URL.EscapedPath()
+		case "query":
+			return req.URL.RawQuery
+		case "body":
+			return string(body)
+		case "bodySHA256":
+			return sha256Hex(body)
+		case "timestamp":
+			return timestamp
+		case "header":
+			return strings.Join(req.Header.Values(sm[2]), ",")
+		default:
+			rerr = fmt.Errorf("failed to sign request: unknown placeholder in template: %s", m)
+			return m
+		}
+	})
+	if rerr != nil {
+		return rerr
+	}
+	mac := hmac.New(newHash, []byte(c.Key))
+	_, _ = mac.Write([]byte(s))
+	var signature string
+	switch c.Encoding {
+	case "base64":
+		signature = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	default:
+		signature = hex.EncodeToString(mac.Sum(nil))
+	}
+	header := c.Header
+	if header == "" {
+		header = defaultHMACSignatureHeader
+	}
+	req.Header.Set(header, c.Prefix+signature)
+	return nil
+}
+
+func hmacHashFunc(algorithm string) (func() hash.Hash, error) {
+	switch strings.ToLower(algorithm) {
+	case "", "sha256":
+		return sha256.New, nil
+	case "sha1":
+		return sha1.New, nil
+	case "sha512":
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("unsupported hmac algorithm: %s", algorithm)
+	}
+}
+
+// requestBody reads the body of the request without consuming it.
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+	b, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	_ = req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
+
+func sha256Hex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write(data)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,321 @@
+package runn
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSignSigV4(t *testing.T) {
+	// Test vectors from AWS Signature Version 4 test suite
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	c := &httpSignConfig{
+		Type:            httpSignTypeSigV4,
+		Region:          "us-east-1",
+		Service:         "service",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{
+			"get-vanilla",
+			"https://example.amazonaws.com/",
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			"get-vanilla-query-order-key-case",
+			"https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := signSigV4(req, c, now); err != nil {
+				t.Fatal(err)
+			}
+			if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+				t.Errorf("got %v\nwant %v", got, "20150830T123600Z")
+			}
+			if got := req.Header.Get("Authorization"); got != tt.want {
+				t.Errorf("got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignSigV4FromEnv(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+	t.Setenv("AWS_SESSION_TOKEN", "session")
+	t.Setenv("AWS_REGION", "ap-northeast-1")
+	req, err := http.NewRequest(http.MethodPost, "https://example.amazonaws.com/users", strings.NewReader(`{"username":"alice"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := signSigV4(req, &httpSignConfig{Type: httpSignTypeSigV4, Service: "execute-api"}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	got := req.Header.Get("Authorization")
+	if !strings.Contains(got, "/ap-northeast-1/execute-api/aws4_request") {
+		t.Errorf("invalid credential scope: %s", got)
+	}
+	if !strings.Contains(got, "SignedHeaders=content-type;host;x-amz-date;x-amz-security-token") {
+		t.Errorf("invalid signed headers: %s", got)
+	}
+	if req.Header.Get("X-Amz-Security-Token") != "session" {
+		t.Errorf("got %v\nwant %v", req.Header.Get("X-Amz-Security-Token"), "session")
+	}
+	b, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"username":"alice"}` {
+		t.Errorf("body should not be consumed: %s", string(b))
+	}
+}
+
+func TestHTTPRunnerSignHMAC(t *testing.T) {
+	const key = "secret"
+	// verifier
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		ts := r.Header.Get("X-Timestamp")
+		if _, err := strconv.ParseInt(ts, 10, 64); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mac := hmac.New(sha256.New, []byte(key))
+		_, _ = fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n%s", r.Method, r.URL.Path, r.Header.Get("X-Client"), ts, string(b))
+		want := "HMAC " + hex.EncodeToString(mac.Sum(nil))
+		if r.Header.Get("Authorization") != want {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	ctx := context.Background()
+	o, err := New(HTTPRunnerWithHandler("req", h))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := o.httpRunners["req"]
+	r.operator = o
+	r.sign = &httpSignConfig{
+		Type:            httpSignTypeHMAC,
+		Key:             key,
+		Template:        "{method}\n{path}\n{header:X-Client}\n{timestamp}\n{body}",
+		Header:          "Authorization",
+		Prefix:          "HMAC ",
+		TimestampHeader: "X-Timestamp",
+	}
+	req := &httpRequest{
+		path:      "/users",
+		method:    http.MethodPost,
+		headers:   http.Header{"X-Client": []string{"runn"}},
+		mediaType: MediaTypeApplicationJSON,
+		body:      map[string]any{"username": "alice"},
+	}
+	if err := r.Run(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	res, ok := o.store.latest()["res"].(map[string]any)
+	if !ok {
+		t.Fatalf("invalid res: %#v", o.store.latest()["res"])
+	}
+	if res["status"] != http.StatusOK {
+		t.Errorf("got %v\nwant %v", res["status"], http.StatusOK)
+	}
+}
+
+func TestHTTPRequestSigner(t *testing.T) {
+	var got string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("X-Signed")
+		w.WriteHeader(http.StatusOK)
+	})
+	signer := func(req *http.Request) error {
+		req.Header.Set("X-Signed", req.Method+" "+req.URL.Path)
+		return nil
+	}
+	ctx := context.Background()
+	o, err := New(HTTPRunnerWithHandler("req", h, HTTPRequestSigner(signer)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := o.httpRunners["req"]
+	r.operator = o
+	if err := r.Run(ctx, &httpRequest{path: "/users", method: http.MethodGet}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "GET /users"; got != want {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+
+	o2, err := New(HTTPRunnerWithHandler("req", h, HTTPRequestSigner(func(req *http.Request) error {
+		return errors.New("sign error")
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2 := o2.httpRunners["req"]
+	r2.operator = o2
+	if err := r2.Run(ctx, &httpRequest{path: "/users", method: http.MethodGet}); err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestSignHMACTemplate(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name    string
+		c       *httpSignConfig
+		want    string
+		wantErr bool
+	}{
+		{
+			"default",
+			&httpSignConfig{Type: httpSignTypeHMAC, Key: "secret"},
+			hmacHex("secret", "GET\n/users\npage=1\n1700000000\ne3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"),
+			false,
+		},
+		{
+			"host",
+			&httpSignConfig{Type: httpSignTypeHMAC, Key: "secret", Template: "{host}"},
+			hmacHex("secret", "example.com"),
+			false,
+		},
+		{
+			"unknown placeholder",
+			&httpSignConfig{Type: httpSignTypeHMAC, Key: "secret", Template: "{unknown}"},
+			"",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "https://example.com/users?page=1", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := signHMAC(req, tt.c, now); err != nil {
+				if !tt.wantErr {
+					t.Errorf("got error: %v", err)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Error("want error")
+				return
+			}
+			if got := req.Header.Get(defaultHMACSignatureHeader); got != tt.want {
+				t.Errorf("got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRunnerWithSign(t *testing.T) {
+	tests := []struct {
+		v       any
+		want    *httpSignConfig
+		wantErr bool
+	}{
+		{
+			map[string]any{
+				"endpoint": "https://example.com",
+				"sign": map[string]any{
+					"type":    "sigv4",
+					"region":  "us-east-1",
+					"service": "execute-api",
+				},
+			},
+			&httpSignConfig{Type: "sigv4", Region: "us-east-1", Service: "execute-api"},
+			false,
+		},
+		{
+			map[string]any{
+				"endpoint": "https://example.com",
+				"sign": map[string]any{
+					"type":      "hmac",
+					"algorithm": "sha512",
+					"key":       "secret",
+					"encoding":  "base64",
+				},
+			},
+			&httpSignConfig{Type: "hmac", Algorithm: "sha512", Key: "secret", Encoding: "base64"},
+			false,
+		},
+		{
+			map[string]any{
+				"endpoint": "https://example.com",
+				"sign": map[string]any{
+					"type":      "hmac",
+					"algorithm": "md5",
+					"key":       "secret",
+				},
+			},
+			nil,
+			true,
+		},
+		{
+			map[string]any{
+				"endpoint": "https://example.com",
+				"sign": map[string]any{
+					"type": "sigv4",
+				},
+			},
+			nil,
+			true,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			bk := newBook()
+			err := bk.parseRunner("req", tt.v)
+			if err != nil {
+				if !tt.wantErr {
+					t.Errorf("got error: %v", err)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Error("want error")
+				return
+			}
+			got := bk.httpRunners["req"].sign
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func hmacHex(key, s string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	_, _ = mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil))
+}
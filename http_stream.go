@@ -0,0 +1,323 @@
+package runn
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/spf13/cast"
+)
+
+const (
+	sseEventKey = "event"
+	sseDataKey  = "data"
+	sseJSONKey  = "json"
+	sseIDKey    = "id"
+	sseRetryKey = "retry"
+
+	sseDefaultEvent = "message"
+)
+
+// httpStream is the condition to stop reading streaming response.
+type httpStream struct {
+	count   int
+	timeout time.Duration
+	until   string
+}
+
+type httpStreamEvent struct {
+	message any
+	err     error
+}
+
+func parseHTTPStream(v any) (*httpStream, error) {
+	switch vv := v.(type) {
+	case nil:
+		return nil, nil
+	case bool:
+		if !vv {
+			return nil, nil
+		}
+		return &httpStream{}, nil
+	case map[string]any:
+		s := &httpStream{}
+		for k, vvv := range vv {
+			switch k {
+			case "count":
+				c, err := cast.ToIntE(vvv)
+				if err != nil {
+					return nil, fmt.Errorf("invalid stream count: %w", err)
+				}
+				if c < 0 {
+					return nil, fmt.Errorf("invalid stream count: %d", c)
+				}
+				s.count = c
+			case "timeout":
+				ts, err := cast.ToStringE(vvv)
+				if err != nil {
+					return nil, fmt.Errorf("invalid stream timeout: %w", err)
+				}
+				s.timeout, err = parseDuration(ts)
+				if err != nil {
+					return nil, fmt.Errorf("invalid stream timeout: %w", err)
+				}
+			case "until":
+				u, ok := vvv.(string)
+				if !ok {
+					return nil, fmt.Errorf("invalid stream until: %v", vvv)
+				}
+				s.until = u
+			default:
+				return nil, fmt.Errorf("invalid stream key: %s", k)
+			}
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("invalid stream: %v", v)
+	}
+}
+
+// doStream sends the request and returns the streaming response.
+// Since http.Client.Timeout also limits the time to read the response body, it would cut off streams longer than it ( e.g. `stream: {timeout: 5m}` ).
+// So the timeout of the client only limits the time until the response headers are received, and reading the stream is limited by the conditions of `stream:` and the context.
+func doStream(client *http.Client, req *http.Request) (*http.Response, error) {
+	timeout := client.Timeout
+	c := *client
+	c.Timeout = 0
+	if timeout <= 0 {
+		return c.Do(req)
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	var timedOut atomic.Bool
+	t := time.AfterFunc(timeout, func() {
+		timedOut.Store(true)
+		cancel()
+	})
+	res, err := c.Do(req.WithContext(ctx))
+	t.Stop()
+	if err != nil {
+		cancel()
+		if timedOut.Load() {
+			return nil, fmt.Errorf("timeout (%v) exceeded while awaiting response headers: %w", timeout, err)
+		}
+		return nil, err
+	}
+	if timedOut.Load() {
+		// Timed out just after the response headers are received
+		_ = res.Body.Close()
+		cancel()
+		return nil, fmt.Errorf("timeout (%v) exceeded while awaiting response headers", timeout)
+	}
+	res.Body = &cancelOnCloseBody{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
+
+// cancelOnCloseBody is the response body that cancels the context of the request when closed.
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// readStream reads messages from the streaming response until the stream ends or the condition of `stream:` is met.
+// `d` is the response recorded so far and is used to evaluate `until:`.
+func (rnr *httpRunner) readStream(ctx context.Context, s *httpStream, res *http.Response, d map[string]any) ([]any, []byte, error) {
+	raw := new(bytes.Buffer)
+	next := newHTTPStreamDecoder(res.Header.Get("Content-Type"), io.TeeReader(res.Body, raw))
+	ch := make(chan httpStreamEvent)
+	go func() {
+		defer close(ch)
+		for {
+			m, err := next()
+			if err != nil {
+				ch <- httpStreamEvent{err: err}
+				return
+			}
+			ch <- httpStreamEvent{message: m}
+		}
+	}()
+
+	var timeout <-chan time.Time
+	if s.timeout > 0 {
+		t := time.NewTimer(s.timeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+	messages := []any{}
+	err := func() error {
+		for {
+			select {
+			case ev, ok := <-ch:
+				if !ok {
+					return nil
+				}
+				if ev.err != nil {
+					if errors.Is(ev.err, io.EOF) {
+						return nil
+					}
+					return ev.err
+				}
+				messages = append(messages, ev.message)
+				rnr.operator.capturers.captureHTTPStreamMessage(rnr.name, ev.message)
+				if s.count > 0 && len(messages) >= s.count {
+					return nil
+				}
+				if s.until != "" {
+					d[httpStoreMessagesKey] = messages
+					store := rnr.operator.store.toMap()
+					store[storeIncludedKey] = rnr.operator.included
+					store[storePreviousKey] = rnr.operator.store.latest()
+					store[storeCurrentKey] = map[string]any{
+						string(httpStoreResponseKey): d,
+					}
+					tf, err := EvalCond(s.until, store)
+					if err != nil {
+						return fmt.Errorf("invalid stream until: %w", err)
+					}
+					if tf {
+						return nil
+					}
+				}
+			case <-timeout:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}()
+	// Stop reading and wait for the reader
+	_ = res.Body.Close()
+	for range ch {
+		// Discard messages read after the condition is met
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return messages, raw.Bytes(), nil
+}
+
+// newHTTPStreamDecoder returns the function that reads the next message from the streaming response.
+// text/event-stream is decoded as Server-Sent Events, NDJSON is decoded line by line, and other media types are read line by line as strings.
+func newHTTPStreamDecoder(contentType string, r io.Reader) func() (any, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+	br := bufio.NewReader(r)
+	switch {
+	case mediaType == MediaTypeTextEventStream:
+		return func() (any, error) {
+			return readSSEEvent(br)
+		}
+	case mediaType == MediaTypeApplicationNDJSON || strings.Contains(mediaType, "ndjson") || strings.Contains(mediaType, "jsonl") || strings.Contains(mediaType, "json-seq"):
+		return func() (any, error) {
+			for {
+				l, err := readLine(br)
+				if err != nil {
+					return nil, err
+				}
+				// RFC 7464 record separator
+				l = strings.TrimSpace(strings.TrimPrefix(l, "\x1e"))
+				if l == "" {
+					continue
+				}
+				var v any
+				if err := json.Unmarshal([]byte(l), &v); err != nil {
+					return nil, fmt.Errorf("failed to decode stream message: %w", err)
+				}
+				return v, nil
+			}
+		}
+	default:
+		return func() (any, error) {
+			return readLine(br)
+		}
+	}
+}
+
+// readSSEEvent reads an event of Server-Sent Events.
+// ref: https://html.spec.whatwg.org/multipage/server-sent-events.html#event-stream-interpretation
+func readSSEEvent(br *bufio.Reader) (map[string]any, error) {
+	var (
+		event string
+		data  []string
+		id    *string
+		retry *int
+	)
+	for {
+		l, err := readLine(br)
+		if err != nil {
+			return nil, err
+		}
+		if l == "" {
+			if len(data) == 0 {
+				event = ""
+				continue
+			}
+			ev := map[string]any{
+				sseEventKey: sseDefaultEvent,
+				sseDataKey:  strings.Join(data, "\n"),
+			}
+			if event != "" {
+				ev[sseEventKey] = event
+			}
+			var v any
+			if err := json.Unmarshal([]byte(ev[sseDataKey].(string)), &v); err == nil {
+				ev[sseJSONKey] = v
+			}
+			if id != nil {
+				ev[sseIDKey] = *id
+			}
+			if retry != nil {
+				ev[sseRetryKey] = *retry
+			}
+			return ev, nil
+		}
+		if strings.HasPrefix(l, ":") {
+			// comment
+			continue
+		}
+		field, value, _ := strings.Cut(l, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case sseEventKey:
+			event = value
+		case sseDataKey:
+			data = append(data, value)
+		case sseIDKey:
+			v := value
+			id = &v
+		case sseRetryKey:
+			if n, err := strconv.Atoi(value); err == nil {
+				retry = &n
+			}
+		}
+	}
+}
+
+// readLine reads a line without the line terminator. The last line without the terminator is also returned.
+func readLine(br *bufio.Reader) (string, error) {
+	l, err := br.ReadString('\n')
+	if err != nil {
+		if errors.Is(err, io.EOF) && l != "" {
+			return strings.TrimSuffix(l, "\r"), nil
+		}
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(l, "\n"), "\r"), nil
+}
@@ -0,0 +1,245 @@
+package runn
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHTTPRunnerStream(t *testing.T) {
+	sse := []string{
+		": comment\n\n",
+		"event: greeting\ndata: hello\nid: 1\n\n",
+		"data: {\"num\": 2}\nid: 2\nretry: 1000\n\n",
+		"event: done\ndata: line1\ndata: line2\nid: 3\n\n",
+	}
+	ndjson := []string{
+		"{\"id\": 1}\n",
+		"\n",
+		"{\"id\": 2}\n",
+		"{\"id\": 3}\n",
+	}
+	tests := []struct {
+		name        string
+		contentType string
+		chunks      []string
+		hang        bool
+		stream      *httpStream
+		want        []any
+	}{
+		{
+			"sse until EOF",
+			MediaTypeTextEventStream,
+			sse,
+			false,
+			&httpStream{},
+			[]any{
+				map[string]any{"event": "greeting", "data": "hello", "id": "1"},
+				map[string]any{"event": "message", "data": `{"num": 2}`, "json": map[string]any{"num": float64(2)}, "id": "2", "retry": 1000},
+				map[string]any{"event": "done", "data": "line1\nline2", "id": "3"},
+			},
+		},
+		{
+			"sse count",
+			"text/event-stream; charset=utf-8",
+			sse,
+			true,
+			&httpStream{count: 2},
+			[]any{
+				map[string]any{"event": "greeting", "data": "hello", "id": "1"},
+				map[string]any{"event": "message", "data": `{"num": 2}`, "json": map[string]any{"num": float64(2)}, "id": "2", "retry": 1000},
+			},
+		},
+		{
+			"sse until",
+			MediaTypeTextEventStream,
+			sse,
+			true,
+			&httpStream{until: `current.res.messages[-1].event == "done"`},
+			[]any{
+				map[string]any{"event": "greeting", "data": "hello", "id": "1"},
+				map[string]any{"event": "message", "data": `{"num": 2}`, "json": map[string]any{"num": float64(2)}, "id": "2", "retry": 1000},
+				map[string]any{"event": "done", "data": "line1\nline2", "id": "3"},
+			},
+		},
+		{
+			"ndjson timeout",
+			MediaTypeApplicationNDJSON,
+			ndjson,
+			true,
+			&httpStream{timeout: 500 * time.Millisecond},
+			[]any{
+				map[string]any{"id": float64(1)},
+				map[string]any{"id": float64(2)},
+				map[string]any{"id": float64(3)},
+			},
+		},
+		{
+			"ndjson until",
+			MediaTypeApplicationNDJSON,
+			ndjson,
+			true,
+			&httpStream{until: `len(current.res.messages) == 2 && current.res.status == 200`},
+			[]any{
+				map[string]any{"id": float64(1)},
+				map[string]any{"id": float64(2)},
+			},
+		},
+		{
+			"lines",
+			"text/plain",
+			[]string{"foo\n", "bar\r\n", "baz"},
+			false,
+			&httpStream{},
+			[]any{"foo", "bar", "baz"},
+		},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := make(chan struct{})
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(http.StatusOK)
+				for _, c := range tt.chunks {
+					_, _ = w.Write([]byte(c))
+					w.(http.Flusher).Flush()
+				}
+				if tt.hang {
+					select {
+					case <-r.Context().Done():
+					case <-done:
+					}
+				}
+			}))
+			t.Cleanup(func() {
+				close(done)
+				ts.Close()
+			})
+			out := new(bytes.Buffer)
+			o, err := New(Capture(NewDebugger(out)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := newHTTPRunner("req", ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.operator = o
+			req := &httpRequest{
+				path:   "/",
+				method: http.MethodGet,
+				stream: tt.stream,
+			}
+			if err := r.Run(ctx, req); err != nil {
+				t.Fatal(err)
+			}
+			res, ok := o.store.latest()["res"].(map[string]any)
+			if !ok {
+				t.Fatalf("invalid res: %#v", o.store.latest()["res"])
+			}
+			if diff := cmp.Diff(res["messages"], tt.want); diff != "" {
+				t.Error(diff)
+			}
+			if got := strings.Count(out.String(), "-----START HTTP STREAM MESSAGE-----"); got != len(tt.want) {
+				t.Errorf("got %v\nwant %v", got, len(tt.want))
+			}
+			if !tt.hang {
+				if diff := cmp.Diff(res["rawBody"], strings.Join(tt.chunks, "")); diff != "" {
+					t.Error(diff)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPRunnerStreamInvalidMessage(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", MediaTypeApplicationNDJSON)
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprint(w, "{\"id\": 1}\n{invalid\n")
+	})
+	ctx := context.Background()
+	o, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := newHTTPRunnerWithHandler("req", h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.operator = o
+	if err := r.Run(ctx, &httpRequest{path: "/", method: http.MethodGet, stream: &httpStream{}}); err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestHTTPRunnerStreamTimeout(t *testing.T) {
+	tests := []struct {
+		name         string
+		headerDelay  time.Duration
+		messageDelay time.Duration
+		wantErr      bool
+	}{
+		{"stream longer than the timeout of the runner", 0, 50 * time.Millisecond, false},
+		{"headers later than the timeout of the runner", 500 * time.Millisecond, 0, true},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-r.Context().Done():
+					return
+				case <-time.After(tt.headerDelay):
+				}
+				w.Header().Set("Content-Type", MediaTypeApplicationNDJSON)
+				w.WriteHeader(http.StatusOK)
+				for i := 0; i < 6; i++ {
+					_, _ = fmt.Fprintf(w, "{\"id\": %d}\n", i)
+					w.(http.Flusher).Flush()
+					select {
+					case <-r.Context().Done():
+						return
+					case <-time.After(tt.messageDelay):
+					}
+				}
+			}))
+			t.Cleanup(ts.Close)
+			o, err := New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := newHTTPRunner("req", ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.client.Timeout = 100 * time.Millisecond
+			r.operator = o
+			err = r.Run(ctx, &httpRequest{path: "/", method: http.MethodGet, stream: &httpStream{}})
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "awaiting response headers") {
+					t.Errorf("got %v\nwant timeout error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, ok := o.store.latest()["res"].(map[string]any)
+			if !ok {
+				t.Fatalf("invalid res: %#v", o.store.latest()["res"])
+			}
+			if got, ok := res["messages"].([]any); !ok || len(got) != 6 {
+				t.Errorf("got %v\nwant %v messages", res["messages"], 6)
+			}
+		})
+	}
+}
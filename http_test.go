@@ -33,8 +33,8 @@ func TestHTTPRunnerRunUsingGitHubAPI(t *testing.T) {
 				path:      "/users/k1LoW",
 				method:    http.MethodGet,
 				mediaType: MediaTypeApplicationJSON,
-				headers: map[string]string{
-					"Authorization": fmt.Sprintf("token %s", os.Getenv("GITHUB_TOKEN")),
+				headers: http.Header{
+					"Authorization": []string{fmt.Sprintf("token %s", os.Getenv("GITHUB_TOKEN"))},
 				},
 			},
 			true,
@@ -45,8 +45,8 @@ func TestHTTPRunnerRunUsingGitHubAPI(t *testing.T) {
 				path:      "/invalid/endpoint",
 				method:    http.MethodGet,
 				mediaType: MediaTypeApplicationJSON,
-				headers: map[string]string{
-					"Authorization": fmt.Sprintf("token %s", os.Getenv("GITHUB_TOKEN")),
+				headers: http.Header{
+					"Authorization": []string{fmt.Sprintf("token %s", os.Getenv("GITHUB_TOKEN"))},
 				},
 			},
 			false,
@@ -133,6 +133,36 @@ two: ni`,
 			MediaTypeApplicationFormUrlencoded,
 			`one=ichi&two=ni`,
 		},
+		{
+			`
+user:
+  '@id': 1
+  name: alice
+  tag:
+    - a
+    - b`,
+			MediaTypeApplicationXML,
+			`<user id="1"><name>alice</name><tag>a</tag><tag>b</tag></user>`,
+		},
+		{
+			`<user><name>alice</name></user>`,
+			MediaTypeTextXML,
+			`<user><name>alice</name></user>`,
+		},
+		{
+			`
+- one: ichi
+- two: ni`,
+			MediaTypeApplicationNDJSON,
+			"{\"one\":\"ichi\"}\n{\"two\":\"ni\"}\n",
+		},
+		{
+			`
+data:
+  one: ichi`,
+			"application/merge-patch+json",
+			`{"data":{"one":"ichi"}}`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -480,6 +510,239 @@ func TestHTTPRunnerWithHandler(t *testing.T) {
 	}
 }
 
+func TestHTTPRunnerWithHandlerBehavesAsClient(t *testing.T) {
+	h := http.NewServeMux()
+	h.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/hello", http.StatusFound)
+	})
+	h.HandleFunc("/redirect2", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/redirect", http.StatusFound)
+	})
+	h.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("hello"))
+	})
+	h.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cr3t", Path: "/"})
+		w.WriteHeader(http.StatusOK)
+	})
+	h.HandleFunc("/me", func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie("session")
+		if err != nil || c.Value != "s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	h.HandleFunc("/host", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Host))
+	})
+	h.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.String()))
+	})
+
+	tests := []struct {
+		name     string
+		opts     []httpRunnerOption
+		reqs     []*httpRequest
+		want     int
+		wantBody string
+		wantErr  bool
+	}{
+		{
+			"follow redirect",
+			nil,
+			[]*httpRequest{{path: "/redirect", method: http.MethodGet}},
+			http.StatusOK,
+			"hello",
+			false,
+		},
+		{
+			"not follow redirect",
+			[]httpRunnerOption{NotFollowRedirect(true)},
+			[]*httpRequest{{path: "/redirect", method: http.MethodGet}},
+			http.StatusFound,
+			"",
+			false,
+		},
+		{
+			"max redirects",
+			[]httpRunnerOption{MaxRedirects(1)},
+			[]*httpRequest{{path: "/redirect2", method: http.MethodGet}},
+			0,
+			"",
+			true,
+		},
+		{
+			"use cookie",
+			[]httpRunnerOption{UseCookie(true)},
+			[]*httpRequest{
+				{path: "/login", method: http.MethodGet},
+				{path: "/me", method: http.MethodGet},
+			},
+			http.StatusOK,
+			"",
+			false,
+		},
+		{
+			"not use cookie",
+			nil,
+			[]*httpRequest{
+				{path: "/login", method: http.MethodGet},
+				{path: "/me", method: http.MethodGet},
+			},
+			http.StatusUnauthorized,
+			"",
+			false,
+		},
+		{
+			"default host",
+			nil,
+			[]*httpRequest{{path: "/host", method: http.MethodGet}},
+			http.StatusOK,
+			"example.com",
+			false,
+		},
+		{
+			"override host",
+			nil,
+			[]*httpRequest{{path: "/host", method: http.MethodGet, headers: http.Header{"Host": []string{"api.example.net"}}}},
+			http.StatusOK,
+			"api.example.net",
+			false,
+		},
+		{
+			"endpoint with base path",
+			[]httpRunnerOption{HTTPEndpoint("https://example.com/api/v1")},
+			[]*httpRequest{{path: "/users", method: http.MethodGet, query: url.Values{"page": []string{"2"}}}},
+			http.StatusOK,
+			"/api/v1/users?page=2",
+			false,
+		},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := New(HTTPRunnerWithHandler("req", h, tt.opts...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, ok := o.httpRunners["req"]
+			if !ok {
+				t.Fatal("http runner not found")
+			}
+			r.operator = o
+			for i, req := range tt.reqs {
+				if req.headers == nil {
+					req.headers = http.Header{}
+				}
+				err := r.Run(ctx, req)
+				if i < len(tt.reqs)-1 {
+					if err != nil {
+						t.Fatal(err)
+					}
+					continue
+				}
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("got %v\nwantErr %v", err, tt.wantErr)
+				}
+			}
+			if tt.wantErr {
+				return
+			}
+			res, ok := o.store.latest()["res"].(map[string]any)
+			if !ok {
+				t.Fatalf("invalid res: %#v", o.store.latest()["res"])
+			}
+			if got := res["status"]; got != tt.want {
+				t.Errorf("got %v\nwant %v", got, tt.want)
+			}
+			if tt.wantBody != "" {
+				if got := res["rawBody"]; got != tt.wantBody {
+					t.Errorf("got %v\nwant %v", got, tt.wantBody)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPRunnerHeadersAndQuery(t *testing.T) {
+	tests := []struct {
+		endpoint    string
+		req         *httpRequest
+		wantQuery   url.Values
+		wantHeaders http.Header
+	}{
+		{
+			"",
+			&httpRequest{
+				path:   "/users?page=2",
+				method: http.MethodGet,
+				headers: http.Header{
+					"Accept": []string{"application/json", "text/plain"},
+				},
+				query: url.Values{
+					"tag": []string{"a", "b"},
+				},
+			},
+			url.Values{
+				"page": []string{"2"},
+				"tag":  []string{"a", "b"},
+			},
+			http.Header{
+				"Accept": []string{"application/json", "text/plain"},
+			},
+		},
+		{
+			"?token=xxx",
+			&httpRequest{
+				path:   "/users",
+				method: http.MethodGet,
+				headers: http.Header{
+					"X-Forwarded-For": []string{"192.0.2.1", "192.0.2.2"},
+				},
+				query: url.Values{
+					"page": []string{"1"},
+				},
+			},
+			url.Values{
+				"page":  []string{"1"},
+				"token": []string{"xxx"},
+			},
+			http.Header{
+				"X-Forwarded-For": []string{"192.0.2.1", "192.0.2.2"},
+			},
+		},
+	}
+	ctx := context.Background()
+	o, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.req.path, func(t *testing.T) {
+			hs, hr := testutil.HTTPServerAndRouter(t)
+			r, err := newHTTPRunner("req", hs.URL+tt.endpoint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.operator = o
+			if err := r.Run(ctx, tt.req); err != nil {
+				t.Fatal(err)
+			}
+			got := hr.Requests()[0]
+			if diff := cmp.Diff(got.URL.Query(), tt.wantQuery, nil); diff != "" {
+				t.Error(diff)
+			}
+			for k, want := range tt.wantHeaders {
+				if diff := cmp.Diff(got.Header.Values(k), want, nil); diff != "" {
+					t.Error(diff)
+				}
+			}
+		})
+	}
+}
+
 func TestNotFollowRedirect(t *testing.T) {
 	tests := []struct {
 		req               *httpRequest
@@ -490,7 +753,7 @@ func TestNotFollowRedirect(t *testing.T) {
 			&httpRequest{
 				path:    "/redirect",
 				method:  http.MethodGet,
-				headers: map[string]string{},
+				headers: http.Header{},
 			},
 			false,
 			http.StatusNotFound,
@@ -499,7 +762,7 @@ func TestNotFollowRedirect(t *testing.T) {
 			&httpRequest{
 				path:    "/redirect",
 				method:  http.MethodGet,
-				headers: map[string]string{},
+				headers: http.Header{},
 			},
 			true,
 			http.StatusFound,
@@ -559,7 +822,7 @@ func TestHTTPCerts(t *testing.T) {
 	req := &httpRequest{
 		path:    "/users/1",
 		method:  http.MethodGet,
-		headers: map[string]string{},
+		headers: http.Header{},
 	}
 	for i, tt := range tests {
 		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
@@ -607,7 +870,7 @@ func TestHTTPRunnerInitializeWithCerts(t *testing.T) {
 	req := &httpRequest{
 		path:    "/users/1",
 		method:  http.MethodGet,
-		headers: map[string]string{},
+		headers: http.Header{},
 	}
 	for i, tt := range tests {
 		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
@@ -0,0 +1,156 @@
+package runn
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+const (
+	httpTraceDNSKey          = "dns"
+	httpTraceConnectKey      = "connect"
+	httpTraceTLSHandshakeKey = "tls"
+	httpTraceFirstByteKey    = "ttfb"
+	httpTraceTotalKey        = "total"
+	httpTraceConnReusedKey   = "reused"
+)
+
+// HTTPTrace is the timing breakdown of HTTP request.
+type HTTPTrace struct {
+	// DNS is the duration of DNS lookup.
+	DNS time.Duration
+	// Connect is the duration of establishing TCP connection.
+	Connect time.Duration
+	// TLSHandshake is the duration of TLS handshake.
+	TLSHandshake time.Duration
+	// FirstByte is the duration from the start of the request to the first byte of the response ( time to first byte ).
+	FirstByte time.Duration
+	// Total is the duration from the start of the request to the end of reading the response body.
+	Total time.Duration
+	// ConnReused is whether the connection has been previously used for another HTTP request.
+	ConnReused bool
+}
+
+// toMap converts the trace into the value recorded as `res.trace`. Durations are in milliseconds.
+func (t *HTTPTrace) toMap() map[string]any {
+	return map[string]any{
+		httpTraceDNSKey:          durationToMilliseconds(t.DNS),
+		httpTraceConnectKey:      durationToMilliseconds(t.Connect),
+		httpTraceTLSHandshakeKey: durationToMilliseconds(t.TLSHandshake),
+		httpTraceFirstByteKey:    durationToMilliseconds(t.FirstByte),
+		httpTraceTotalKey:        durationToMilliseconds(t.Total),
+		httpTraceConnReusedKey:   t.ConnReused,
+	}
+}
+
+// httpTracer records the timing of HTTP request using net/http/httptrace.
+type httpTracer struct {
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+	end          time.Time
+	reused       bool
+	mu           sync.Mutex
+}
+
+func newHTTPTracer() *httpTracer {
+	return &httpTracer{}
+}
+
+func (t *httpTracer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(_ httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.dnsStart = time.Now()
+		},
+		DNSDone: func(_ httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.dnsDone = time.Now()
+		},
+		ConnectStart: func(_, _ string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			// With multiple addresses, ConnectStart may be called more than once. Keep the first one.
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(_, _ string, err error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if err == nil {
+				t.connectDone = time.Now()
+			}
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, _ error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.tlsDone = time.Now()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.reused = info.Reused
+		},
+		GotFirstResponseByte: func() {
+			t.gotFirstResponseByte()
+		},
+	}
+}
+
+func (t *httpTracer) begin() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.start = time.Now()
+}
+
+func (t *httpTracer) gotFirstResponseByte() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.firstByte.IsZero() {
+		t.firstByte = time.Now()
+	}
+}
+
+func (t *httpTracer) finish() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.end = time.Now()
+}
+
+func (t *httpTracer) result() *HTTPTrace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return &HTTPTrace{
+		DNS:          between(t.dnsStart, t.dnsDone),
+		Connect:      between(t.connectStart, t.connectDone),
+		TLSHandshake: between(t.tlsStart, t.tlsDone),
+		FirstByte:    between(t.start, t.firstByte),
+		Total:        between(t.start, t.end),
+		ConnReused:   t.reused,
+	}
+}
+
+func between(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+func durationToMilliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
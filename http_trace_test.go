@@ -0,0 +1,122 @@
+package runn
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/k1LoW/runn/testutil"
+)
+
+func TestHTTPRunnerTrace(t *testing.T) {
+	ctx := context.Background()
+	hs := testutil.HTTPServer(t)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("hello"))
+	})
+	tests := []struct {
+		name    string
+		newFunc func() (*httpRunner, error)
+	}{
+		{
+			"client",
+			func() (*httpRunner, error) {
+				r, err := newHTTPRunner("req", hs.URL)
+				if err != nil {
+					return nil, err
+				}
+				r.client = hs.Client()
+				return r, nil
+			},
+		},
+		{
+			"handler",
+			func() (*httpRunner, error) {
+				return newHTTPRunnerWithHandler("req", h)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := tt.newFunc()
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.operator = o
+			if err := r.Run(ctx, &httpRequest{path: "/users", method: http.MethodGet}); err != nil {
+				t.Fatal(err)
+			}
+			res, ok := o.store.latest()["res"].(map[string]any)
+			if !ok {
+				t.Fatalf("invalid res: %#v", o.store.latest()["res"])
+			}
+			trace, ok := res["trace"].(map[string]any)
+			if !ok {
+				t.Fatalf("invalid trace: %#v", res["trace"])
+			}
+			for _, k := range []string{"dns", "connect", "tls", "ttfb", "total"} {
+				v, ok := trace[k].(float64)
+				if !ok {
+					t.Errorf("invalid trace.%s: %#v", k, trace[k])
+					continue
+				}
+				if v < 0 {
+					t.Errorf("trace.%s should not be negative: %v", k, v)
+				}
+			}
+			if trace["ttfb"].(float64) > trace["total"].(float64) {
+				t.Errorf("trace.ttfb should not be greater than trace.total: %v > %v", trace["ttfb"], trace["total"])
+			}
+			if trace["total"].(float64) <= 0 {
+				t.Errorf("trace.total should be positive: %v", trace["total"])
+			}
+			if _, ok := trace["reused"].(bool); !ok {
+				t.Errorf("invalid trace.reused: %#v", trace["reused"])
+			}
+		})
+	}
+}
+
+func TestHTTPTraceTLSHandshake(t *testing.T) {
+	ctx := context.Background()
+	hs := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(func() {
+		hs.Close()
+	})
+	o, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := newHTTPRunner("req", hs.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.client = hs.Client()
+	r.skipVerify = true
+	r.operator = o
+	if err := r.Run(ctx, &httpRequest{path: "/", method: http.MethodGet}); err != nil {
+		t.Fatal(err)
+	}
+	res, ok := o.store.latest()["res"].(map[string]any)
+	if !ok {
+		t.Fatalf("invalid res: %#v", o.store.latest()["res"])
+	}
+	trace, ok := res["trace"].(map[string]any)
+	if !ok {
+		t.Fatalf("invalid trace: %#v", res["trace"])
+	}
+	if trace["tls"].(float64) <= 0 {
+		t.Errorf("trace.tls should be positive: %v", trace["tls"])
+	}
+	if trace["connect"].(float64) <= 0 {
+		t.Errorf("trace.connect should be positive: %v", trace["connect"])
+	}
+}
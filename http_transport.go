@@ -0,0 +1,92 @@
+package runn
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// HTTPMiddleware wraps the http.RoundTripper of HTTP runner.
+type HTTPMiddleware func(http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc is an adapter to allow the use of ordinary functions as http.RoundTripper.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// roundTripperUnwrapper is implemented by http.RoundTripper wrapping another http.RoundTripper.
+type roundTripperUnwrapper interface {
+	Unwrap() http.RoundTripper
+}
+
+// chainHTTPMiddlewares wraps rt with middlewares. The first middleware is the outermost.
+func chainHTTPMiddlewares(rt http.RoundTripper, middlewares []HTTPMiddleware) http.RoundTripper {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		rt = middlewares[i](rt)
+	}
+	return rt
+}
+
+// baseTransport returns *http.Transport underlying rt by unwrapping custom transports.
+func baseTransport(rt http.RoundTripper) (*http.Transport, bool) {
+	for rt != nil {
+		switch v := rt.(type) {
+		case *http.Transport:
+			return v, true
+		case roundTripperUnwrapper:
+			rt = v.Unwrap()
+		default:
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// configureTLS applies the TLS settings ( and `resolve:` ) of the runner to *http.Transport underlying the transport of the client.
+// Custom transports without *http.Transport ( e.g. in-memory transports ) are left as they are, but it returns an error if the TLS settings are set because they can not be applied.
+func (rnr *httpRunner) configureTLS() error {
+	ts, ok := baseTransport(rnr.client.Transport)
+	if !ok {
+		if len(rnr.cacert) != 0 || len(rnr.cert) != 0 || rnr.skipVerify || rnr.dialer != nil {
+			return fmt.Errorf("could not apply TLS settings ( cacert, cert, key, skipVerify, resolve, serverName, tlsMinVersion, tlsMaxVersion or alpn ) because *http.Transport is not found in the transport: %T", rnr.client.Transport)
+		}
+		return nil
+	}
+	if ts.TLSClientConfig != nil {
+		ts.TLSClientConfig = ts.TLSClientConfig.Clone()
+	} else {
+		ts.TLSClientConfig = new(tls.Config)
+	}
+	ts.TLSClientConfig.InsecureSkipVerify = rnr.skipVerify
+	if len(rnr.cacert) != 0 {
+		certpool, err := x509.SystemCertPool()
+		if err != nil {
+			// FIXME for Windows
+			// ref: https://github.com/golang/go/issues/18609
+			certpool = x509.NewCertPool()
+		}
+		if !certpool.AppendCertsFromPEM(rnr.cacert) {
+			return errors.New("failed to append cacert")
+		}
+		ts.TLSClientConfig.RootCAs = certpool
+	}
+	if len(rnr.cert) != 0 && len(rnr.key) != 0 {
+		cert, err := tls.X509KeyPair(rnr.cert, rnr.key)
+		if err != nil {
+			return fmt.Errorf("could not set certificates: %w", err)
+		}
+		ts.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	}
+	if rnr.dialer != nil {
+		rnr.dialer.applyTLS(ts.TLSClientConfig)
+		// `resolve:` does not apply to the unix domain socket
+		if len(rnr.dialer.resolve) > 0 && rnr.socket == "" {
+			ts.DialContext = rnr.dialer.DialContext
+		}
+	}
+	return nil
+}
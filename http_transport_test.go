@@ -0,0 +1,185 @@
+package runn
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+type unwrappableTransport struct {
+	base http.RoundTripper
+}
+
+func (t *unwrappableTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req)
+}
+
+func (t *unwrappableTransport) Unwrap() http.RoundTripper {
+	return t.base
+}
+
+func TestHTTPMiddlewares(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Join(r.Header.Values("X-Middleware"), ",")))
+	})
+	var count int64
+	mw := func(name string) HTTPMiddleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				req.Header.Add("X-Middleware", name)
+				res, err := next.RoundTrip(req)
+				if err == nil {
+					atomic.AddInt64(&count, 1)
+				}
+				return res, err
+			})
+		}
+	}
+	ctx := context.Background()
+	o, err := New(HTTPRunnerWithHandler("req", h, HTTPMiddlewares(mw("first"), mw("second"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, ok := o.httpRunners["req"]
+	if !ok {
+		t.Fatal("http runner not found")
+	}
+	r.operator = o
+	for i := 0; i < 2; i++ {
+		req := &httpRequest{
+			path:    "/",
+			method:  http.MethodGet,
+			headers: http.Header{},
+		}
+		if err := r.Run(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+		res, ok := o.store.latest()["res"].(map[string]any)
+		if !ok {
+			t.Fatalf("invalid res: %#v", o.store.latest()["res"])
+		}
+		if got, want := res["rawBody"], "first,second"; got != want {
+			t.Errorf("got %v\nwant %v", got, want)
+		}
+	}
+	if got, want := atomic.LoadInt64(&count), int64(4); got != want {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+	// The transport of the client is not wrapped repeatedly
+	if _, ok := r.client.Transport.(*handlerTransport); !ok {
+		t.Errorf("got %T\nwant %T", r.client.Transport, &handlerTransport{})
+	}
+}
+
+func TestHTTPMiddlewaresInvalid(t *testing.T) {
+	c := &httpRunnerConfig{}
+	if err := HTTPMiddlewares(nil)(c); err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestHTTPRunnerTLSWithCustomTransport(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(ts.Close)
+	cacert := filepath.Join(t.TempDir(), "cacert.pem")
+	if err := os.WriteFile(cacert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		transport func() http.RoundTripper
+		opts      []httpRunnerOption
+		wantErr   bool
+	}{
+		{
+			"unwrappable transport without cacert",
+			func() http.RoundTripper {
+				return &unwrappableTransport{base: http.DefaultTransport.(*http.Transport).Clone()}
+			},
+			[]httpRunnerOption{UseCookie(false)},
+			true,
+		},
+		{
+			"unwrappable transport with cacert",
+			func() http.RoundTripper {
+				return &unwrappableTransport{base: http.DefaultTransport.(*http.Transport).Clone()}
+			},
+			[]httpRunnerOption{HTTPCACert(cacert)},
+			false,
+		},
+		{
+			"unwrappable transport with skipVerify",
+			func() http.RoundTripper {
+				return &unwrappableTransport{base: http.DefaultTransport.(*http.Transport).Clone()}
+			},
+			[]httpRunnerOption{HTTPSkipVerify(true)},
+			false,
+		},
+		{
+			"opaque transport without TLS settings",
+			func() http.RoundTripper {
+				base := ts.Client().Transport
+				return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					return base.RoundTrip(req)
+				})
+			},
+			[]httpRunnerOption{UseCookie(false)},
+			false,
+		},
+		{
+			"opaque transport with cacert",
+			func() http.RoundTripper {
+				base := ts.Client().Transport
+				return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					return base.RoundTrip(req)
+				})
+			},
+			[]httpRunnerOption{HTTPCACert(cacert)},
+			true,
+		},
+		{
+			"middleware with cacert",
+			func() http.RoundTripper {
+				return nil
+			},
+			[]httpRunnerOption{HTTPCACert(cacert), HTTPMiddlewares(func(next http.RoundTripper) http.RoundTripper {
+				return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					return next.RoundTrip(req)
+				})
+			})},
+			false,
+		},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &http.Client{Transport: tt.transport()}
+			o, err := New(HTTPRunner("req", ts.URL, client, tt.opts...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, ok := o.httpRunners["req"]
+			if !ok {
+				t.Fatal("http runner not found")
+			}
+			r.operator = o
+			req := &httpRequest{
+				path:    "/",
+				method:  http.MethodGet,
+				headers: http.Header{},
+			}
+			if err := r.Run(ctx, req); (err != nil) != tt.wantErr {
+				t.Errorf("got %v\nwantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -34,8 +34,14 @@ func (e *UnsupportedError) Unwrap() error {
 }
 
 func newHttpValidator(c *httpRunnerConfig) (httpValidator, error) {
-	if c.OpenApi3DocLocation != "" || c.openApi3Doc != nil {
+	useOpenApi3 := c.OpenApi3DocLocation != "" || c.openApi3Doc != nil
+	switch {
+	case useOpenApi3 && c.JSONSchema != "":
+		return nil, errors.New("openapi3 and jsonSchema cannot be used together")
+	case useOpenApi3:
 		return newOpenApi3Validator(c)
+	case c.JSONSchema != "":
+		return newJSONSchemaValidator(c)
 	}
 	return newNopValidator(), nil
 }
@@ -58,37 +64,15 @@ type openApi3Validator struct {
 	skipValidateRequest  bool
 	skipValidateResponse bool
 	doc                  *openapi3.T
+	// location of the document ( empty if the document is given as data )
+	location string
 }
 
 func newOpenApi3Validator(c *httpRunnerConfig) (*openApi3Validator, error) {
 	if c.OpenApi3DocLocation != "" {
-		l := c.OpenApi3DocLocation
-		ctx := context.Background()
-		loader := openapi3.NewLoader()
-		var doc *openapi3.T
-		switch {
-		case strings.HasPrefix(l, "https://") || strings.HasPrefix(l, "http://"):
-			u, err := url.Parse(l)
-			if err != nil {
-				return nil, err
-			}
-			doc, err = loader.LoadFromURI(u)
-			if err != nil {
-				return nil, err
-			}
-		default:
-			b, err := readFile(l)
-			if err != nil {
-				return nil, err
-			}
-			doc, err = loader.LoadFromData(b)
-			if err != nil {
-				return nil, err
-			}
-		}
-
-		if err := doc.Validate(ctx); err != nil {
-			return nil, fmt.Errorf("openapi3 document validation error: %w", err)
+		doc, err := loadOpenApi3Doc(c.OpenApi3DocLocation)
+		if err != nil {
+			return nil, err
 		}
 		c.openApi3Doc = doc
 	}
@@ -100,9 +84,42 @@ func newOpenApi3Validator(c *httpRunnerConfig) (*openApi3Validator, error) {
 		skipValidateRequest:  c.SkipValidateRequest,
 		skipValidateResponse: c.SkipValidateResponse,
 		doc:                  c.openApi3Doc,
+		location:             c.OpenApi3DocLocation,
 	}, nil
 }
 
+// loadOpenApi3Doc loads and validates the OpenAPI 3 document from the file path or URL.
+func loadOpenApi3Doc(l string) (*openapi3.T, error) {
+	ctx := context.Background()
+	loader := openapi3.NewLoader()
+	var doc *openapi3.T
+	switch {
+	case strings.HasPrefix(l, "https://") || strings.HasPrefix(l, "http://"):
+		u, err := url.Parse(l)
+		if err != nil {
+			return nil, err
+		}
+		doc, err = loader.LoadFromURI(u)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		b, err := readFile(l)
+		if err != nil {
+			return nil, err
+		}
+		doc, err = loader.LoadFromData(b)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := doc.Validate(ctx); err != nil {
+		return nil, fmt.Errorf("openapi3 document validation error: %w", err)
+	}
+	return doc, nil
+}
+
 // FIXME: better to depend on any library
 // currently refer to https://developer.mozilla.org/en-US/docs/Web/HTTP/Basics_of_HTTP/MIME_types
 var registerBodyMimeTypes = []string{
@@ -164,6 +181,15 @@ func (v *openApi3Validator) requestInput(req *http.Request) (*openapi3filter.Req
 	}, nil
 }
 
+// operation returns the operation ( method and path template ) of the OpenAPI document that matches the request.
+func (v *openApi3Validator) operation(req *http.Request) (string, error) {
+	input, err := v.requestInput(req)
+	if err != nil {
+		return "", err
+	}
+	return httpCoverageOperation(input.Route.Method, input.Route.Path), nil
+}
+
 func (v *openApi3Validator) responseInput(req *http.Request, res *http.Response) (*openapi3filter.ResponseValidationInput, error) {
 	reqInput, err := v.requestInput(req)
 	if err != nil {
@@ -120,6 +120,9 @@ func (rnr *includeRunner) Run(ctx context.Context, c *includeConfig) error {
 	for _, r := range oo.sshRunners {
 		r.operator = rnr.operator
 	}
+	for _, r := range oo.wsRunners {
+		r.operator = rnr.operator
+	}
 
 	return nil
 }
@@ -142,6 +145,9 @@ func (o *operator) newNestedOperator(parent *step, opts ...Option) (*operator, e
 	for k, r := range o.sshRunners {
 		popts = append(popts, runnSSHRunner(k, r))
 	}
+	for k, r := range o.wsRunners {
+		popts = append(popts, runnWSRunner(k, r))
+	}
 
 	popts = append(popts, Debug(o.debug))
 	popts = append(popts, Profile(o.profile))
@@ -160,6 +166,8 @@ func (o *operator) newNestedOperator(parent *step, opts ...Option) (*operator, e
 	oo.t = o.thisT
 	oo.thisT = o.thisT
 	oo.sw = o.sw
+	oo.cov = o.cov
+	oo.cassetteCfg = o.cassetteCfg
 	oo.capturers = o.capturers
 	oo.parent = parent
 	oo.store.parentVars = o.store.toMap()
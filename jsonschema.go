@@ -0,0 +1,171 @@
+package runn
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+const jsonSchemaRootContext = "(root)"
+
+// jsonSchema is the JSON Schema to validate JSON values ( HTTP response bodies and gRPC response messages ).
+type jsonSchema struct {
+	location string
+	schema   *gojsonschema.Schema
+}
+
+// loadJSONSchema loads the JSON Schema from the location.
+// The location is a file path ( `json://` and `yaml://` schemes are allowed ) or a URL.
+// Relative file paths are resolved from root.
+func loadJSONSchema(l, root string) (*jsonSchema, error) {
+	var loader gojsonschema.JSONLoader
+	switch {
+	case strings.HasPrefix(l, "https://") || strings.HasPrefix(l, "http://"):
+		loader = gojsonschema.NewReferenceLoader(l)
+	case strings.HasPrefix(l, yamlEvaluator.scheme):
+		v, err := evaluateSchema(yamlEvaluator.scheme+fp(strings.TrimPrefix(l, yamlEvaluator.scheme), root), "", nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load JSON Schema: %w", err)
+		}
+		loader = gojsonschema.NewGoLoader(v)
+	default:
+		p, err := filepath.Abs(fp(strings.TrimPrefix(l, jsonEvaluator.scheme), root))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load JSON Schema: %w", err)
+		}
+		// Use the reference loader so that relative $ref can be resolved
+		loader = gojsonschema.NewReferenceLoader(fmt.Sprintf("file://%s", filepath.ToSlash(p)))
+	}
+	s, err := gojsonschema.NewSchema(loader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load JSON Schema %s: %w", l, err)
+	}
+	return &jsonSchema{
+		location: l,
+		schema:   s,
+	}, nil
+}
+
+// validate validates the value and returns the error that reports the JSON pointer of each violation.
+func (s *jsonSchema) validate(v any) error {
+	res, err := s.schema.Validate(gojsonschema.NewGoLoader(v))
+	if err != nil {
+		return fmt.Errorf("json schema validation error: %w", err)
+	}
+	if res.Valid() {
+		return nil
+	}
+	var msgs []string
+	for _, e := range res.Errors() {
+		msgs = append(msgs, fmt.Sprintf("  %q: %s", jsonPointer(e.Context()), e.Description()))
+	}
+	return fmt.Errorf("json schema validation error (%s):\n%s", s.location, strings.Join(msgs, "\n"))
+}
+
+// jsonPointer converts the context of gojsonschema ( e.g. (root).users.0.name ) to the JSON pointer ( e.g. /users/0/name ).
+func jsonPointer(c *gojsonschema.JsonContext) string {
+	if c == nil {
+		return ""
+	}
+	const sep = "\x00"
+	tokens := strings.Split(c.String(sep), sep)
+	if len(tokens) > 0 && tokens[0] == jsonSchemaRootContext {
+		tokens = tokens[1:]
+	}
+	var b strings.Builder
+	for _, t := range tokens {
+		b.WriteString("/")
+		b.WriteString(strings.ReplaceAll(strings.ReplaceAll(t, "~", "~0"), "/", "~1"))
+	}
+	return b.String()
+}
+
+// jsonSchemaValidator validates HTTP response bodies using JSON Schema.
+type jsonSchemaValidator struct {
+	skipValidateResponse bool
+	schema               *jsonSchema
+}
+
+func newJSONSchemaValidator(c *httpRunnerConfig) (*jsonSchemaValidator, error) {
+	s, err := loadJSONSchema(c.JSONSchema, c.root)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonSchemaValidator{
+		skipValidateResponse: c.SkipValidateResponse,
+		schema:               s,
+	}, nil
+}
+
+// ValidateRequest does nothing because JSON Schema is used to validate the response body.
+func (v *jsonSchemaValidator) ValidateRequest(ctx context.Context, req *http.Request) error {
+	return nil
+}
+
+func (v *jsonSchemaValidator) ValidateResponse(ctx context.Context, req *http.Request, res *http.Response) error {
+	if v.skipValidateResponse {
+		return nil
+	}
+	return v.schema.validateResponse(res)
+}
+
+// validateResponse validates the JSON body of the HTTP response and restores the body.
+func (s *jsonSchema) validateResponse(res *http.Response) error {
+	mt, _, err := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if err != nil || !strings.Contains(mt, "json") {
+		return &UnsupportedError{Cause: fmt.Errorf("unsupported content type for json schema validation: %s", res.Header.Get("Content-Type"))}
+	}
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	_ = res.Body.Close()
+	res.Body = io.NopCloser(bytes.NewReader(b))
+	var body any
+	if err := json.Unmarshal(b, &body); err != nil {
+		return fmt.Errorf("json schema validation error: invalid JSON body: %w", err)
+	}
+	return s.validate(body)
+}
+
+// validateMessages validates the response messages of the latest gRPC call using the JSON Schemas of the runner and the step.
+func (rnr *grpcRunner) validateMessages(r *grpcRequest) error {
+	schemas := []*jsonSchema{}
+	if rnr.jsonSchema != nil {
+		schemas = append(schemas, rnr.jsonSchema)
+	}
+	if r.jsonSchema != "" {
+		s, err := loadJSONSchema(r.jsonSchema, rnr.operator.root)
+		if err != nil {
+			return err
+		}
+		schemas = append(schemas, s)
+	}
+	if len(schemas) == 0 {
+		return nil
+	}
+	res, ok := rnr.operator.store.latest()[grpcStoreResponseKey].(map[string]any)
+	if !ok {
+		return nil
+	}
+	messages, ok := res[grpcStoreMessagesKey].([]map[string]any)
+	if !ok {
+		return nil
+	}
+	for _, s := range schemas {
+		for i, m := range messages {
+			if err := s.validate(m); err != nil {
+				return fmt.Errorf("invalid response message[%d]: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package runn
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestJSONSchemaValidate(t *testing.T) {
+	tests := []struct {
+		location string
+		v        any
+		want     []string
+	}{
+		{"testdata/jsonschema/user.json", map[string]any{"id": 1, "name": "alice"}, nil},
+		{"json://testdata/jsonschema/user.json", map[string]any{"id": 1, "name": "alice", "tags": []any{map[string]any{"name": "a"}}}, nil},
+		{"testdata/jsonschema/user.json", map[string]any{"id": "1"}, []string{
+			`"": name is required`,
+			`"/id": Invalid type. Expected: integer, given: string`,
+		}},
+		{"testdata/jsonschema/user.json", map[string]any{"id": 1, "name": "alice", "tags": []any{map[string]any{"name": "a"}, map[string]any{"name": ""}}}, []string{
+			`"/tags/1/name": String length must be greater than or equal to 1`,
+		}},
+		{"testdata/jsonschema/user.json", map[string]any{"id": 1, "name": "alice", "a/b~c": 1}, nil},
+		{"yaml://testdata/jsonschema/user.yml", map[string]any{"id": 1, "name": "alice"}, nil},
+		{"yaml://testdata/jsonschema/user.yml", map[string]any{"id": 1.5, "name": "alice"}, []string{
+			`"/id": Invalid type. Expected: integer, given: number`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.location, func(t *testing.T) {
+			s, err := loadJSONSchema(tt.location, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = s.validate(tt.v)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("got %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("want error")
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(err.Error(), w) {
+					t.Errorf("got %v\nwant to contain %s", err, w)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadJSONSchemaError(t *testing.T) {
+	tests := []string{
+		"testdata/jsonschema/not_exists.json",
+		"yaml://testdata/jsonschema/not_exists.yml",
+		"testdata/vars.yml",
+	}
+	for _, tt := range tests {
+		t.Run(tt, func(t *testing.T) {
+			if _, err := loadJSONSchema(tt, ""); err == nil {
+				t.Error("want error")
+			}
+		})
+	}
+}
+
+func TestHTTPRunnerJSONSchema(t *testing.T) {
+	h := http.NewServeMux()
+	h.HandleFunc("/users/1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", MediaTypeApplicationJSON)
+		_, _ = w.Write([]byte(`{"id":1,"name":"alice"}`))
+	})
+	h.HandleFunc("/users/2", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", MediaTypeApplicationJSON)
+		_, _ = w.Write([]byte(`{"id":"2","tags":[{"name":""}]}`))
+	})
+	h.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", MediaTypeTextPlain)
+		_, _ = w.Write([]byte(`hello`))
+	})
+	tests := []struct {
+		name       string
+		opts       []httpRunnerOption
+		path       string
+		jsonSchema string
+		wantErr    bool
+	}{
+		{"runner valid", []httpRunnerOption{HTTPJSONSchema("testdata/jsonschema/user.json")}, "/users/1", "", false},
+		{"runner invalid", []httpRunnerOption{HTTPJSONSchema("testdata/jsonschema/user.json")}, "/users/2", "", true},
+		{"runner skip validate response", []httpRunnerOption{HTTPJSONSchema("testdata/jsonschema/user.json"), SkipValidateResponse(true)}, "/users/2", "", false},
+		{"runner unsupported content type", []httpRunnerOption{HTTPJSONSchema("testdata/jsonschema/user.json")}, "/hello", "", false},
+		{"step valid", nil, "/users/1", "json://testdata/jsonschema/user.json", false},
+		{"step invalid", nil, "/users/2", "json://testdata/jsonschema/user.json", true},
+		{"step unsupported content type", nil, "/hello", "json://testdata/jsonschema/user.json", false},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := New(HTTPRunnerWithHandler("req", h, tt.opts...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, ok := o.httpRunners["req"]
+			if !ok {
+				t.Fatal("http runner not found")
+			}
+			r.operator = o
+			req := &httpRequest{
+				path:       tt.path,
+				method:     http.MethodGet,
+				headers:    http.Header{},
+				jsonSchema: tt.jsonSchema,
+			}
+			if err := r.Run(ctx, req); (err != nil) != tt.wantErr {
+				t.Errorf("got %v\nwantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGRPCRunnerValidateMessages(t *testing.T) {
+	tests := []struct {
+		name     string
+		runner   string
+		step     string
+		messages []map[string]any
+		wantErr  bool
+	}{
+		{"no schema", "", "", []map[string]any{{"id": "1"}}, false},
+		{"runner valid", "testdata/jsonschema/user.json", "", []map[string]any{{"id": 1, "name": "alice"}}, false},
+		{"runner invalid", "testdata/jsonschema/user.json", "", []map[string]any{{"id": 1, "name": "alice"}, {"id": 2}}, true},
+		{"step invalid", "", "yaml://testdata/jsonschema/user.yml", []map[string]any{{"name": "alice"}}, true},
+		{"no messages", "testdata/jsonschema/user.json", "", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := newGrpcRunner("greq", "localhost:443")
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.operator = o
+			if tt.runner != "" {
+				r.jsonSchema, err = loadJSONSchema(tt.runner, "")
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			o.record(map[string]any{
+				grpcStoreResponseKey: map[string]any{
+					grpcStoreMessagesKey: tt.messages,
+				},
+			})
+			if err := r.validateMessages(&grpcRequest{jsonSchema: tt.step}); (err != nil) != tt.wantErr {
+				t.Errorf("got %v\nwantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
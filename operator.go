@@ -35,6 +35,7 @@ type operator struct {
 	grpcRunners map[string]*grpcRunner
 	cdpRunners  map[string]*cdpRunner
 	sshRunners  map[string]*sshRunner
+	wsRunners   map[string]*wsRunner
 	steps       []*step
 	store       store
 	desc        string
@@ -67,6 +68,12 @@ type operator struct {
 	sw            *stopw.Span
 	capturers     capturers
 	runResult     *RunResult
+	// Shared API coverage collector. nil if coverage is disabled.
+	cov *coverage
+	// Configuration of record/replay of HTTP and gRPC traffic. nil if disabled.
+	cassetteCfg *cassetteConfig
+	// Cassette of the running runbook.
+	cassette *cassette
 
 	mu sync.Mutex
 }
@@ -110,6 +117,9 @@ func (o *operator) Close(force bool) {
 	for _, r := range o.sshRunners {
 		_ = r.Close()
 	}
+	for _, r := range o.wsRunners {
+		_ = r.Close()
+	}
 	for _, r := range o.dbRunners {
 		if !force && r.dsn == "" {
 			continue
@@ -125,6 +135,9 @@ func (o *operator) runStep(ctx context.Context, i int, s *step) error {
 	ids := s.trails()
 	o.capturers.setCurrentTrails(ids)
 	defer o.sw.Start(ids.toInterfaceSlice()...).Stop()
+	if o.cassette != nil {
+		ctx = withCassetteStep(ctx, i)
+	}
 	if i != 0 {
 		// interval:
 		time.Sleep(o.interval)
@@ -167,7 +180,7 @@ func (o *operator) runStep(ctx context.Context, i int, s *step) error {
 			if !ok {
 				return fmt.Errorf("invalid %s: %v", o.stepName(i), e)
 			}
-			req, err := parseHTTPRequest(r)
+			req, err := parseHTTPRequest(r, s.httpRunner.encoders)
 			if err != nil {
 				return err
 			}
@@ -219,6 +232,15 @@ func (o *operator) runStep(ctx context.Context, i int, s *step) error {
 				return fmt.Errorf("ssh command failed on %s: %w", o.stepName(i), err)
 			}
 			run = true
+		case s.wsRunner != nil && s.wsRequest != nil:
+			req, err := parseWSRequest(s.wsRequest, o.expandBeforeRecord)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", o.stepName(i), err)
+			}
+			if err := s.wsRunner.Run(ctx, req); err != nil {
+				return fmt.Errorf("WebSocket request failed on %s: %w", o.stepName(i), err)
+			}
+			run = true
 		case s.execRunner != nil && s.execCommand != nil:
 			e, err := o.expandBeforeRecord(s.execCommand)
 			if err != nil {
@@ -434,6 +456,7 @@ func New(opts ...Option) (*operator, error) {
 		grpcRunners: map[string]*grpcRunner{},
 		cdpRunners:  map[string]*cdpRunner{},
 		sshRunners:  map[string]*sshRunner{},
+		wsRunners:   map[string]*wsRunner{},
 		store: store{
 			steps:    []map[string]any{},
 			stepMap:  map[string]map[string]any{},
@@ -470,6 +493,26 @@ func New(opts ...Option) (*operator, error) {
 	if o.debug {
 		o.capturers = append(o.capturers, NewDebugger(o.stderr))
 	}
+	if bk.coverage {
+		o.cov = newCoverage()
+	}
+	switch {
+	case bk.recordDir != "" && bk.replayDir != "":
+		return nil, errors.New("record and replay cannot be enabled at the same time")
+	case bk.recordDir != "":
+		o.cassetteCfg = &cassetteConfig{
+			mode: cassetteModeRecord,
+			dir:  bk.recordDir,
+		}
+	case bk.replayDir != "":
+		o.cassetteCfg = &cassetteConfig{
+			mode:             cassetteModeReplay,
+			dir:              bk.replayDir,
+			ignoreHeaders:    bk.replayIgnoreHeaders,
+			ignoreBodyFields: bk.replayIgnoreBodyFields,
+			strict:           bk.replayStrict,
+		}
+	}
 	if o.concurrency == "" {
 		o.concurrency = o.id
 	}
@@ -496,6 +539,7 @@ func New(opts ...Option) (*operator, error) {
 		}
 		v.protos = append([]string{}, bk.grpcProtos...)
 		v.importPaths = append([]string{}, bk.grpcImportPaths...)
+		v.protosets = unique(append(append([]string{}, v.protosets...), bk.grpcProtosets...))
 		o.grpcRunners[k] = v
 	}
 	for k, v := range bk.cdpRunners {
@@ -506,6 +550,10 @@ func New(opts ...Option) (*operator, error) {
 		v.operator = o
 		o.sshRunners[k] = v
 	}
+	for k, v := range bk.wsRunners {
+		v.operator = o
+		o.wsRunners[k] = v
+	}
 
 	keys := map[string]struct{}{}
 	for k := range o.httpRunners {
@@ -535,6 +583,12 @@ func New(opts ...Option) (*operator, error) {
 		}
 		keys[k] = struct{}{}
 	}
+	for k := range o.wsRunners {
+		if _, ok := keys[k]; ok {
+			return nil, fmt.Errorf("duplicate runner names (%s): %s", o.bookPath, k)
+		}
+		keys[k] = struct{}{}
+	}
 	var merr error
 	for k, err := range bk.runnerErrs {
 		merr = multierr.Append(merr, fmt.Errorf("runner %s error: %w", k, err))
@@ -734,6 +788,16 @@ func (o *operator) AppendStep(key string, s map[string]any) error {
 				step.sshCommand = vv
 				detected = true
 			}
+			wc, ok := o.wsRunners[k]
+			if ok && !detected {
+				step.wsRunner = wc
+				vv, ok := v.(map[string]any)
+				if !ok {
+					return fmt.Errorf("invalid WebSocket request: %v", v)
+				}
+				step.wsRequest = vv
+				detected = true
+			}
 
 			if !detected {
 				return fmt.Errorf("cannot find client: %s", k)
@@ -794,11 +858,20 @@ func (o *operator) clearResult() {
 	}
 }
 
-func (o *operator) run(ctx context.Context) error {
+func (o *operator) run(ctx context.Context) (rerr error) {
 	defer o.sw.Start(o.trails().toInterfaceSlice()...).Stop()
 	if o.newOnly {
 		return errors.New("this runbook is not allowed to run")
 	}
+	o.registerCoverage()
+	if err := o.openCassette(); err != nil {
+		return fmt.Errorf("failed to run %s: %w", o.bookPathOrID(), err)
+	}
+	defer func() {
+		if err := o.closeCassette(); err != nil && rerr == nil {
+			rerr = fmt.Errorf("failed to run %s: %w", o.bookPathOrID(), err)
+		}
+	}()
 	var err error
 	if o.t != nil {
 		// As test helper
@@ -1134,6 +1207,7 @@ type operators struct {
 	t           *testing.T
 	sw          *stopw.Span
 	profile     bool
+	cov         *coverage
 	shuffle     bool
 	shuffleSeed int64
 	shardN      int
@@ -1171,6 +1245,10 @@ func Load(pathp string, opts ...Option) (*operators, error) {
 	if bk.runConcurrent {
 		ops.concmax = bk.runConcurrentMax
 	}
+	if bk.coverage {
+		ops.cov = newCoverage()
+		ops.cov.threshold = bk.covThreshold
+	}
 	books, err := Books(pathp)
 	if err != nil {
 		return nil, err
@@ -1212,6 +1290,7 @@ func Load(pathp string, opts ...Option) (*operators, error) {
 			idMatched = append(idMatched, o)
 		}
 		o.sw = ops.sw
+		o.cov = ops.cov
 		ops.ops = append(ops.ops, o)
 	}
 
@@ -1324,6 +1403,7 @@ func (ops *operators) SelectedOperators() ([]*operator, error) {
 		}
 		for _, o := range rops {
 			o.sw = ops.sw
+			o.cov = ops.cov
 		}
 		return rops, nil
 	}
@@ -1378,6 +1458,9 @@ func (ops *operators) runN(ctx context.Context) (*runNResult, error) {
 	if err := cg.Wait(); err != nil {
 		return result, err
 	}
+	if ops.cov != nil {
+		result.Coverage = ops.cov.result()
+	}
 	return result, nil
 }
 
@@ -1411,6 +1494,7 @@ func copyOperators(ops []*operator, opts []Option) ([]*operator, error) {
 			return nil, err
 		}
 		oo.id = o.id // Copy id from original operator
+		oo.cov = o.cov
 		c = append(c, oo)
 	}
 	return c, nil
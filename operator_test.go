@@ -711,6 +711,22 @@ func TestGrpc(t *testing.T) {
 	}
 }
 
+func TestWS(t *testing.T) {
+	ctx := context.Background()
+	ts := testutil.WSServer(t)
+	t.Setenv("TEST_WS_END_POINT", testutil.WSURL(ts))
+	o, err := New(Book("testdata/book/ws.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		o.Close(true)
+	})
+	if err := o.Run(ctx); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestAfterFuncAlwaysCall(t *testing.T) {
 	tests := []struct {
 		book    string
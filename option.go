@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/Songmu/prompter"
+	"github.com/dustin/go-humanize"
 	"github.com/k1LoW/duration"
 	"github.com/k1LoW/runn/builtin"
 	"github.com/k1LoW/sshc/v4"
@@ -70,6 +71,9 @@ func Overlay(path string) Option {
 		for k, r := range loaded.sshRunners {
 			bk.sshRunners[k] = r
 		}
+		for k, r := range loaded.wsRunners {
+			bk.wsRunners[k] = r
+		}
 		for k, v := range loaded.vars {
 			bk.vars[k] = v
 		}
@@ -139,6 +143,11 @@ func Underlay(path string) Option {
 				bk.sshRunners[k] = r
 			}
 		}
+		for k, r := range loaded.wsRunners {
+			if _, ok := bk.wsRunners[k]; !ok {
+				bk.wsRunners[k] = r
+			}
+		}
 		for k, v := range loaded.vars {
 			if _, ok := bk.vars[k]; !ok {
 				bk.vars[k] = v
@@ -194,15 +203,52 @@ func Runner(name, dsn string, opts ...httpRunnerOption) Option {
 		}
 		if c.NotFollowRedirect {
 			r.client.CheckRedirect = notFollowRedirectFn
+		} else if c.MaxRedirects > 0 {
+			r.client.CheckRedirect = maxRedirectsFn(c.MaxRedirects)
 		}
 		r.multipartBoundary = c.MultipartBoundary
+		r.decoders = c.decoders
+		r.encoders = c.encoders
+		if c.Auth != nil {
+			r.auth, err = newHTTPAuth(c.Auth)
+			if err != nil {
+				bk.runnerErrs[name] = err
+				return nil
+			}
+		}
+		if c.Sign != nil {
+			if err := c.Sign.validate(); err != nil {
+				bk.runnerErrs[name] = err
+				return nil
+			}
+			r.sign = c.Sign
+		}
+		r.signer = c.signer
+		r.middlewares = c.middlewares
 		if c.Timeout != "" {
 			r.client.Timeout, err = duration.Parse(c.Timeout)
 			if err != nil {
 				return fmt.Errorf("timeout in HttpRunnerConfig is invalid: %w", err)
 			}
 		}
-		if c.OpenApi3DocLocation != "" {
+		if c.MaxBodySize != "" {
+			r.maxBodySize, err = humanize.ParseBytes(c.MaxBodySize)
+			if err != nil {
+				bk.runnerErrs[name] = fmt.Errorf("maxBodySize in HttpRunnerConfig is invalid: %w", err)
+				return nil
+			}
+		}
+		r.dialer, err = c.newDialer()
+		if err != nil {
+			bk.runnerErrs[name] = err
+			return nil
+		}
+		if c.OpenApi3DocLocation != "" || c.JSONSchema != "" {
+			root, err := bk.generateOperatorRoot()
+			if err != nil {
+				return err
+			}
+			c.root = root
 			v, err := newHttpValidator(c)
 			if err != nil {
 				bk.runnerErrs[name] = err
@@ -242,11 +288,32 @@ func HTTPRunner(name, endpoint string, client *http.Client, opts ...httpRunnerOp
 
 		if c.NotFollowRedirect {
 			r.client.CheckRedirect = notFollowRedirectFn
+		} else if c.MaxRedirects > 0 {
+			r.client.CheckRedirect = maxRedirectsFn(c.MaxRedirects)
 		}
 		r.multipartBoundary = c.MultipartBoundary
+		r.decoders = c.decoders
+		r.encoders = c.encoders
+		if c.Auth != nil {
+			r.auth, err = newHTTPAuth(c.Auth)
+			if err != nil {
+				bk.runnerErrs[name] = err
+				return nil
+			}
+		}
+		if c.Sign != nil {
+			if err := c.Sign.validate(); err != nil {
+				bk.runnerErrs[name] = err
+				return nil
+			}
+			r.sign = c.Sign
+		}
+		r.signer = c.signer
+		r.middlewares = c.middlewares
 		if c.OpenApi3DocLocation != "" && !strings.HasPrefix(c.OpenApi3DocLocation, "https://") && !strings.HasPrefix(c.OpenApi3DocLocation, "http://") && !strings.HasPrefix(c.OpenApi3DocLocation, "/") {
 			c.OpenApi3DocLocation = fp(c.OpenApi3DocLocation, root)
 		}
+		c.root = root
 		if c.CACert != "" {
 			b, err := readFile(fp(c.CACert, root))
 			if err != nil {
@@ -275,6 +342,18 @@ func HTTPRunner(name, endpoint string, client *http.Client, opts ...httpRunnerOp
 				return fmt.Errorf("timeout in HttpRunnerConfig is invalid: %w", err)
 			}
 		}
+		if c.MaxBodySize != "" {
+			r.maxBodySize, err = humanize.ParseBytes(c.MaxBodySize)
+			if err != nil {
+				bk.runnerErrs[name] = fmt.Errorf("maxBodySize in HttpRunnerConfig is invalid: %w", err)
+				return nil
+			}
+		}
+		r.dialer, err = c.newDialer()
+		if err != nil {
+			bk.runnerErrs[name] = err
+			return nil
+		}
 		r.useCookie = c.UseCookie
 
 		hv, err := newHttpValidator(c)
@@ -304,17 +383,52 @@ func HTTPRunnerWithHandler(name string, h http.Handler, opts ...httpRunnerOption
 					return nil
 				}
 			}
+			if c.Endpoint != "" {
+				u, err := url.Parse(c.Endpoint)
+				if err != nil {
+					bk.runnerErrs[name] = err
+					return nil
+				}
+				r.endpoint = u
+			}
 			if c.NotFollowRedirect {
-				bk.runnerErrs[name] = errors.New("HTTPRunnerWithHandler does not support option NotFollowRedirect")
-				return nil
+				r.client.CheckRedirect = notFollowRedirectFn
+			} else if c.MaxRedirects > 0 {
+				r.client.CheckRedirect = maxRedirectsFn(c.MaxRedirects)
 			}
+			r.useCookie = c.UseCookie
 			r.multipartBoundary = c.MultipartBoundary
+			r.decoders = c.decoders
+			r.encoders = c.encoders
+			if c.Auth != nil {
+				r.auth, err = newHTTPAuth(c.Auth)
+				if err != nil {
+					bk.runnerErrs[name] = err
+					return nil
+				}
+			}
+			if c.Sign != nil {
+				if err := c.Sign.validate(); err != nil {
+					bk.runnerErrs[name] = err
+					return nil
+				}
+				r.sign = c.Sign
+			}
+			r.signer = c.signer
+			r.middlewares = c.middlewares
 			if c.Timeout != "" {
 				r.client.Timeout, err = duration.Parse(c.Timeout)
 				if err != nil {
 					return fmt.Errorf("timeout in HttpRunnerConfig is invalid: %w", err)
 				}
 			}
+			if c.MaxBodySize != "" {
+				r.maxBodySize, err = humanize.ParseBytes(c.MaxBodySize)
+				if err != nil {
+					bk.runnerErrs[name] = fmt.Errorf("maxBodySize in HttpRunnerConfig is invalid: %w", err)
+					return nil
+				}
+			}
 			v, err := newHttpValidator(c)
 			if err != nil {
 				bk.runnerErrs[name] = err
@@ -407,7 +521,26 @@ func GrpcRunnerWithOptions(name, target string, opts ...grpcRunnerOption) Option
 			}
 			r.importPaths = c.ImportPaths
 			r.protos = c.Protos
+			r.protosets = c.Protosets
+			r.protocol = c.Protocol
 			r.skipVerify = c.SkipVerify
+			d, err := c.newDialer()
+			if err != nil {
+				bk.runnerErrs[name] = err
+				return nil
+			}
+			r.dialer = d
+			if c.JSONSchema != "" {
+				root, err := bk.generateOperatorRoot()
+				if err != nil {
+					return err
+				}
+				r.jsonSchema, err = loadJSONSchema(c.JSONSchema, root)
+				if err != nil {
+					bk.runnerErrs[name] = err
+					return nil
+				}
+			}
 		}
 		bk.grpcRunners[name] = r
 		return nil
@@ -511,6 +644,56 @@ func SSHRunnerWithOptions(name string, opts ...sshRunnerOption) Option {
 	}
 }
 
+// WSRunner - Set WebSocket runner to runbook.
+func WSRunner(name, endpoint string, opts ...wsRunnerOption) Option {
+	return func(bk *book) error {
+		delete(bk.runnerErrs, name)
+		r, err := newWSRunner(name, endpoint)
+		if err != nil {
+			bk.runnerErrs[name] = err
+			return nil
+		}
+		c := &wsRunnerConfig{}
+		for _, opt := range opts {
+			if err := opt(c); err != nil {
+				bk.runnerErrs[name] = err
+				return nil
+			}
+		}
+		for k, v := range c.Headers {
+			r.headers.Set(k, v)
+		}
+		r.subprotocols = c.Subprotocols
+		if c.CACert != "" {
+			b, err := readFile(c.CACert)
+			if err != nil {
+				bk.runnerErrs[name] = err
+				return nil
+			}
+			r.cacert = b
+		}
+		if c.Cert != "" {
+			b, err := readFile(c.Cert)
+			if err != nil {
+				bk.runnerErrs[name] = err
+				return nil
+			}
+			r.cert = b
+		}
+		if c.Key != "" {
+			b, err := readFile(c.Key)
+			if err != nil {
+				bk.runnerErrs[name] = err
+				return nil
+			}
+			r.key = b
+		}
+		r.skipVerify = c.SkipVerify
+		bk.wsRunners[name] = r
+		return nil
+	}
+}
+
 // T - Acts as test helper.
 func T(t *testing.T) Option {
 	return func(bk *book) error {
@@ -584,6 +767,70 @@ func Profile(profile bool) Option {
 	}
 }
 
+// Coverage - Enable collecting API coverage of runners against OpenAPI documents and gRPC service descriptors.
+func Coverage(enable bool) Option {
+	return func(bk *book) error {
+		if !bk.coverage {
+			bk.coverage = enable
+		}
+		return nil
+	}
+}
+
+// CoverageThreshold - Set the threshold percentage of API coverage of each runner, and enable collecting API coverage.
+// The result of coverage that has a runner below the threshold fails on CheckThreshold.
+func CoverageThreshold(percentage float64) Option {
+	return func(bk *book) error {
+		if percentage < 0 || percentage > 100 {
+			return fmt.Errorf("invalid coverage threshold: %v", percentage)
+		}
+		bk.covThreshold = percentage
+		bk.coverage = true
+		return nil
+	}
+}
+
+// Record - Record HTTP and gRPC traffic of runners to cassette files in the directory.
+func Record(dir string) Option {
+	return func(bk *book) error {
+		bk.recordDir = dir
+		return nil
+	}
+}
+
+// Replay - Replay HTTP and gRPC traffic of runners from cassette files in the directory.
+func Replay(dir string) Option {
+	return func(bk *book) error {
+		bk.replayDir = dir
+		return nil
+	}
+}
+
+// ReplayIgnoreHeaders - Ignore the request headers when matching requests with recorded ones.
+func ReplayIgnoreHeaders(headers ...string) Option {
+	return func(bk *book) error {
+		bk.replayIgnoreHeaders = append(bk.replayIgnoreHeaders, headers...)
+		return nil
+	}
+}
+
+// ReplayIgnoreBodyFields - Ignore the fields of JSON request bodies ( and gRPC request messages ) when matching requests with recorded ones.
+// Nested fields are specified with dot-separated keys ( e.g. `user.createdAt` ).
+func ReplayIgnoreBodyFields(fields ...string) Option {
+	return func(bk *book) error {
+		bk.replayIgnoreBodyFields = append(bk.replayIgnoreBodyFields, fields...)
+		return nil
+	}
+}
+
+// ReplayStrict - Fail on requests that do not match any recorded ones instead of sending them to the servers.
+func ReplayStrict(enable bool) Option {
+	return func(bk *book) error {
+		bk.replayStrict = enable
+		return nil
+	}
+}
+
 // Interval - Set interval between steps.
 func Interval(d time.Duration) Option {
 	return func(bk *book) error {
@@ -655,6 +902,14 @@ func GRPCImportPaths(paths []string) Option {
 	}
 }
 
+// GRPCProtosets - Set the protosets ( FileDescriptorSet files or buf images ) for all gRPC runners.
+func GRPCProtosets(protosets []string) Option {
+	return func(bk *book) error {
+		bk.grpcProtosets = protosets
+		return nil
+	}
+}
+
 // BeforeFunc - Register the function to be run before the runbook is run.
 func BeforeFunc(fn func(*RunResult) error) Option {
 	return func(bk *book) error {
@@ -892,6 +1147,13 @@ func runnSSHRunner(name string, r *sshRunner) Option {
 	}
 }
 
+func runnWSRunner(name string, r *wsRunner) Option {
+	return func(bk *book) error {
+		bk.wsRunners[name] = r
+		return nil
+	}
+}
+
 var (
 	AsTestHelper = T
 	Runbook      = Book
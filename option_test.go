@@ -478,6 +478,12 @@ func TestOptionHTTPRunnerWithHandler(t *testing.T) {
 				t.Fatal(err)
 			}
 		}), nil, 0, 1, 0},
+		{"req", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}), []httpRunnerOption{NotFollowRedirect(true), UseCookie(true), HTTPEndpoint("https://example.com/api")}, 0, 1, 0},
+		{"req", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}), []httpRunnerOption{MaxRedirects(-1)}, 0, 0, 1},
 	}
 	for _, tt := range tests {
 		bk := newBook()
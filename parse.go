@@ -1,20 +1,27 @@
 package runn
 
 import (
+	"encoding/base64"
 	"fmt"
+	"net/http"
+	"net/url"
 	"regexp"
 	"strings"
 	"time"
 
+	"github.com/goccy/go-json"
 	"github.com/goccy/go-yaml"
 	"github.com/k1LoW/duration"
+	"github.com/spf13/cast"
 	"google.golang.org/grpc/metadata"
 )
 
-func parseHTTPRequest(v map[string]any) (*httpRequest, error) {
+// parseHTTPRequest parses the HTTP request of the step. encoders are the request encoders registered to the runner in addition to the built-in ones.
+func parseHTTPRequest(v map[string]any, encoders map[string]httpRequestEncodeFunc) (*httpRequest, error) {
 	v = trimDelimiter(v)
 	req := &httpRequest{
-		headers: map[string]string{},
+		headers:  http.Header{},
+		encoders: encoders,
 	}
 	part, err := yaml.Marshal(v)
 	if err != nil {
@@ -45,12 +52,43 @@ func parseHTTPRequest(v map[string]any) (*httpRequest, error) {
 					return nil, fmt.Errorf("invalid request: %s", string(part))
 				}
 				for k, v := range hm {
-					req.headers[k], ok = v.(string)
-					if !ok {
+					switch vv := v.(type) {
+					case string:
+						req.headers.Add(k, vv)
+					case []any:
+						for _, vvv := range vv {
+							s, ok := vvv.(string)
+							if !ok {
+								return nil, fmt.Errorf("invalid request: %s", string(part))
+							}
+							req.headers.Add(k, s)
+						}
+					default:
 						return nil, fmt.Errorf("invalid request: %s", string(part))
 					}
 				}
 			}
+			qm, ok := vvvvv["query"]
+			if ok {
+				qm, ok := qm.(map[string]any)
+				if !ok {
+					return nil, fmt.Errorf("invalid request: %s", string(part))
+				}
+				req.query = url.Values{}
+				for k, v := range qm {
+					vs, ok := v.([]any)
+					if !ok {
+						vs = []any{v}
+					}
+					for _, vv := range vs {
+						s, err := cast.ToStringE(vv)
+						if err != nil {
+							return nil, fmt.Errorf("invalid request: %s", string(part))
+						}
+						req.query.Add(k, s)
+					}
+				}
+			}
 			bm, ok := vvvvv["body"]
 			if ok {
 				switch v := bm.(type) {
@@ -69,6 +107,44 @@ func parseHTTPRequest(v map[string]any) (*httpRequest, error) {
 					}
 				}
 			}
+			sm, ok := vvvvv["stream"]
+			if ok {
+				req.stream, err = parseHTTPStream(sm)
+				if err != nil {
+					return nil, fmt.Errorf("invalid request: %s: %w", string(part), err)
+				}
+			}
+			gm, ok := vvvvv["graphql"]
+			if ok {
+				req.graphql, err = parseHTTPGraphQL(gm)
+				if err != nil {
+					return nil, fmt.Errorf("invalid request: %s: %w", string(part), err)
+				}
+			}
+			jm, ok := vvvvv["jsonSchema"]
+			if ok {
+				s, ok := jm.(string)
+				if !ok {
+					return nil, fmt.Errorf("invalid request: %s", string(part))
+				}
+				req.jsonSchema = s
+			}
+			stm, ok := vvvvv["saveTo"]
+			if ok {
+				s, ok := stm.(string)
+				if !ok {
+					return nil, fmt.Errorf("invalid request: %s", string(part))
+				}
+				req.saveTo = s
+			}
+			cm, ok := vvvvv["compress"]
+			if ok {
+				s, ok := cm.(string)
+				if !ok {
+					return nil, fmt.Errorf("invalid request: %s", string(part))
+				}
+				req.compress = s
+			}
 			um, ok := vvvvv["useCookie"]
 			if ok {
 				switch v := um.(type) {
@@ -167,6 +243,24 @@ func parseGrpcRequest(v map[string]any, expand func(any) (any, error)) (*grpcReq
 				return nil, fmt.Errorf("invalid request: %s: %w", string(part), err)
 			}
 		}
+		jm, ok := vvv["jsonSchema"]
+		if ok {
+			jme, err := expand(jm)
+			if err != nil {
+				return nil, err
+			}
+			req.jsonSchema, ok = jme.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid request: %s", string(part))
+			}
+		}
+		rm, ok := vvv["receive"]
+		if ok {
+			req.receive, err = parseGrpcReceive(rm, expand)
+			if err != nil {
+				return nil, fmt.Errorf("invalid request: %s: %w", string(part), err)
+			}
+		}
 		// `message:` and `messages:` expand at run time so not here
 		mm, ok := vvv["message"]
 		if ok {
@@ -220,6 +314,20 @@ func parseGrpcRequest(v map[string]any, expand func(any) (any, error)) (*grpcReq
 							op: op,
 						})
 					case map[string]any:
+						if rm, ok := v["receive"]; ok && len(v) == 1 {
+							if _, ok := rm.(map[string]any); ok {
+								// receive op with conditions ( e.g. `receive: {until: ..., count: 3, timeout: 5s}` )
+								rc, err := parseGrpcReceive(rm, expand)
+								if err != nil {
+									return nil, fmt.Errorf("invalid request: %s: %w", string(part), err)
+								}
+								req.messages = append(req.messages, &grpcMessage{
+									op:      GRPCOpReceive,
+									receive: rc,
+								})
+								continue
+							}
+						}
 						req.messages = append(req.messages, &grpcMessage{
 							op:     GRPCOpMessage,
 							params: v,
@@ -318,6 +426,161 @@ func parseSSHCommand(v map[string]any, expand func(any) (any, error)) (*sshComma
 	return sc, nil
 }
 
+func parseWSRequest(v map[string]any, expand func(any) (any, error)) (*wsRequest, error) {
+	part, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	v = trimDelimiter(v)
+	vv, err := expand(v)
+	if err != nil {
+		return nil, err
+	}
+	vvv, ok := vv.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid request: %s", string(part))
+	}
+	req := &wsRequest{
+		headers: http.Header{},
+	}
+	for k, vvvv := range vvv {
+		switch k {
+		case "headers":
+			hm, ok := vvvv.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("invalid request: %s", string(part))
+			}
+			for k, v := range hm {
+				switch vv := v.(type) {
+				case []any:
+					for _, vvv := range vv {
+						req.headers.Add(k, cast.ToString(vvv))
+					}
+				default:
+					req.headers.Add(k, cast.ToString(vv))
+				}
+			}
+		case "timeout":
+			req.timeout, err = parseDuration(cast.ToString(vvvv))
+			if err != nil {
+				return nil, fmt.Errorf("invalid request: %s: %w", string(part), err)
+			}
+		case "messages":
+			ms, ok := vvvv.([]any)
+			if !ok {
+				return nil, fmt.Errorf("invalid request: %s", string(part))
+			}
+			for _, m := range ms {
+				wm, err := parseWSMessage(m)
+				if err != nil {
+					return nil, fmt.Errorf("invalid request: %s: %w", string(part), err)
+				}
+				req.messages = append(req.messages, wm)
+			}
+		default:
+			return nil, fmt.Errorf("invalid request: %s", string(part))
+		}
+	}
+	if len(req.messages) == 0 {
+		return nil, fmt.Errorf("invalid request: %s", string(part))
+	}
+	return req, nil
+}
+
+func parseWSMessage(v any) (*wsMessage, error) {
+	switch vv := v.(type) {
+	case string:
+		op := WSOp(vv)
+		if op != WSOpReceive && op != WSOpClose {
+			return nil, fmt.Errorf("invalid message: %s", vv)
+		}
+		return &wsMessage{op: op}, nil
+	case map[string]any:
+		if len(vv) != 1 {
+			return nil, fmt.Errorf("invalid message: %v", vv)
+		}
+		for k, p := range vv {
+			op := WSOp(k)
+			switch op {
+			case WSOpText:
+				s, ok := p.(string)
+				if !ok {
+					return nil, fmt.Errorf("invalid text message: %v", p)
+				}
+				return &wsMessage{op: op, data: []byte(s)}, nil
+			case WSOpJSON:
+				b, err := json.Marshal(p)
+				if err != nil {
+					return nil, fmt.Errorf("invalid json message: %w", err)
+				}
+				return &wsMessage{op: op, data: b}, nil
+			case WSOpBinary:
+				s, ok := p.(string)
+				if !ok {
+					return nil, fmt.Errorf("invalid binary message: %v", p)
+				}
+				b, err := base64.StdEncoding.DecodeString(s)
+				if err != nil {
+					return nil, fmt.Errorf("invalid binary message: %w", err)
+				}
+				return &wsMessage{op: op, data: b}, nil
+			case WSOpReceive:
+				m := &wsMessage{op: op}
+				if p == nil {
+					return m, nil
+				}
+				pm, ok := p.(map[string]any)
+				if !ok {
+					return nil, fmt.Errorf("invalid receive: %v", p)
+				}
+				for kk, pv := range pm {
+					switch kk {
+					case "timeout":
+						var err error
+						m.timeout, err = parseDuration(cast.ToString(pv))
+						if err != nil {
+							return nil, fmt.Errorf("invalid receive timeout: %w", err)
+						}
+					default:
+						return nil, fmt.Errorf("invalid receive key: %s", kk)
+					}
+				}
+				return m, nil
+			case WSOpClose:
+				m := &wsMessage{op: op}
+				switch pv := p.(type) {
+				case nil:
+				case map[string]any:
+					for kk, pvv := range pv {
+						switch kk {
+						case wsCloseCodeKey:
+							c, err := cast.ToIntE(pvv)
+							if err != nil {
+								return nil, fmt.Errorf("invalid close code: %w", err)
+							}
+							m.code = c
+						case wsCloseReasonKey:
+							m.reason = cast.ToString(pvv)
+						default:
+							return nil, fmt.Errorf("invalid close key: %s", kk)
+						}
+					}
+				default:
+					c, err := cast.ToIntE(pv)
+					if err != nil {
+						return nil, fmt.Errorf("invalid close code: %w", err)
+					}
+					m.code = c
+				}
+				return m, nil
+			default:
+				return nil, fmt.Errorf("invalid message: %v", vv)
+			}
+		}
+	}
+	return nil, fmt.Errorf("invalid message: %v", v)
+}
+
 func parseServiceAndMethod(in string) (string, string, error) {
 	splitted := strings.Split(strings.TrimPrefix(in, "/"), "/")
 	if len(splitted) < 2 {
@@ -2,6 +2,7 @@ package runn
 
 import (
 	"net/http"
+	"net/url"
 	"testing"
 	"time"
 
@@ -30,7 +31,7 @@ func TestParseHTTPRequest(t *testing.T) {
 				path:      "/login",
 				method:    http.MethodPost,
 				mediaType: MediaTypeApplicationJSON,
-				headers:   map[string]string{},
+				headers:   http.Header{},
 				body: map[string]any{
 					"key": "value",
 				},
@@ -47,7 +48,7 @@ func TestParseHTTPRequest(t *testing.T) {
 				path:      "/users/k1LoW",
 				method:    http.MethodGet,
 				mediaType: "",
-				headers:   map[string]string{},
+				headers:   http.Header{},
 				body:      nil,
 				useCookie: nil,
 			},
@@ -81,7 +82,7 @@ func TestParseHTTPRequest(t *testing.T) {
 				path:      "/users/k1LoW",
 				method:    http.MethodGet,
 				mediaType: "",
-				headers:   map[string]string{},
+				headers:   http.Header{},
 				body:      nil,
 				useCookie: &use,
 			},
@@ -108,12 +109,284 @@ func TestParseHTTPRequest(t *testing.T) {
 				path:      "/users/k1LoW?page=2",
 				method:    http.MethodGet,
 				mediaType: "",
-				headers:   map[string]string{},
+				headers:   http.Header{},
 				body:      nil,
 				useCookie: &notUse,
 			},
 			false,
 		},
+		{
+			`
+/users:
+  get:
+    headers:
+      Accept:
+        - application/json
+        - text/plain
+      x-request-id: abc
+    query:
+      page: 2
+      tag:
+        - a
+        - b
+    body: null
+`,
+			&httpRequest{
+				path:      "/users",
+				method:    http.MethodGet,
+				mediaType: "",
+				headers: http.Header{
+					"Accept":       []string{"application/json", "text/plain"},
+					"X-Request-Id": []string{"abc"},
+				},
+				query: url.Values{
+					"page": []string{"2"},
+					"tag":  []string{"a", "b"},
+				},
+				body: nil,
+			},
+			false,
+		},
+		{
+			`
+/users:
+  get:
+    headers:
+      Accept:
+        - 1
+    body: null
+`,
+			nil,
+			true,
+		},
+		{
+			`
+/users:
+  get:
+    query:
+      - page
+    body: null
+`,
+			nil,
+			true,
+		},
+		{
+			`
+/events:
+  get:
+    stream:
+      count: 3
+      timeout: 5
+      until: 'current.res.messages[-1].event == "done"'
+    body: null
+`,
+			&httpRequest{
+				path:    "/events",
+				method:  http.MethodGet,
+				headers: http.Header{},
+				stream: &httpStream{
+					count:   3,
+					timeout: 5 * time.Second,
+					until:   `current.res.messages[-1].event == "done"`,
+				},
+				body: nil,
+			},
+			false,
+		},
+		{
+			`
+/events:
+  get:
+    stream: true
+    body: null
+`,
+			&httpRequest{
+				path:    "/events",
+				method:  http.MethodGet,
+				headers: http.Header{},
+				stream:  &httpStream{},
+				body:    nil,
+			},
+			false,
+		},
+		{
+			`
+/events:
+  get:
+    stream:
+      max: 3
+    body: null
+`,
+			nil,
+			true,
+		},
+		{
+			`
+/graphql:
+  post:
+    graphql:
+      query: 'query ($id: ID!) { user(id: $id) { name } }'
+      variables:
+        id: 1
+      operationName: GetUser
+      failOnErrors: true
+`,
+			&httpRequest{
+				path:    "/graphql",
+				method:  http.MethodPost,
+				headers: http.Header{},
+				graphql: &httpGraphQL{
+					query:         "query ($id: ID!) { user(id: $id) { name } }",
+					variables:     map[string]any{"id": uint64(1)},
+					operationName: "GetUser",
+					failOnErrors:  true,
+				},
+			},
+			false,
+		},
+		{
+			`
+/graphql:
+  get:
+    graphql:
+      file: testdata/graphql/user.graphql
+`,
+			&httpRequest{
+				path:    "/graphql",
+				method:  http.MethodGet,
+				headers: http.Header{},
+				graphql: &httpGraphQL{
+					file: "testdata/graphql/user.graphql",
+				},
+			},
+			false,
+		},
+		{
+			`
+/graphql:
+  post:
+    graphql:
+      query: '{ users { name } }'
+    body:
+      application/json:
+        query: '{ users { name } }'
+`,
+			nil,
+			true,
+		},
+		{
+			`
+/reports/1:
+  get:
+    saveTo: tmp/report.pdf
+    body: null
+`,
+			&httpRequest{
+				path:    "/reports/1",
+				method:  http.MethodGet,
+				headers: http.Header{},
+				saveTo:  "tmp/report.pdf",
+			},
+			false,
+		},
+		{
+			`
+/users:
+  post:
+    compress: gzip
+    body:
+      application/json:
+        name: alice
+`,
+			&httpRequest{
+				path:      "/users",
+				method:    http.MethodPost,
+				headers:   http.Header{},
+				mediaType: MediaTypeApplicationJSON,
+				body:      map[string]any{"name": "alice"},
+				compress:  "gzip",
+			},
+			false,
+		},
+		{
+			`
+/users:
+  post:
+    compress: lzma
+    body:
+      application/json:
+        name: alice
+`,
+			nil,
+			true,
+		},
+		{
+			`
+/users/1:
+  get:
+    jsonSchema: json://schemas/user.json
+    body: null
+`,
+			&httpRequest{
+				path:       "/users/1",
+				method:     http.MethodGet,
+				headers:    http.Header{},
+				jsonSchema: "json://schemas/user.json",
+			},
+			false,
+		},
+		{
+			`
+/events:
+  get:
+    saveTo: tmp/events.txt
+    stream: true
+`,
+			nil,
+			true,
+		},
+		{
+			`
+/reports/1:
+  get:
+    saveTo:
+      path: tmp/report.pdf
+`,
+			nil,
+			true,
+		},
+		{
+			`
+/graphql:
+  put:
+    graphql:
+      query: '{ users { name } }'
+`,
+			nil,
+			true,
+		},
+		{
+			`
+/graphql:
+  post:
+    graphql:
+      variables:
+        id: 1
+`,
+			nil,
+			true,
+		},
+		{
+			`
+/graphql:
+  post:
+    graphql:
+      query: '{ users { name } }'
+      file: testdata/graphql/user.graphql
+`,
+			nil,
+			true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -121,7 +394,7 @@ func TestParseHTTPRequest(t *testing.T) {
 		if err := yaml.Unmarshal([]byte(tt.in), &v); err != nil {
 			t.Fatal(err)
 		}
-		got, err := parseHTTPRequest(v)
+		got, err := parseHTTPRequest(v, nil)
 		if err != nil {
 			if !tt.wantErr {
 				t.Error(err)
@@ -131,7 +404,7 @@ func TestParseHTTPRequest(t *testing.T) {
 		if tt.wantErr {
 			t.Error("want error")
 		}
-		opts := cmp.AllowUnexported(httpRequest{})
+		opts := cmp.AllowUnexported(httpRequest{}, httpStream{}, httpGraphQL{})
 		if diff := cmp.Diff(got, tt.want, opts); diff != "" {
 			t.Error(diff)
 		}
@@ -294,6 +567,91 @@ my.custom.server.Service/Method:
 		},
 		{
 			`
+my.custom.server.Service/Method:
+  message:
+    key: value
+  receive:
+    until: current.res.message.status == 2
+    count: 10
+    timeout: 5sec
+`,
+			&grpcRequest{
+				service: "my.custom.server.Service",
+				method:  "Method",
+				headers: metadata.MD{},
+				messages: []*grpcMessage{
+					{
+						op: GRPCOpMessage,
+						params: map[string]any{
+							"key": "value",
+						},
+					},
+				},
+				receive: &grpcReceive{
+					until:   "current.res.message.status == 2",
+					count:   10,
+					timeout: 5 * time.Second,
+				},
+			},
+			false,
+		},
+		{
+			`
+my.custom.server.Service/Method:
+  messages:
+    -
+      key: value
+    -
+      receive:
+        count: "{{ vars.count }}"
+    -
+      receive: value
+    -
+      close
+`,
+			&grpcRequest{
+				service: "my.custom.server.Service",
+				method:  "Method",
+				headers: metadata.MD{},
+				messages: []*grpcMessage{
+					{
+						op: GRPCOpMessage,
+						params: map[string]any{
+							"key": "value",
+						},
+					},
+					{
+						op: GRPCOpReceive,
+						receive: &grpcReceive{
+							count: 3,
+						},
+					},
+					{
+						op: GRPCOpMessage,
+						params: map[string]any{
+							"receive": "value",
+						},
+					},
+					{
+						op: GRPCOpClose,
+					},
+				},
+			},
+			false,
+		},
+		{
+			`
+my.custom.server.Service/Method:
+  message:
+    key: value
+  receive:
+    invalid: true
+`,
+			nil,
+			true,
+		},
+		{
+			`
 "{{ vars.path }}":
   headers:
     "{{ vars.one }}": "{{ vars.two }}"
@@ -325,7 +683,7 @@ my.custom.server.Service/Method:
 	if err != nil {
 		t.Fatal(err)
 	}
-	o.store.vars = map[string]any{"path": "my.custom.server.Service/Method", "one": "ichi", "two": "ni"}
+	o.store.vars = map[string]any{"path": "my.custom.server.Service/Method", "one": "ichi", "two": "ni", "count": 3}
 
 	for _, tt := range tests {
 		var v map[string]any
@@ -342,7 +700,136 @@ my.custom.server.Service/Method:
 		if tt.wantErr {
 			t.Error("want error")
 		}
-		opts := cmp.AllowUnexported(grpcRequest{}, grpcMessage{})
+		opts := cmp.AllowUnexported(grpcRequest{}, grpcMessage{}, grpcReceive{})
+		if diff := cmp.Diff(got, tt.want, opts); diff != "" {
+			t.Error(diff)
+		}
+	}
+}
+
+func TestParseWSRequest(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    *wsRequest
+		wantErr bool
+	}{
+		{
+			`
+headers:
+  Authorization: "Bearer {{ vars.token }}"
+timeout: 3sec
+messages:
+  - text: hello
+  - json:
+      type: join
+      room: "{{ vars.room }}"
+  - binary: AQID
+  - receive
+  - receive:
+      timeout: 500ms
+  - close
+`,
+			&wsRequest{
+				headers: http.Header{
+					"Authorization": []string{"Bearer xxxxx"},
+				},
+				timeout: 3 * time.Second,
+				messages: []*wsMessage{
+					{op: WSOpText, data: []byte("hello")},
+					{op: WSOpJSON, data: []byte(`{"room":"lobby","type":"join"}`)},
+					{op: WSOpBinary, data: []byte{1, 2, 3}},
+					{op: WSOpReceive},
+					{op: WSOpReceive, timeout: 500 * time.Millisecond},
+					{op: WSOpClose},
+				},
+			},
+			false,
+		},
+		{
+			`
+messages:
+  - close:
+      code: 1001
+      reason: going away
+`,
+			&wsRequest{
+				headers: http.Header{},
+				messages: []*wsMessage{
+					{op: WSOpClose, code: 1001, reason: "going away"},
+				},
+			},
+			false,
+		},
+		{
+			`
+messages:
+  - close: 4000
+`,
+			&wsRequest{
+				headers: http.Header{},
+				messages: []*wsMessage{
+					{op: WSOpClose, code: 4000},
+				},
+			},
+			false,
+		},
+		{
+			`
+messages:
+  - send
+`,
+			nil,
+			true,
+		},
+		{
+			`
+messages:
+  - binary: "not base64"
+`,
+			nil,
+			true,
+		},
+		{
+			`
+messages:
+  - text: hello
+    receive:
+`,
+			nil,
+			true,
+		},
+		{
+			`
+headers:
+  Authorization: "Bearer xxxxx"
+`,
+			nil,
+			true,
+		},
+	}
+
+	o, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.store.vars = map[string]any{"token": "xxxxx", "room": "lobby"}
+
+	for _, tt := range tests {
+		var v map[string]any
+		if err := yaml.Unmarshal([]byte(tt.in), &v); err != nil {
+			t.Fatal(err)
+		}
+		got, err := parseWSRequest(v, o.expandBeforeRecord)
+		if err != nil {
+			if !tt.wantErr {
+				t.Error(err)
+			}
+			continue
+		}
+		if tt.wantErr {
+			t.Error("want error")
+		}
+		opts := cmp.AllowUnexported(wsRequest{}, wsMessage{})
 		if diff := cmp.Diff(got, tt.want, opts); diff != "" {
 			t.Error(diff)
 		}
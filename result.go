@@ -39,15 +39,18 @@ type StepResult struct {
 type runNResult struct {
 	Total      atomic.Int64
 	RunResults []*RunResult
-	mu         sync.Mutex
+	// API coverage of runners. nil if coverage is disabled.
+	Coverage *coverageResult
+	mu       sync.Mutex
 }
 
 type runNResultSimplified struct {
-	Total   int64                  `json:"total"`
-	Success int64                  `json:"success"`
-	Failure int64                  `json:"failure"`
-	Skipped int64                  `json:"skipped"`
-	Results []*runResultSimplified `json:"results"`
+	Total    int64                  `json:"total"`
+	Success  int64                  `json:"success"`
+	Failure  int64                  `json:"failure"`
+	Skipped  int64                  `json:"skipped"`
+	Results  []*runResultSimplified `json:"results"`
+	Coverage *coverageResult        `json:"coverage,omitempty"`
 }
 
 type runResultSimplified struct {
@@ -81,7 +84,8 @@ func (r *runNResult) HasFailure() bool {
 
 func (r *runNResult) Simplify() runNResultSimplified {
 	s := runNResultSimplified{
-		Total: r.Total.Load(),
+		Total:    r.Total.Load(),
+		Coverage: r.Coverage,
 	}
 	for _, rr := range r.RunResults {
 		switch {
@@ -0,0 +1,235 @@
+package runn
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"gopkg.in/yaml.v2"
+)
+
+// har is the HTTP Archive ( https://w3c.github.io/web-performance/specs/HAR/Overview.html ).
+type har struct {
+	Log struct {
+		Entries []*harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	Request  *harRequest  `json:"request"`
+	Response *harResponse `json:"response"`
+}
+
+type harRequest struct {
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Headers  []*harNameValue `json:"headers"`
+	PostData *harPostData    `json:"postData,omitempty"`
+}
+
+type harPostData struct {
+	MimeType string          `json:"mimeType"`
+	Text     string          `json:"text"`
+	Params   []*harNameValue `json:"params,omitempty"`
+}
+
+type harResponse struct {
+	Status  int `json:"status"`
+	Content struct {
+		MimeType string `json:"mimeType"`
+	} `json:"content"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harConfig struct {
+	hosts        []string
+	methods      []string
+	contentTypes []string
+	statusTest   bool
+}
+
+// HAROption is the option for converting HAR entries into steps.
+type HAROption func(*harConfig) error
+
+// HARHosts sets the hosts ( glob patterns are allowed ) of the entries to convert.
+func HARHosts(hosts ...string) HAROption {
+	return func(c *harConfig) error {
+		for _, h := range hosts {
+			if _, err := path.Match(h, ""); err != nil {
+				return fmt.Errorf("invalid host pattern: %s", h)
+			}
+		}
+		c.hosts = append(c.hosts, hosts...)
+		return nil
+	}
+}
+
+// HARMethods sets the methods of the entries to convert.
+func HARMethods(methods ...string) HAROption {
+	return func(c *harConfig) error {
+		for _, m := range methods {
+			c.methods = append(c.methods, strings.ToUpper(m))
+		}
+		return nil
+	}
+}
+
+// HARContentTypes sets the content types ( e.g. application/json, image/* ) of the responses of the entries to convert.
+func HARContentTypes(contentTypes ...string) HAROption {
+	return func(c *harConfig) error {
+		c.contentTypes = append(c.contentTypes, contentTypes...)
+		return nil
+	}
+}
+
+// HARStatusTest enables `test:` conditions asserting the recorded status codes.
+func HARStatusTest(enable bool) HAROption {
+	return func(c *harConfig) error {
+		c.statusTest = enable
+		return nil
+	}
+}
+
+// Request headers that should not be replayed as is.
+var harIgnoreHeaders = []string{
+	"Host",
+	"Content-Length",
+	"Connection",
+	// The HTTP client decompresses the response only if Accept-Encoding is not set manually
+	"Accept-Encoding",
+}
+
+// AppendStepsFromHAR appends HTTP steps converted from the entries of HAR.
+func (rb *runbook) AppendStepsFromHAR(in io.Reader, opts ...HAROption) error {
+	c := &harConfig{}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return err
+		}
+	}
+	h := &har{}
+	if err := json.NewDecoder(in).Decode(h); err != nil {
+		return fmt.Errorf("invalid HAR: %w", err)
+	}
+	for i, e := range h.Log.Entries {
+		if e.Request == nil {
+			return fmt.Errorf("invalid HAR entry[%d]: no request", i)
+		}
+		u, err := url.Parse(e.Request.URL)
+		if err != nil {
+			return fmt.Errorf("invalid HAR entry[%d]: %w", i, err)
+		}
+		if !c.match(e, u) {
+			continue
+		}
+		dsn := fmt.Sprintf("%s://%s", u.Scheme, u.Host)
+		key := rb.setRunner(dsn)
+		step, err := createHTTPStepFromHAREntry(key, e, u)
+		if err != nil {
+			return fmt.Errorf("invalid HAR entry[%d]: %w", i, err)
+		}
+		if c.statusTest && e.Response != nil && e.Response.Status > 0 {
+			step = append(step, yaml.MapItem{Key: "test", Value: fmt.Sprintf("current.res.status == %d", e.Response.Status)})
+		}
+		if rb.useMap {
+			rb.stepKeys = append(rb.stepKeys, fmt.Sprintf("%s%d", strings.ToLower(e.Request.Method), len(rb.stepKeys)))
+		}
+		rb.Steps = append(rb.Steps, step)
+	}
+	return nil
+}
+
+func (c *harConfig) match(e *harEntry, u *url.URL) bool {
+	if len(c.hosts) > 0 {
+		matched := false
+		for _, h := range c.hosts {
+			if ok, _ := path.Match(h, u.Host); ok {
+				matched = true
+				break
+			}
+			if ok, _ := path.Match(h, u.Hostname()); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(c.methods) > 0 && !contains(c.methods, strings.ToUpper(e.Request.Method)) {
+		return false
+	}
+	if len(c.contentTypes) > 0 {
+		if e.Response == nil {
+			return false
+		}
+		mt, _, err := mime.ParseMediaType(e.Response.Content.MimeType)
+		if err != nil {
+			return false
+		}
+		matched := false
+		for _, ct := range c.contentTypes {
+			if ok, _ := path.Match(ct, mt); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func createHTTPStepFromHAREntry(key string, e *harEntry, u *url.URL) (yaml.MapSlice, error) {
+	var body io.Reader = http.NoBody
+	contentType := ""
+	if pd := e.Request.PostData; pd != nil {
+		contentType = pd.MimeType
+		switch {
+		case pd.Text != "":
+			body = strings.NewReader(pd.Text)
+		case len(pd.Params) > 0:
+			vs := url.Values{}
+			for _, p := range pd.Params {
+				vs.Add(p.Name, p.Value)
+			}
+			body = strings.NewReader(vs.Encode())
+		}
+	}
+	p := &url.URL{Path: u.Path, RawPath: u.RawPath, RawQuery: u.RawQuery}
+	req, err := http.NewRequest(strings.ToUpper(e.Request.Method), p.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for _, hv := range e.Request.Headers {
+		// HTTP/2 pseudo-headers such as :authority
+		if strings.HasPrefix(hv.Name, ":") {
+			continue
+		}
+		ignore := false
+		for _, ih := range harIgnoreHeaders {
+			if strings.EqualFold(hv.Name, ih) {
+				ignore = true
+				break
+			}
+		}
+		if ignore {
+			continue
+		}
+		req.Header.Add(hv.Name, hv.Value)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return CreateHTTPStepMapSlice(key, req)
+}
@@ -0,0 +1,75 @@
+package runn
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/tenntenn/golden"
+	"gopkg.in/yaml.v2"
+)
+
+func TestAppendStepsFromHAR(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []HAROption
+	}{
+		{"all", nil},
+		{"hosts", []HAROption{HARHosts("*.example.com", "localhost")}},
+		{"methods", []HAROption{HARMethods("post")}},
+		{"content_types", []HAROption{HARContentTypes("application/json", "image/*")}},
+		{"status_test", []HAROption{HARHosts("example.com"), HARStatusTest(true)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, err := os.Open("testdata/har/session.har")
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				_ = in.Close()
+			})
+			rb := NewRunbook(tt.name)
+			if err := rb.AppendStepsFromHAR(in, tt.opts...); err != nil {
+				t.Fatal(err)
+			}
+
+			got := new(bytes.Buffer)
+			enc := yaml.NewEncoder(got)
+			if err := enc.Encode(rb); err != nil {
+				t.Error(err)
+			}
+
+			f := fmt.Sprintf("har_%s.append_step", tt.name)
+			if os.Getenv("UPDATE_GOLDEN") != "" {
+				golden.Update(t, "testdata", f, got)
+				return
+			}
+			if diff := golden.Diff(t, "testdata", f, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestAppendStepsFromHARError(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		opts []HAROption
+	}{
+		{"invalid json", `{"log":`, nil},
+		{"no request", `{"log":{"entries":[{}]}}`, nil},
+		{"invalid host pattern", `{"log":{"entries":[]}}`, []HAROption{HARHosts("[")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rb := NewRunbook(tt.name)
+			if err := rb.AppendStepsFromHAR(strings.NewReader(tt.in), tt.opts...); err == nil {
+				t.Error("want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,440 @@
+package runn
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/goccy/go-json"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// OpenApi3GroupByOperation generates a runbook per operation.
+	OpenApi3GroupByOperation = "operation"
+	// OpenApi3GroupByTag generates a runbook per tag ( the first tag of each operation ).
+	OpenApi3GroupByTag = "tag"
+)
+
+const (
+	openApi3DummyDSN      = "https://dummy.example.com"
+	openApi3DefaultTag    = "default"
+	openApi3ExampleString = "example"
+	// Max depth of nested schemas to generate an example value
+	openApi3MaxExampleDepth = 8
+)
+
+// methods in the order of steps.
+var openApi3Methods = []string{
+	http.MethodGet,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodHead,
+	http.MethodOptions,
+	http.MethodTrace,
+}
+
+var unsafeNameRe = regexp.MustCompile(`[^a-zA-Z0-9_\-]+`)
+
+// NewRunbooksFromOpenApi3 generates runbooks from the OpenAPI 3 document.
+// It returns runbooks keyed on the name ( operationId, method and path, or tag ).
+// Names are sanitized to be used as file names, and names that collide after sanitizing get a suffix ( e.g. `get_users_2` ).
+// Names that are empty after sanitizing fall back to the method and path ( or `default` for tags ).
+func NewRunbooksFromOpenApi3(l, groupBy string) (map[string]*runbook, error) {
+	doc, err := loadOpenApi3Doc(l)
+	if err != nil {
+		return nil, err
+	}
+	switch groupBy {
+	case "":
+		groupBy = OpenApi3GroupByOperation
+	case OpenApi3GroupByOperation, OpenApi3GroupByTag:
+	default:
+		return nil, fmt.Errorf("invalid group by: %s", groupBy)
+	}
+	dsn := openApi3DSN(doc)
+	rbs := map[string]*runbook{}
+	names := newOpenApi3Names()
+	var paths []string
+	for p := range doc.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		item := doc.Paths[p]
+		for _, m := range openApi3Methods {
+			op := item.GetOperation(m)
+			if op == nil {
+				continue
+			}
+			var name, fallback, desc string
+			switch groupBy {
+			case OpenApi3GroupByTag:
+				name = openApi3DefaultTag
+				fallback = openApi3DefaultTag
+				if len(op.Tags) > 0 {
+					name = op.Tags[0]
+				}
+				desc = name
+				if t := doc.Tags.Get(name); t != nil && t.Description != "" {
+					desc = t.Description
+				}
+			default:
+				fallback = fmt.Sprintf("%s%s", strings.ToLower(m), p)
+				name = op.OperationID
+				if name == "" {
+					name = fallback
+				}
+				desc = op.Summary
+				if desc == "" {
+					desc = fmt.Sprintf("%s %s", m, p)
+				}
+			}
+			name = names.get(name, fallback)
+			rb, ok := rbs[name]
+			if !ok {
+				rb = NewRunbook(desc)
+				rbs[name] = rb
+			}
+			key := rb.setRunner(dsn)
+			step, err := createHTTPStepFromOpenApi3(key, m, p, item, op)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create step of %s %s: %w", m, p, err)
+			}
+			rb.Steps = append(rb.Steps, step)
+		}
+	}
+	return rbs, nil
+}
+
+// openApi3Names maps names of runbooks to sanitized names that are unique.
+type openApi3Names struct {
+	sanitized map[string]string
+	used      map[string]struct{}
+}
+
+func newOpenApi3Names() *openApi3Names {
+	return &openApi3Names{
+		sanitized: map[string]string{},
+		used:      map[string]struct{}{},
+	}
+}
+
+// get returns the sanitized name of the name.
+// The same name always returns the same sanitized name, and different names never return the same sanitized name.
+// If the name is sanitized to empty ( e.g. consists only of non-ASCII characters ), the sanitized fallback is used instead.
+func (n *openApi3Names) get(name, fallback string) string {
+	if s, ok := n.sanitized[name]; ok {
+		return s
+	}
+	base := sanitizeOpenApi3Name(name)
+	if base == "" {
+		base = sanitizeOpenApi3Name(fallback)
+	}
+	if base == "" {
+		base = openApi3DefaultTag
+	}
+	s := base
+	for i := 2; ; i++ {
+		if _, ok := n.used[s]; !ok {
+			break
+		}
+		s = fmt.Sprintf("%s_%d", base, i)
+	}
+	n.sanitized[name] = s
+	n.used[s] = struct{}{}
+	return s
+}
+
+func sanitizeOpenApi3Name(name string) string {
+	return strings.Trim(unsafeNameRe.ReplaceAllString(name, "_"), "_")
+}
+
+// openApi3DSN returns the DSN of the HTTP runner using the first server of the document.
+func openApi3DSN(doc *openapi3.T) string {
+	if len(doc.Servers) == 0 {
+		return openApi3DummyDSN
+	}
+	s := doc.Servers[0]
+	u := s.URL
+	for k, v := range s.Variables {
+		u = strings.ReplaceAll(u, fmt.Sprintf("{%s}", k), v.Default)
+	}
+	if !strings.HasPrefix(u, "https://") && !strings.HasPrefix(u, "http://") {
+		// Relative server URL
+		u = openApi3DummyDSN + "/" + strings.TrimPrefix(u, "/")
+	}
+	return strings.TrimSuffix(u, "/")
+}
+
+func createHTTPStepFromOpenApi3(key, method, p string, item *openapi3.PathItem, op *openapi3.Operation) (yaml.MapSlice, error) {
+	params := openapi3.Parameters{}
+	params = append(params, item.Parameters...)
+	params = append(params, op.Parameters...)
+	query := url.Values{}
+	h := http.Header{}
+	for _, pr := range params {
+		if pr.Value == nil {
+			continue
+		}
+		prm := pr.Value
+		switch prm.In {
+		case openapi3.ParameterInPath:
+			v := fmt.Sprintf("%v", parameterExample(prm))
+			p = strings.ReplaceAll(p, fmt.Sprintf("{%s}", prm.Name), url.PathEscape(v))
+		case openapi3.ParameterInQuery:
+			if !prm.Required {
+				continue
+			}
+			query.Add(prm.Name, fmt.Sprintf("%v", parameterExample(prm)))
+		case openapi3.ParameterInHeader:
+			if !prm.Required {
+				continue
+			}
+			h.Add(prm.Name, fmt.Sprintf("%v", parameterExample(prm)))
+		}
+	}
+	if len(query) > 0 {
+		p = fmt.Sprintf("%s?%s", p, query.Encode())
+	}
+
+	var (
+		contentType string
+		body        []byte
+	)
+	if op.RequestBody != nil && op.RequestBody.Value != nil {
+		var (
+			mt  *openapi3.MediaType
+			err error
+		)
+		contentType, mt = selectMediaType(op.RequestBody.Value.Content)
+		if mt != nil {
+			body, err = encodeExample(contentType, mediaTypeExample(mt))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	req, err := http.NewRequest(method, p, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) == 0 {
+		req.Body = http.NoBody
+	}
+	req.Header = h
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	step, err := CreateHTTPStepMapSlice(key, req)
+	if err != nil {
+		return nil, err
+	}
+	var s yaml.MapSlice
+	if op.Summary != "" {
+		s = append(s, yaml.MapItem{Key: "desc", Value: op.Summary})
+	}
+	s = append(s, step...)
+	if cond := statusCond(op.Responses); cond != "" {
+		s = append(s, yaml.MapItem{Key: "test", Value: cond})
+	}
+	return s, nil
+}
+
+// selectMediaType selects application/json or the first media type in alphabetical order.
+func selectMediaType(content openapi3.Content) (string, *openapi3.MediaType) {
+	if mt, ok := content[MediaTypeApplicationJSON]; ok {
+		return MediaTypeApplicationJSON, mt
+	}
+	var keys []string
+	for k := range content {
+		keys = append(keys, k)
+	}
+	if len(keys) == 0 {
+		return "", nil
+	}
+	sort.Strings(keys)
+	return keys[0], content[keys[0]]
+}
+
+func encodeExample(contentType string, v any) ([]byte, error) {
+	switch {
+	case v == nil:
+		return nil, nil
+	case strings.Contains(contentType, "json"):
+		return json.Marshal(v)
+	case contentType == MediaTypeApplicationFormUrlencoded:
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("invalid example of %s: %v", contentType, v)
+		}
+		vs := url.Values{}
+		for k, vv := range m {
+			vs.Set(k, fmt.Sprintf("%v", vv))
+		}
+		return []byte(vs.Encode()), nil
+	default:
+		// Body of other media types ( multipart/form-data, files, etc. ) is left to the user
+		return nil, nil
+	}
+}
+
+func parameterExample(p *openapi3.Parameter) any {
+	if p.Example != nil {
+		return p.Example
+	}
+	if v, ok := firstExample(p.Examples); ok {
+		return v
+	}
+	if p.Schema != nil && p.Schema.Value != nil {
+		return schemaExample(p.Schema.Value, 0)
+	}
+	return openApi3ExampleString
+}
+
+func mediaTypeExample(mt *openapi3.MediaType) any {
+	if mt.Example != nil {
+		return mt.Example
+	}
+	if v, ok := firstExample(mt.Examples); ok {
+		return v
+	}
+	if mt.Schema != nil && mt.Schema.Value != nil {
+		return schemaExample(mt.Schema.Value, 0)
+	}
+	return nil
+}
+
+// firstExample returns the value of the first example in alphabetical order of the names.
+func firstExample(examples openapi3.Examples) (any, bool) {
+	var keys []string
+	for k, e := range examples {
+		if e == nil || e.Value == nil || e.Value.Value == nil {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) == 0 {
+		return nil, false
+	}
+	sort.Strings(keys)
+	return examples[keys[0]].Value.Value, true
+}
+
+// schemaExample generates the example value from the schema.
+func schemaExample(s *openapi3.Schema, depth int) any {
+	switch {
+	case s.Example != nil:
+		return s.Example
+	case s.Default != nil:
+		return s.Default
+	case len(s.Enum) > 0:
+		return s.Enum[0]
+	}
+	if depth > openApi3MaxExampleDepth {
+		return nil
+	}
+	switch {
+	case len(s.AllOf) > 0:
+		m := map[string]any{}
+		for _, sr := range s.AllOf {
+			if sr.Value == nil {
+				continue
+			}
+			if mm, ok := schemaExample(sr.Value, depth+1).(map[string]any); ok {
+				for k, v := range mm {
+					m[k] = v
+				}
+			}
+		}
+		for k, v := range objectExample(s, depth) {
+			m[k] = v
+		}
+		return m
+	case len(s.OneOf) > 0 && s.OneOf[0].Value != nil:
+		return schemaExample(s.OneOf[0].Value, depth+1)
+	case len(s.AnyOf) > 0 && s.AnyOf[0].Value != nil:
+		return schemaExample(s.AnyOf[0].Value, depth+1)
+	}
+	switch s.Type {
+	case openapi3.TypeObject:
+		return objectExample(s, depth)
+	case openapi3.TypeArray:
+		if s.Items == nil || s.Items.Value == nil {
+			return []any{}
+		}
+		return []any{schemaExample(s.Items.Value, depth+1)}
+	case openapi3.TypeString:
+		return openApi3ExampleString
+	case openapi3.TypeInteger:
+		if s.Min != nil {
+			return int(*s.Min)
+		}
+		return 1
+	case openapi3.TypeNumber:
+		if s.Min != nil {
+			return *s.Min
+		}
+		return 1.0
+	case openapi3.TypeBoolean:
+		return true
+	default:
+		if len(s.Properties) > 0 {
+			return objectExample(s, depth)
+		}
+		return nil
+	}
+}
+
+func objectExample(s *openapi3.Schema, depth int) map[string]any {
+	m := map[string]any{}
+	for k, sr := range s.Properties {
+		if sr.Value == nil || sr.Value.ReadOnly {
+			continue
+		}
+		m[k] = schemaExample(sr.Value, depth+1)
+	}
+	return m
+}
+
+// statusCond returns the condition of `test:` asserting the documented success status codes.
+// If no success status code is documented, all documented status codes are used.
+func statusCond(responses openapi3.Responses) string {
+	var success, all []string
+	for code := range responses {
+		var cond string
+		switch {
+		case code == "default":
+			continue
+		case len(code) == 3 && strings.HasSuffix(strings.ToUpper(code), "XX"):
+			n, err := strconv.Atoi(code[:1])
+			if err != nil {
+				continue
+			}
+			cond = fmt.Sprintf("(current.res.status >= %d && current.res.status < %d)", n*100, (n+1)*100)
+		default:
+			if _, err := strconv.Atoi(code); err != nil {
+				continue
+			}
+			cond = fmt.Sprintf("current.res.status == %s", code)
+		}
+		all = append(all, cond)
+		if strings.HasPrefix(code, "2") {
+			success = append(success, cond)
+		}
+	}
+	conds := success
+	if len(conds) == 0 {
+		conds = all
+	}
+	sort.Strings(conds)
+	return strings.Join(conds, " || ")
+}
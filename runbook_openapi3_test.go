@@ -0,0 +1,188 @@
+package runn
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tenntenn/golden"
+	"gopkg.in/yaml.v2"
+)
+
+func TestNewRunbooksFromOpenApi3(t *testing.T) {
+	tests := []struct {
+		in      string
+		groupBy string
+		want    []string
+	}{
+		{"testdata/openapi3.yml", OpenApi3GroupByOperation, []string{"get_notfound", "get_ping", "get_private", "get_redirect", "get_users", "get_users_id", "post_help", "post_upload", "post_users"}},
+		{"testdata/openapi3_tags.yml", OpenApi3GroupByOperation, []string{"createPet", "get_health", "listPets", "showPetById", "updatePet"}},
+		{"testdata/openapi3_tags.yml", OpenApi3GroupByTag, []string{"default", "pets"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s_%s", tt.in, tt.groupBy), func(t *testing.T) {
+			rbs, err := NewRunbooksFromOpenApi3(tt.in, tt.groupBy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for name := range rbs {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			if fmt.Sprint(names) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v\nwant %v", names, tt.want)
+			}
+			buf := new(bytes.Buffer)
+			for _, name := range names {
+				b, err := yaml.Marshal(rbs[name])
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, _ = fmt.Fprintf(buf, "# %s\n%s", name, string(b))
+				// Generated runbook should be parsed as a runbook
+				if _, err := parseRunbook(b); err != nil {
+					t.Error(err)
+				}
+			}
+			got := buf.String()
+			f := fmt.Sprintf("%s.%s.runbooks", tt.in[len("testdata/"):], tt.groupBy)
+			if os.Getenv("UPDATE_GOLDEN") != "" {
+				golden.Update(t, "testdata", f, got)
+				return
+			}
+			if diff := golden.Diff(t, "testdata", f, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestNewRunbooksFromOpenApi3NameCollision(t *testing.T) {
+	doc := `openapi: 3.0.3
+info:
+  title: collision
+  version: 0.0.1
+paths:
+  /users:
+    get:
+      operationId: get.users
+      tags: [a.b]
+      responses:
+        "200":
+          description: OK
+    post:
+      operationId: get users
+      tags: [a b]
+      responses:
+        "201":
+          description: Created
+    put:
+      operationId: get_users
+      tags: [a.b]
+      responses:
+        "200":
+          description: OK
+  /users/{id}:
+    get:
+      tags: [a_b]
+      parameters:
+        - name: id
+          in: path
+          required: true
+          schema:
+            type: integer
+      responses:
+        "200":
+          description: OK
+`
+	p := filepath.Join(t.TempDir(), "openapi3.yml")
+	if err := os.WriteFile(p, []byte(doc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		groupBy string
+		want    map[string]int
+	}{
+		{OpenApi3GroupByOperation, map[string]int{"get_users": 1, "get_users_2": 1, "get_users_3": 1, "get_users_id": 1}},
+		{OpenApi3GroupByTag, map[string]int{"a_b": 2, "a_b_2": 1, "a_b_3": 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.groupBy, func(t *testing.T) {
+			rbs, err := NewRunbooksFromOpenApi3(p, tt.groupBy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]int{}
+			for name, rb := range rbs {
+				got[name] = len(rb.Steps)
+			}
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestNewRunbooksFromOpenApi3InvalidGroupBy(t *testing.T) {
+	if _, err := NewRunbooksFromOpenApi3("testdata/openapi3.yml", "path"); err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestNewRunbooksFromOpenApi3EmptyName(t *testing.T) {
+	doc := `openapi: 3.0.3
+info:
+  title: empty
+  version: 0.0.1
+paths:
+  /users:
+    get:
+      operationId: ユーザー一覧
+      tags: [ユーザー]
+      responses:
+        "200":
+          description: OK
+    post:
+      operationId: "..."
+      tags: [ユーザー]
+      responses:
+        "201":
+          description: Created
+    put:
+      operationId: get_users
+      responses:
+        "200":
+          description: OK
+`
+	p := filepath.Join(t.TempDir(), "openapi3.yml")
+	if err := os.WriteFile(p, []byte(doc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		groupBy string
+		want    map[string]int
+	}{
+		{OpenApi3GroupByOperation, map[string]int{"get_users": 1, "post_users": 1, "get_users_2": 1}},
+		{OpenApi3GroupByTag, map[string]int{"default": 2, "default_2": 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.groupBy, func(t *testing.T) {
+			rbs, err := NewRunbooksFromOpenApi3(p, tt.groupBy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]int{}
+			for name, rb := range rbs {
+				got[name] = len(rb.Steps)
+			}
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
@@ -1,9 +1,15 @@
 package runn
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"strings"
 
+	"github.com/dustin/go-humanize"
 	"github.com/getkin/kin-openapi/openapi3"
 )
 
@@ -13,6 +19,7 @@ type httpRunnerConfig struct {
 	SkipValidateRequest  bool   `yaml:"skipValidateRequest,omitempty"`
 	SkipValidateResponse bool   `yaml:"skipValidateResponse,omitempty"`
 	NotFollowRedirect    bool   `yaml:"notFollowRedirect,omitempty"`
+	MaxRedirects         int    `yaml:"maxRedirects,omitempty"`
 	MultipartBoundary    string `yaml:"multipartBoundary,omitempty"`
 	CACert               string `yaml:"cacert,omitempty"`
 	Cert                 string `yaml:"cert,omitempty"`
@@ -20,8 +27,24 @@ type httpRunnerConfig struct {
 	SkipVerify           bool   `yaml:"skipVerify,omitempty"`
 	Timeout              string `yaml:"timeout,omitempty"`
 	UseCookie            *bool  `yaml:"useCookie,omitempty"`
+	MaxBodySize          string `yaml:"maxBodySize,omitempty"`
+	JSONSchema           string `yaml:"jsonSchema,omitempty"`
+
+	Auth *httpAuthConfig `yaml:"auth,omitempty"`
+	Sign *httpSignConfig `yaml:"sign,omitempty"`
+
+	Resolve       []string `yaml:"resolve,omitempty"`
+	ServerName    string   `yaml:"serverName,omitempty"`
+	TLSMinVersion string   `yaml:"tlsMinVersion,omitempty"`
+	TLSMaxVersion string   `yaml:"tlsMaxVersion,omitempty"`
+	ALPN          []string `yaml:"alpn,omitempty"`
 
 	openApi3Doc *openapi3.T
+	decoders    map[string]httpResponseDecodeFunc
+	encoders    map[string]httpRequestEncodeFunc
+	signer      func(req *http.Request) error
+	middlewares []HTTPMiddleware
+	root        string
 }
 
 type grpcRunnerConfig struct {
@@ -33,6 +56,15 @@ type grpcRunnerConfig struct {
 	SkipVerify  bool     `yaml:"skipVerify,omitempty"`
 	ImportPaths []string `yaml:"importPaths,omitempty"`
 	Protos      []string `yaml:"protos,omitempty"`
+	Protosets   []string `yaml:"protosets,omitempty"`
+	Protocol    string   `yaml:"protocol,omitempty"`
+	JSONSchema  string   `yaml:"jsonSchema,omitempty"`
+
+	Resolve       []string `yaml:"resolve,omitempty"`
+	ServerName    string   `yaml:"serverName,omitempty"`
+	TLSMinVersion string   `yaml:"tlsMinVersion,omitempty"`
+	TLSMaxVersion string   `yaml:"tlsMaxVersion,omitempty"`
+	ALPN          []string `yaml:"alpn,omitempty"`
 
 	cacert []byte
 	cert   []byte
@@ -52,6 +84,16 @@ type sshRunnerConfig struct {
 	KeyboardInteractive []*sshAnswer `yaml:"keyboardInteractive,omitempty"`
 }
 
+type wsRunnerConfig struct {
+	Endpoint     string            `yaml:"endpoint"`
+	Headers      map[string]string `yaml:"headers,omitempty"`
+	Subprotocols []string          `yaml:"subprotocols,omitempty"`
+	CACert       string            `yaml:"cacert,omitempty"`
+	Cert         string            `yaml:"cert,omitempty"`
+	Key          string            `yaml:"key,omitempty"`
+	SkipVerify   bool              `yaml:"skipVerify,omitempty"`
+}
+
 type sshAnswer struct {
 	Match  string `yaml:"match"`
 	Answer string `yaml:"answer"`
@@ -63,6 +105,8 @@ type grpcRunnerOption func(*grpcRunnerConfig) error
 
 type sshRunnerOption func(*sshRunnerConfig) error
 
+type wsRunnerOption func(*wsRunnerConfig) error
+
 func (c *sshRunnerConfig) validate() error {
 	if c.Host == "" && c.Hostname == "" {
 		return fmt.Errorf("host or hostname is required")
@@ -121,6 +165,25 @@ func NotFollowRedirect(nf bool) httpRunnerOption {
 	}
 }
 
+// MaxRedirects sets the maximum number of redirects to follow ( default: 10 ).
+func MaxRedirects(max int) httpRunnerOption {
+	return func(c *httpRunnerConfig) error {
+		if max < 0 {
+			return fmt.Errorf("invalid max redirects: %d", max)
+		}
+		c.MaxRedirects = max
+		return nil
+	}
+}
+
+// HTTPEndpoint sets the endpoint ( scheme, host and base path ) of HTTP runner with http.Handler.
+func HTTPEndpoint(endpoint string) httpRunnerOption {
+	return func(c *httpRunnerConfig) error {
+		c.Endpoint = endpoint
+		return nil
+	}
+}
+
 func MultipartBoundary(b string) httpRunnerOption {
 	return func(c *httpRunnerConfig) error {
 		c.MultipartBoundary = b
@@ -163,6 +226,57 @@ func HTTPTimeout(timeout string) httpRunnerOption {
 	}
 }
 
+// HTTPResolve overrides the address to connect to for the host and port ( host:port:addr ) in the same way as curl --resolve.
+func HTTPResolve(entries ...string) httpRunnerOption {
+	return func(c *httpRunnerConfig) error {
+		for _, e := range entries {
+			if _, _, err := parseResolve(e); err != nil {
+				return err
+			}
+		}
+		c.Resolve = append(c.Resolve, entries...)
+		return nil
+	}
+}
+
+// HTTPServerName sets the server name for SNI and certificate verification.
+func HTTPServerName(name string) httpRunnerOption {
+	return func(c *httpRunnerConfig) error {
+		c.ServerName = name
+		return nil
+	}
+}
+
+// HTTPTLSMinVersion sets the minimum TLS version ( 1.0, 1.1, 1.2 or 1.3 ).
+func HTTPTLSMinVersion(v string) httpRunnerOption {
+	return func(c *httpRunnerConfig) error {
+		if _, err := parseTLSVersion(v); err != nil {
+			return err
+		}
+		c.TLSMinVersion = v
+		return nil
+	}
+}
+
+// HTTPTLSMaxVersion sets the maximum TLS version ( 1.0, 1.1, 1.2 or 1.3 ).
+func HTTPTLSMaxVersion(v string) httpRunnerOption {
+	return func(c *httpRunnerConfig) error {
+		if _, err := parseTLSVersion(v); err != nil {
+			return err
+		}
+		c.TLSMaxVersion = v
+		return nil
+	}
+}
+
+// HTTPALPN sets the protocols advertised by ALPN.
+func HTTPALPN(protos ...string) httpRunnerOption {
+	return func(c *httpRunnerConfig) error {
+		c.ALPN = protos
+		return nil
+	}
+}
+
 func UseCookie(use bool) httpRunnerOption {
 	return func(c *httpRunnerConfig) error {
 		c.UseCookie = &use
@@ -170,6 +284,192 @@ func UseCookie(use bool) httpRunnerOption {
 	}
 }
 
+// MaxBodySize sets the max size of the response body read into memory ( e.g. "10MB" ).
+func MaxBodySize(size string) httpRunnerOption {
+	return func(c *httpRunnerConfig) error {
+		if _, err := humanize.ParseBytes(size); err != nil {
+			return fmt.Errorf("invalid max body size: %w", err)
+		}
+		c.MaxBodySize = size
+		return nil
+	}
+}
+
+// HTTPJSONSchema sets the JSON Schema to validate HTTP response bodies ( file path, `json://`, `yaml://` or URL ).
+func HTTPJSONSchema(l string) httpRunnerOption {
+	return func(c *httpRunnerConfig) error {
+		c.JSONSchema = l
+		return nil
+	}
+}
+
+// HTTPMiddlewares appends middlewares wrapping the http.RoundTripper of HTTP runner.
+// The first middleware is the outermost, and the TLS settings of the runner are applied to the transport wrapped by middlewares.
+func HTTPMiddlewares(middlewares ...HTTPMiddleware) httpRunnerOption {
+	return func(c *httpRunnerConfig) error {
+		for _, mw := range middlewares {
+			if mw == nil {
+				return errors.New("invalid http middleware: nil")
+			}
+		}
+		c.middlewares = append(c.middlewares, middlewares...)
+		return nil
+	}
+}
+
+// HTTPBasicAuth sets the credentials of Basic authentication.
+func HTTPBasicAuth(username, password string) httpRunnerOption {
+	return func(c *httpRunnerConfig) error {
+		c.Auth = &httpAuthConfig{
+			Type:     httpAuthTypeBasic,
+			Username: username,
+			Password: password,
+		}
+		return nil
+	}
+}
+
+// HTTPBearerAuth sets the token of Bearer authentication.
+func HTTPBearerAuth(token string) httpRunnerOption {
+	return func(c *httpRunnerConfig) error {
+		c.Auth = &httpAuthConfig{
+			Type:  httpAuthTypeBearer,
+			Token: token,
+		}
+		return nil
+	}
+}
+
+// HTTPDigestAuth sets the credentials of Digest access authentication.
+func HTTPDigestAuth(username, password string) httpRunnerOption {
+	return func(c *httpRunnerConfig) error {
+		c.Auth = &httpAuthConfig{
+			Type:     httpAuthTypeDigest,
+			Username: username,
+			Password: password,
+		}
+		return nil
+	}
+}
+
+// HTTPOAuth2ClientCredentials sets the credentials of OAuth 2.0 client credentials grant.
+// The access token is cached and fetched again when it expires or the server responds 401.
+func HTTPOAuth2ClientCredentials(tokenURL, clientID, clientSecret string, scopes ...string) httpRunnerOption {
+	return func(c *httpRunnerConfig) error {
+		c.Auth = &httpAuthConfig{
+			Type:         httpAuthTypeOAuth2,
+			GrantType:    oauth2GrantTypeClientCredentials,
+			TokenURL:     tokenURL,
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			Scopes:       scopes,
+		}
+		return nil
+	}
+}
+
+// HTTPOAuth2Password sets the credentials of OAuth 2.0 resource owner password credentials grant.
+// The access token is cached and fetched again when it expires or the server responds 401.
+func HTTPOAuth2Password(tokenURL, clientID, clientSecret, username, password string, scopes ...string) httpRunnerOption {
+	return func(c *httpRunnerConfig) error {
+		c.Auth = &httpAuthConfig{
+			Type:         httpAuthTypeOAuth2,
+			GrantType:    oauth2GrantTypePassword,
+			TokenURL:     tokenURL,
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			Username:     username,
+			Password:     password,
+			Scopes:       scopes,
+		}
+		return nil
+	}
+}
+
+// HTTPSigV4 signs HTTP requests with AWS Signature Version 4.
+// If accessKeyID and secretAccessKey are empty, the credentials are taken from the environment variables.
+func HTTPSigV4(region, service, accessKeyID, secretAccessKey, sessionToken string) httpRunnerOption {
+	return func(c *httpRunnerConfig) error {
+		c.Sign = &httpSignConfig{
+			Type:            httpSignTypeSigV4,
+			Region:          region,
+			Service:         service,
+			AccessKeyID:     accessKeyID,
+			SecretAccessKey: secretAccessKey,
+			SessionToken:    sessionToken,
+		}
+		return nil
+	}
+}
+
+// HTTPHMACSignature signs HTTP requests with HMAC of the canonical string built from the template.
+// The signature is set to the header.
+func HTTPHMACSignature(algorithm, key, header, template string) httpRunnerOption {
+	return func(c *httpRunnerConfig) error {
+		c.Sign = &httpSignConfig{
+			Type:      httpSignTypeHMAC,
+			Algorithm: algorithm,
+			Key:       key,
+			Header:    header,
+			Template:  template,
+		}
+		return nil
+	}
+}
+
+// HTTPRequestSigner sets the function to sign HTTP requests.
+// It is called after headers, cookies and credentials are applied to the request.
+func HTTPRequestSigner(fn func(req *http.Request) error) httpRunnerOption {
+	return func(c *httpRunnerConfig) error {
+		if fn == nil {
+			return errors.New("request signer is nil")
+		}
+		c.signer = fn
+		return nil
+	}
+}
+
+// HTTPResponseDecoder registers the decoder of HTTP response body for the media type.
+// The decoded value is recorded as `res.body`.
+func HTTPResponseDecoder(mediaType string, dec func(b []byte) (any, error)) httpRunnerOption {
+	return func(c *httpRunnerConfig) error {
+		if mediaType == "" {
+			return errors.New("media type of response decoder is required")
+		}
+		if dec == nil {
+			return fmt.Errorf("response decoder for %s is nil", mediaType)
+		}
+		if c.decoders == nil {
+			c.decoders = map[string]httpResponseDecodeFunc{}
+		}
+		c.decoders[strings.ToLower(mediaType)] = dec
+		return nil
+	}
+}
+
+// HTTPRequestEncoder registers the encoder of HTTP request body for the media type.
+func HTTPRequestEncoder(mediaType string, enc func(body any) ([]byte, error)) httpRunnerOption {
+	return func(c *httpRunnerConfig) error {
+		if mediaType == "" {
+			return errors.New("media type of request encoder is required")
+		}
+		if enc == nil {
+			return fmt.Errorf("request encoder for %s is nil", mediaType)
+		}
+		if c.encoders == nil {
+			c.encoders = map[string]httpRequestEncodeFunc{}
+		}
+		c.encoders[strings.ToLower(mediaType)] = func(r *httpRequest) (io.Reader, error) {
+			b, err := enc(r.body)
+			if err != nil {
+				return nil, err
+			}
+			return bytes.NewReader(b), nil
+		}
+		return nil
+	}
+}
+
 func TLS(useTLS bool) grpcRunnerOption {
 	return func(c *grpcRunnerConfig) error {
 		c.TLS = &useTLS
@@ -198,6 +498,14 @@ func Key(path string) grpcRunnerOption {
 	}
 }
 
+// GRPCSkipVerify skips verification of the server certificate.
+func GRPCSkipVerify(skip bool) grpcRunnerOption {
+	return func(c *grpcRunnerConfig) error {
+		c.SkipVerify = skip
+		return nil
+	}
+}
+
 func CACertFromData(b []byte) grpcRunnerOption {
 	return func(c *grpcRunnerConfig) error {
 		c.cacert = b
@@ -219,6 +527,14 @@ func KeyFromData(b []byte) grpcRunnerOption {
 	}
 }
 
+// GRPCJSONSchema sets the JSON Schema to validate gRPC response messages converted to JSON ( file path, `json://`, `yaml://` or URL ).
+func GRPCJSONSchema(l string) grpcRunnerOption {
+	return func(c *grpcRunnerConfig) error {
+		c.JSONSchema = l
+		return nil
+	}
+}
+
 // Protos append protos.
 func Protos(protos []string) grpcRunnerOption {
 	return func(c *grpcRunnerConfig) error {
@@ -235,6 +551,76 @@ func ImportPaths(paths []string) grpcRunnerOption {
 	}
 }
 
+// Protosets append protosets ( FileDescriptorSet files or buf images ).
+func Protosets(protosets []string) grpcRunnerOption {
+	return func(c *grpcRunnerConfig) error {
+		c.Protosets = unique(append(c.Protosets, protosets...))
+		return nil
+	}
+}
+
+// GRPCProtocol sets the protocol to call methods ( grpc, grpcweb or connect ).
+func GRPCProtocol(protocol string) grpcRunnerOption {
+	return func(c *grpcRunnerConfig) error {
+		if err := validateGRPCProtocol(protocol); err != nil {
+			return err
+		}
+		c.Protocol = protocol
+		return nil
+	}
+}
+
+// GRPCResolve overrides the address to connect to for the host and port ( host:port:addr ) in the same way as curl --resolve.
+func GRPCResolve(entries ...string) grpcRunnerOption {
+	return func(c *grpcRunnerConfig) error {
+		for _, e := range entries {
+			if _, _, err := parseResolve(e); err != nil {
+				return err
+			}
+		}
+		c.Resolve = append(c.Resolve, entries...)
+		return nil
+	}
+}
+
+// GRPCServerName sets the server name for SNI and certificate verification.
+func GRPCServerName(name string) grpcRunnerOption {
+	return func(c *grpcRunnerConfig) error {
+		c.ServerName = name
+		return nil
+	}
+}
+
+// GRPCTLSMinVersion sets the minimum TLS version ( 1.0, 1.1, 1.2 or 1.3 ).
+func GRPCTLSMinVersion(v string) grpcRunnerOption {
+	return func(c *grpcRunnerConfig) error {
+		if _, err := parseTLSVersion(v); err != nil {
+			return err
+		}
+		c.TLSMinVersion = v
+		return nil
+	}
+}
+
+// GRPCTLSMaxVersion sets the maximum TLS version ( 1.0, 1.1, 1.2 or 1.3 ).
+func GRPCTLSMaxVersion(v string) grpcRunnerOption {
+	return func(c *grpcRunnerConfig) error {
+		if _, err := parseTLSVersion(v); err != nil {
+			return err
+		}
+		c.TLSMaxVersion = v
+		return nil
+	}
+}
+
+// GRPCALPN sets the protocols advertised by ALPN.
+func GRPCALPN(protos ...string) grpcRunnerOption {
+	return func(c *grpcRunnerConfig) error {
+		c.ALPN = protos
+		return nil
+	}
+}
+
 func SSHConfig(p string) sshRunnerOption {
 	return func(c *sshRunnerConfig) error {
 		c.SSHConfig = p
@@ -297,3 +683,50 @@ func LocalForward(l string) sshRunnerOption {
 		return nil
 	}
 }
+
+// WSHeader sets the header of the WebSocket opening handshake.
+func WSHeader(key, value string) wsRunnerOption {
+	return func(c *wsRunnerConfig) error {
+		if c.Headers == nil {
+			c.Headers = map[string]string{}
+		}
+		c.Headers[key] = value
+		return nil
+	}
+}
+
+// WSSubprotocols sets the subprotocols requested in the WebSocket opening handshake.
+func WSSubprotocols(protocols ...string) wsRunnerOption {
+	return func(c *wsRunnerConfig) error {
+		c.Subprotocols = unique(append(c.Subprotocols, protocols...))
+		return nil
+	}
+}
+
+func WSCACert(path string) wsRunnerOption {
+	return func(c *wsRunnerConfig) error {
+		c.CACert = path
+		return nil
+	}
+}
+
+func WSCert(path string) wsRunnerOption {
+	return func(c *wsRunnerConfig) error {
+		c.Cert = path
+		return nil
+	}
+}
+
+func WSKey(path string) wsRunnerOption {
+	return func(c *wsRunnerConfig) error {
+		c.Key = path
+		return nil
+	}
+}
+
+func WSSkipVerify(skip bool) wsRunnerOption {
+	return func(c *wsRunnerConfig) error {
+		c.SkipVerify = skip
+		return nil
+	}
+}
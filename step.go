@@ -18,6 +18,8 @@ type step struct {
 	cdpActions    map[string]any
 	sshRunner     *sshRunner
 	sshCommand    map[string]any
+	wsRunner      *wsRunner
+	wsRequest     map[string]any
 	execRunner    *execRunner
 	execCommand   map[string]any
 	testRunner    *testRunner
@@ -56,6 +58,8 @@ func (s *step) generateTrail() Trail {
 		tr.StepRunnerType = RunnerTypeCDP
 	case s.sshRunner != nil && s.sshCommand != nil:
 		tr.StepRunnerType = RunnerTypeSSH
+	case s.wsRunner != nil && s.wsRequest != nil:
+		tr.StepRunnerType = RunnerTypeWS
 	case s.execRunner != nil && s.execCommand != nil:
 		tr.StepRunnerType = RunnerTypeExec
 	case s.includeRunner != nil && s.includeConfig != nil:
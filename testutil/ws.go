@@ -0,0 +1,66 @@
+package testutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+const WSSubprotocol = "echo"
+
+// WSServer returns the WebSocket server that echoes received messages.
+// The server sends a welcome message on connect, and closes the connection with the code 1000 when it receives "bye".
+func WSServer(t *testing.T) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u := ws.HTTPUpgrader{
+			Protocol: func(p string) bool {
+				return p == WSSubprotocol
+			},
+			Header: http.Header{
+				"X-Echo": []string{r.Header.Get("X-Echo")},
+			},
+		}
+		conn, _, _, err := u.Upgrade(r, w)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if err := wsutil.WriteServerText(conn, []byte(`{"type":"welcome"}`)); err != nil {
+			return
+		}
+		for {
+			b, op, err := wsutil.ReadClientData(conn)
+			if err != nil {
+				return
+			}
+			if op == ws.OpText && string(b) == "bye" {
+				f := ws.NewCloseFrame(ws.NewCloseFrameBody(ws.StatusNormalClosure, "bye"))
+				if err := ws.WriteFrame(conn, f); err != nil {
+					return
+				}
+				// Wait for the close frame from the client
+				for {
+					if _, _, err := wsutil.ReadClientData(conn); err != nil {
+						return
+					}
+				}
+			}
+			if err := wsutil.WriteServerMessage(conn, op, b); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(func() {
+		ts.Close()
+	})
+	return ts
+}
+
+// WSURL returns the WebSocket URL of the test server.
+func WSURL(ts *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(ts.URL, "http")
+}
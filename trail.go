@@ -19,6 +19,7 @@ const (
 	RunnerTypeGRPC    RunnerType = "grpc"
 	RunnerTypeCDP     RunnerType = "cdp"
 	RunnerTypeSSH     RunnerType = "ssh"
+	RunnerTypeWS      RunnerType = "ws"
 	RunnerTypeExec    RunnerType = "exec"
 	RunnerTypeTest    RunnerType = "test"
 	RunnerTypeDump    RunnerType = "dump"
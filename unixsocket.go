@@ -0,0 +1,78 @@
+package runn
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	httpUnixScheme  = "http+unix://"
+	httpsUnixScheme = "https+unix://"
+	grpcUnixScheme  = "grpc+unix://"
+	unixScheme      = "unix://"
+	// Host of the endpoint of HTTP runner over unix domain socket.
+	unixSocketHost = "localhost"
+)
+
+func isHTTPUnixSocketEndpoint(endpoint string) bool {
+	return strings.HasPrefix(endpoint, httpUnixScheme) || strings.HasPrefix(endpoint, httpsUnixScheme)
+}
+
+func isGRPCUnixSocketTarget(target string) bool {
+	return strings.HasPrefix(target, "unix:") || strings.HasPrefix(target, "unix-abstract:")
+}
+
+// parseHTTPUnixSocketEndpoint parses the endpoint of HTTP over unix domain socket and returns the socket path and the endpoint URL.
+// The socket path is specified as the path ( http+unix:///var/run/app.sock ) or
+// as the percent-encoded host followed by the base path ( http+unix://%2Fvar%2Frun%2Fapp.sock/api/v1 ).
+func parseHTTPUnixSocketEndpoint(endpoint string) (string, *url.URL, error) {
+	scheme := "http"
+	rest := strings.TrimPrefix(endpoint, httpUnixScheme)
+	if strings.HasPrefix(endpoint, httpsUnixScheme) {
+		scheme = "https"
+		rest = strings.TrimPrefix(endpoint, httpsUnixScheme)
+	}
+	var socket, p string
+	if strings.HasPrefix(rest, "/") {
+		socket = rest
+	} else {
+		host := rest
+		if i := strings.Index(rest, "/"); i >= 0 {
+			host = rest[:i]
+			p = rest[i:]
+		}
+		s, err := url.PathUnescape(host)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid unix domain socket endpoint: %s: %w", endpoint, err)
+		}
+		socket = s
+	}
+	if socket == "" {
+		return "", nil, fmt.Errorf("invalid unix domain socket endpoint: %s: socket path is empty", endpoint)
+	}
+	u, err := url.Parse(fmt.Sprintf("%s://%s%s", scheme, unixSocketHost, p))
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid unix domain socket endpoint: %s: %w", endpoint, err)
+	}
+	return socket, u, nil
+}
+
+// unixSocketTransport returns *http.Transport that connects to the unix domain socket regardless of the host of requests.
+func unixSocketTransport(socket string) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", socket)
+	}
+	return t
+}
+
+// grpcUnixSocketTarget converts the DSN of gRPC runner over unix domain socket ( grpc+unix:///var/run/app.sock ) to the target of gRPC ( unix:///var/run/app.sock ).
+func grpcUnixSocketTarget(dsn string) string {
+	return unixScheme + strings.TrimPrefix(dsn, grpcUnixScheme)
+}
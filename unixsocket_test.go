@@ -0,0 +1,178 @@
+package runn
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+)
+
+func TestParseHTTPUnixSocketEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint     string
+		wantSocket   string
+		wantEndpoint string
+		wantErr      bool
+	}{
+		{"http+unix:///var/run/app.sock", "/var/run/app.sock", "http://localhost", false},
+		{"https+unix:///var/run/app.sock", "/var/run/app.sock", "https://localhost", false},
+		{"http+unix://%2Fvar%2Frun%2Fapp.sock/api/v1", "/var/run/app.sock", "http://localhost/api/v1", false},
+		{"http+unix://app.sock", "app.sock", "http://localhost", false},
+		{"http+unix://", "", "", true},
+		{"http+unix://%zz/api", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			socket, u, err := parseHTTPUnixSocketEndpoint(tt.endpoint)
+			if err != nil {
+				if !tt.wantErr {
+					t.Error(err)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Error("want error")
+			}
+			if socket != tt.wantSocket {
+				t.Errorf("got %v\nwant %v", socket, tt.wantSocket)
+			}
+			if u.String() != tt.wantEndpoint {
+				t.Errorf("got %v\nwant %v", u.String(), tt.wantEndpoint)
+			}
+		})
+	}
+}
+
+// unixSocketPath returns the short path of the unix domain socket because the length of the path is limited.
+func unixSocketPath(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "runn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+	return filepath.Join(dir, "app.sock")
+}
+
+func TestHTTPRunnerUnixSocket(t *testing.T) {
+	sock := unixSocketPath(t)
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Host + r.URL.Path))
+	})}
+	go func() {
+		_ = s.Serve(l)
+	}()
+	t.Cleanup(func() {
+		_ = s.Close()
+	})
+
+	tests := []struct {
+		name string
+		dsn  string
+		opts []httpRunnerOption
+		want string
+	}{
+		{"path", "http+unix://" + sock, nil, "localhost/users"},
+		{"escaped path with base path", "http+unix://" + url.PathEscape(sock) + "/api/v1", nil, "localhost/api/v1/users"},
+		// resolve: does not override the dialer of the unix domain socket
+		{"resolve", "http+unix://" + sock, []httpRunnerOption{HTTPResolve("localhost:80:127.0.0.1")}, "localhost/users"},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := New(Runner("req", tt.dsn, tt.opts...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, ok := o.httpRunners["req"]
+			if !ok {
+				t.Fatal("http runner not found")
+			}
+			r.operator = o
+			req := &httpRequest{
+				path:    "/users",
+				method:  http.MethodGet,
+				headers: http.Header{},
+			}
+			if err := r.Run(ctx, req); err != nil {
+				t.Fatal(err)
+			}
+			res, ok := o.store.latest()["res"].(map[string]any)
+			if !ok {
+				t.Fatalf("invalid res: %#v", o.store.latest()["res"])
+			}
+			if got := res["rawBody"]; got != tt.want {
+				t.Errorf("got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGrpcRunnerUnixSocket(t *testing.T) {
+	sock := unixSocketPath(t)
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	reflection.Register(s)
+	go func() {
+		_ = s.Serve(l)
+	}()
+	t.Cleanup(s.Stop)
+
+	ctx := context.Background()
+	for _, dsn := range []string{"unix://" + sock, "grpc+unix://" + sock} {
+		t.Run(dsn, func(t *testing.T) {
+			o, err := New(Runner("greq", dsn))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, ok := o.grpcRunners["greq"]
+			if !ok {
+				t.Fatal("grpc runner not found")
+			}
+			r.operator = o
+			t.Cleanup(func() {
+				_ = r.Close()
+			})
+			req := &grpcRequest{
+				service: "grpc.health.v1.Health",
+				method:  "Check",
+				headers: metadata.MD{},
+				messages: []*grpcMessage{
+					{
+						op:     GRPCOpMessage,
+						params: map[string]any{},
+					},
+				},
+			}
+			if err := r.Run(ctx, req); err != nil {
+				t.Fatal(err)
+			}
+			res, ok := o.store.latest()[grpcStoreResponseKey].(map[string]any)
+			if !ok {
+				t.Fatalf("invalid res: %#v", o.store.latest()[grpcStoreResponseKey])
+			}
+			if got := res[grpcStoreStatusKey]; got != 0 {
+				t.Errorf("got %v\nwant %v", got, 0)
+			}
+		})
+	}
+}
@@ -0,0 +1,349 @@
+package runn
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/goccy/go-json"
+	"github.com/k1LoW/runn/version"
+)
+
+type WSOp string
+
+const (
+	WSOpText    WSOp = "text"
+	WSOpBinary  WSOp = "binary"
+	WSOpJSON    WSOp = "json"
+	WSOpReceive WSOp = "receive"
+	WSOpClose   WSOp = "close"
+)
+
+const (
+	wsStoreHeaderKey   = "headers"
+	wsStoreProtocolKey = "protocol"
+	wsStoreMessageKey  = "message"
+	wsStoreMessagesKey = "messages"
+	wsStoreCloseKey    = "close"
+	wsStoreResponseKey = "res"
+)
+
+const (
+	wsMessageTypeKey = "type"
+	wsMessageDataKey = "data"
+	wsMessageJSONKey = "json"
+	wsCloseCodeKey   = "code"
+	wsCloseReasonKey = "reason"
+)
+
+const (
+	wsDialTimeout    = 10 * time.Second
+	wsReceiveTimeout = 30 * time.Second
+	wsCloseTimeout   = 5 * time.Second
+)
+
+type wsRunner struct {
+	name         string
+	endpoint     string
+	headers      http.Header
+	subprotocols []string
+	cacert       []byte
+	cert         []byte
+	key          []byte
+	skipVerify   bool
+	conn         net.Conn
+	br           io.Reader
+	resHeaders   http.Header
+	protocol     string
+	operator     *operator
+}
+
+type wsMessage struct {
+	op      WSOp
+	data    []byte
+	timeout time.Duration
+	code    int
+	reason  string
+}
+
+type wsRequest struct {
+	headers  http.Header
+	timeout  time.Duration
+	messages []*wsMessage
+}
+
+func newWSRunner(name, endpoint string) (*wsRunner, error) {
+	if !strings.HasPrefix(endpoint, "ws://") && !strings.HasPrefix(endpoint, "wss://") {
+		return nil, fmt.Errorf("invalid WebSocket endpoint: %s", endpoint)
+	}
+	return &wsRunner{
+		name:     name,
+		endpoint: endpoint,
+		headers:  http.Header{},
+	}, nil
+}
+
+// Close sends a close frame and closes the connection.
+func (rnr *wsRunner) Close() error {
+	if rnr.conn == nil {
+		return nil
+	}
+	_ = ws.WriteFrame(rnr.conn, ws.MaskFrameInPlace(ws.NewCloseFrame(ws.NewCloseFrameBody(ws.StatusNormalClosure, ""))))
+	return rnr.disconnect()
+}
+
+func (rnr *wsRunner) Run(ctx context.Context, r *wsRequest) error {
+	rnr.operator.capturers.captureWSStart(rnr.name)
+	defer rnr.operator.capturers.captureWSEnd(rnr.name)
+
+	if rnr.conn == nil {
+		if err := rnr.connect(ctx, r.headers); err != nil {
+			return err
+		}
+	} else if len(r.headers) > 0 {
+		return errors.New("headers: can only be set on the step that opens the connection")
+	}
+	conn := rnr.conn
+	stop := context.AfterFunc(ctx, func() {
+		// Interrupt blocking reads and writes
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	d := map[string]any{
+		string(wsStoreHeaderKey):   rnr.resHeaders,
+		string(wsStoreProtocolKey): rnr.protocol,
+		string(wsStoreMessageKey):  nil,
+	}
+	messages := []map[string]any{}
+	var closedErr error
+	err := func() error {
+		for i, m := range r.messages {
+			switch m.op {
+			case WSOpText, WSOpJSON:
+				if err := wsutil.WriteClientText(conn, m.data); err != nil {
+					return rnr.fail(ctx, fmt.Errorf("failed to send message: %w", err))
+				}
+				rnr.operator.capturers.captureWSSend(rnr.name, wsMessageToMap(ws.OpText, m.data))
+			case WSOpBinary:
+				if err := wsutil.WriteClientBinary(conn, m.data); err != nil {
+					return rnr.fail(ctx, fmt.Errorf("failed to send message: %w", err))
+				}
+				rnr.operator.capturers.captureWSSend(rnr.name, wsMessageToMap(ws.OpBinary, m.data))
+			case WSOpReceive:
+				timeout := m.timeout
+				if timeout == 0 {
+					timeout = r.timeout
+				}
+				if timeout == 0 {
+					timeout = wsReceiveTimeout
+				}
+				_ = conn.SetReadDeadline(time.Now().Add(timeout))
+				b, op, err := wsutil.ReadServerData(rnr.rw())
+				if err != nil {
+					var cerr wsutil.ClosedError
+					if errors.As(err, &cerr) {
+						// Closed by the server
+						rnr.operator.capturers.captureWSClose(rnr.name, int(cerr.Code), cerr.Reason)
+						d[wsStoreCloseKey] = map[string]any{
+							wsCloseCodeKey:   int(cerr.Code),
+							wsCloseReasonKey: cerr.Reason,
+						}
+						if remaining := len(r.messages) - i - 1; remaining > 0 {
+							// The response is recorded to be tested, but the step fails because the remaining messages are not run
+							closedErr = fmt.Errorf("connection closed by the server (code: %d, reason: %q) before running the remaining %d messages", int(cerr.Code), cerr.Reason, remaining)
+						}
+						return rnr.disconnect()
+					}
+					return rnr.fail(ctx, fmt.Errorf("failed to receive message: %w", err))
+				}
+				_ = conn.SetReadDeadline(time.Time{})
+				msg := wsMessageToMap(op, b)
+				rnr.operator.capturers.captureWSReceive(rnr.name, msg)
+				messages = append(messages, msg)
+				d[wsStoreMessageKey] = msg
+			case WSOpClose:
+				code, reason, err := rnr.close(m.code, m.reason)
+				if err != nil {
+					return rnr.fail(ctx, fmt.Errorf("failed to close connection: %w", err))
+				}
+				rnr.operator.capturers.captureWSClose(rnr.name, code, reason)
+				d[wsStoreCloseKey] = map[string]any{
+					wsCloseCodeKey:   code,
+					wsCloseReasonKey: reason,
+				}
+				return nil
+			default:
+				return fmt.Errorf("invalid op: %v", m.op)
+			}
+		}
+		return nil
+	}()
+	if err != nil {
+		return err
+	}
+
+	d[wsStoreMessagesKey] = messages
+	rnr.operator.record(map[string]any{
+		string(wsStoreResponseKey): d,
+	})
+	return closedErr
+}
+
+func (rnr *wsRunner) connect(ctx context.Context, h http.Header) error {
+	reqHeaders := rnr.headers.Clone()
+	if reqHeaders == nil {
+		reqHeaders = http.Header{}
+	}
+	for k, v := range h {
+		reqHeaders[http.CanonicalHeaderKey(k)] = v
+	}
+	if reqHeaders.Get("User-Agent") == "" {
+		reqHeaders.Set("User-Agent", fmt.Sprintf("runn/%s", version.Version))
+	}
+	resHeaders := http.Header{}
+	dialer := ws.Dialer{
+		Timeout:   wsDialTimeout,
+		Protocols: rnr.subprotocols,
+		Header:    ws.HandshakeHeaderHTTP(reqHeaders),
+		OnHeader: func(key, value []byte) error {
+			resHeaders.Add(string(key), string(value))
+			return nil
+		},
+	}
+	if strings.HasPrefix(rnr.endpoint, "wss://") {
+		tlsc, err := rnr.tlsConfig()
+		if err != nil {
+			return err
+		}
+		dialer.TLSConfig = tlsc
+	}
+	conn, br, hs, err := dialer.Dial(ctx, rnr.endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", rnr.endpoint, err)
+	}
+	rnr.conn = conn
+	rnr.br = conn
+	if br != nil {
+		// Frames already read during the handshake remain in br
+		rnr.br = br
+	}
+	rnr.resHeaders = resHeaders
+	rnr.protocol = hs.Protocol
+	rnr.operator.capturers.captureWSConnect(rnr.name, rnr.endpoint, resHeaders)
+	return nil
+}
+
+// close performs the closing handshake and returns the code and reason of the close frame sent back by the server.
+func (rnr *wsRunner) close(code int, reason string) (int, string, error) {
+	if code == 0 {
+		code = int(ws.StatusNormalClosure)
+	}
+	f := ws.NewCloseFrame(ws.NewCloseFrameBody(ws.StatusCode(code), reason))
+	if err := ws.WriteFrame(rnr.conn, ws.MaskFrameInPlace(f)); err != nil {
+		return 0, "", err
+	}
+	_ = rnr.conn.SetReadDeadline(time.Now().Add(wsCloseTimeout))
+	for {
+		f, err := ws.ReadFrame(rnr.br)
+		if err != nil {
+			_ = rnr.disconnect()
+			return 0, "", err
+		}
+		if f.Header.OpCode != ws.OpClose {
+			// Discard messages received before the close frame
+			continue
+		}
+		c, r := ws.ParseCloseFrameData(f.Payload)
+		if err := rnr.disconnect(); err != nil {
+			return 0, "", err
+		}
+		return int(c), r, nil
+	}
+}
+
+// fail disconnects the broken connection and returns the error.
+func (rnr *wsRunner) fail(ctx context.Context, err error) error {
+	_ = rnr.disconnect()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+func (rnr *wsRunner) disconnect() error {
+	if rnr.conn == nil {
+		return nil
+	}
+	err := rnr.conn.Close()
+	rnr.conn = nil
+	rnr.br = nil
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		return err
+	}
+	return nil
+}
+
+func (rnr *wsRunner) rw() io.ReadWriter {
+	return struct {
+		io.Reader
+		io.Writer
+	}{rnr.br, rnr.conn}
+}
+
+func (rnr *wsRunner) tlsConfig() (*tls.Config, error) {
+	tlsc := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(rnr.cert) != 0 {
+		certificate, err := tls.X509KeyPair(rnr.cert, rnr.key)
+		if err != nil {
+			return nil, err
+		}
+		tlsc.Certificates = []tls.Certificate{certificate}
+	}
+	if rnr.skipVerify {
+		//#nosec G402
+		tlsc.InsecureSkipVerify = true
+	} else if len(rnr.cacert) != 0 {
+		certpool, err := x509.SystemCertPool()
+		if err != nil {
+			// FIXME for Windows
+			// ref: https://github.com/golang/go/issues/18609
+			certpool = x509.NewCertPool()
+		}
+		if ok := certpool.AppendCertsFromPEM(rnr.cacert); !ok {
+			return nil, errors.New("failed to append cacert")
+		}
+		tlsc.RootCAs = certpool
+	}
+	return tlsc, nil
+}
+
+// wsMessageToMap converts a message to the value recorded in the store.
+// The data of a binary message is encoded in base64.
+func wsMessageToMap(op ws.OpCode, b []byte) map[string]any {
+	if op == ws.OpBinary {
+		return map[string]any{
+			wsMessageTypeKey: string(WSOpBinary),
+			wsMessageDataKey: base64.StdEncoding.EncodeToString(b),
+		}
+	}
+	m := map[string]any{
+		wsMessageTypeKey: string(WSOpText),
+		wsMessageDataKey: string(b),
+	}
+	var v any
+	if err := json.Unmarshal(b, &v); err == nil {
+		m[wsMessageJSONKey] = v
+	}
+	return m
+}
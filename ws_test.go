@@ -0,0 +1,262 @@
+package runn
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/k1LoW/runn/testutil"
+)
+
+func TestWSRunner(t *testing.T) {
+	ts := testutil.WSServer(t)
+	ctx := context.Background()
+	out := new(bytes.Buffer)
+	o, err := New(Capture(NewDebugger(out)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := newWSRunner("ws", testutil.WSURL(ts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.subprotocols = []string{testutil.WSSubprotocol}
+	r.operator = o
+	t.Cleanup(func() {
+		_ = r.Close()
+	})
+	welcome := map[string]any{"type": "text", "data": `{"type":"welcome"}`, "json": map[string]any{"type": "welcome"}}
+
+	tests := []struct {
+		name      string
+		req       *wsRequest
+		want      []map[string]any
+		wantClose map[string]any
+		wantErr   bool
+	}{
+		{
+			"send and receive",
+			&wsRequest{
+				headers: http.Header{"X-Echo": []string{"runn"}},
+				messages: []*wsMessage{
+					{op: WSOpReceive},
+					{op: WSOpText, data: []byte("hello")},
+					{op: WSOpReceive},
+					{op: WSOpJSON, data: []byte(`{"num":1}`)},
+					{op: WSOpBinary, data: []byte{1, 2, 3}},
+					{op: WSOpReceive},
+					{op: WSOpReceive},
+				},
+			},
+			[]map[string]any{
+				welcome,
+				{"type": "text", "data": "hello"},
+				{"type": "text", "data": `{"num":1}`, "json": map[string]any{"num": float64(1)}},
+				{"type": "binary", "data": "AQID"},
+			},
+			nil,
+			false,
+		},
+		{
+			"headers on established connection",
+			&wsRequest{
+				headers: http.Header{"X-Echo": []string{"runn"}},
+				messages: []*wsMessage{
+					{op: WSOpReceive},
+				},
+			},
+			nil,
+			nil,
+			true,
+		},
+		{
+			"closed by server",
+			&wsRequest{
+				messages: []*wsMessage{
+					{op: WSOpText, data: []byte("bye")},
+					{op: WSOpReceive},
+				},
+			},
+			[]map[string]any{},
+			map[string]any{"code": 1000, "reason": "bye"},
+			false,
+		},
+		{
+			"closed by server with remaining messages",
+			&wsRequest{
+				messages: []*wsMessage{
+					{op: WSOpReceive},
+					{op: WSOpText, data: []byte("bye")},
+					{op: WSOpReceive},
+					{op: WSOpReceive},
+				},
+			},
+			[]map[string]any{
+				welcome,
+			},
+			map[string]any{"code": 1000, "reason": "bye"},
+			true,
+		},
+		{
+			"reconnect and close",
+			&wsRequest{
+				messages: []*wsMessage{
+					{op: WSOpReceive},
+					{op: WSOpClose, code: 4000},
+					{op: WSOpReceive},
+				},
+			},
+			[]map[string]any{
+				welcome,
+			},
+			map[string]any{"code": 4000, "reason": ""},
+			false,
+		},
+		{
+			"receive timeout",
+			&wsRequest{
+				timeout: 100 * time.Millisecond,
+				messages: []*wsMessage{
+					{op: WSOpReceive},
+					{op: WSOpReceive},
+				},
+			},
+			nil,
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		o.store.clearSteps()
+		err := r.Run(ctx, tt.req)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: got %v\nwantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		// The response is recorded even if the connection is closed by the server with remaining messages
+		if err != nil && tt.wantClose == nil {
+			continue
+		}
+		res, ok := o.store.latest()["res"].(map[string]any)
+		if !ok {
+			t.Fatalf("%s: invalid res: %#v", tt.name, o.store.latest()["res"])
+		}
+		if diff := cmp.Diff(res["messages"], tt.want); diff != "" {
+			t.Errorf("%s: %s", tt.name, diff)
+		}
+		if tt.wantClose == nil {
+			if _, ok := res["close"]; ok {
+				t.Errorf("%s: got close %v", tt.name, res["close"])
+			}
+		} else if diff := cmp.Diff(res["close"], tt.wantClose); diff != "" {
+			t.Errorf("%s: %s", tt.name, diff)
+		}
+		if got := res["protocol"]; got != testutil.WSSubprotocol {
+			t.Errorf("%s: got %v\nwant %v", tt.name, got, testutil.WSSubprotocol)
+		}
+	}
+	if r.conn != nil {
+		t.Error("connection should be closed after receive timeout")
+	}
+	for _, want := range []string{">>>>>START WebSocket>>>>>", "-----START WebSocket SEND MESSAGE-----", "-----START WebSocket RECEIVE MESSAGE-----", "-----START WebSocket CLOSE-----"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("debugger output should contain %q", want)
+		}
+	}
+}
+
+func TestWSRunnerHeaders(t *testing.T) {
+	ts := testutil.WSServer(t)
+	ctx := context.Background()
+	o, err := New(WSRunner("ws", testutil.WSURL(ts), WSHeader("X-Echo", "runner")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, ok := o.wsRunners["ws"]
+	if !ok {
+		t.Fatal("ws runner not found")
+	}
+	t.Cleanup(func() {
+		_ = r.Close()
+	})
+	if err := r.Run(ctx, &wsRequest{messages: []*wsMessage{{op: WSOpReceive}}}); err != nil {
+		t.Fatal(err)
+	}
+	res, ok := o.store.latest()["res"].(map[string]any)
+	if !ok {
+		t.Fatalf("invalid res: %#v", o.store.latest()["res"])
+	}
+	h, ok := res["headers"].(http.Header)
+	if !ok {
+		t.Fatalf("invalid headers: %#v", res["headers"])
+	}
+	if got := h.Get("X-Echo"); got != "runner" {
+		t.Errorf("got %v\nwant %v", got, "runner")
+	}
+	if got := res["protocol"]; got != "" {
+		t.Errorf("got %v\nwant %v", got, "")
+	}
+}
+
+func TestParseRunnerWithWS(t *testing.T) {
+	tests := []struct {
+		v       any
+		want    *wsRunner
+		wantErr bool
+	}{
+		{
+			"ws://example.com/chat",
+			&wsRunner{name: "ws", endpoint: "ws://example.com/chat", headers: http.Header{}},
+			false,
+		},
+		{
+			map[string]any{
+				"endpoint":     "wss://example.com/chat",
+				"headers":      map[string]any{"Authorization": "Bearer xxxxx"},
+				"subprotocols": []any{"chat.v1", "chat.v2"},
+				"skipVerify":   true,
+			},
+			&wsRunner{
+				name:         "ws",
+				endpoint:     "wss://example.com/chat",
+				headers:      http.Header{"Authorization": []string{"Bearer xxxxx"}},
+				subprotocols: []string{"chat.v1", "chat.v2"},
+				skipVerify:   true,
+			},
+			false,
+		},
+		{
+			map[string]any{
+				"endpoint": "wss://example.com/chat",
+				"cacert":   "testdata/notexist.pem",
+			},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		bk := newBook()
+		if err := bk.parseRunner("ws", tt.v); err != nil {
+			if !tt.wantErr {
+				t.Errorf("got error: %v", err)
+			}
+			continue
+		}
+		if tt.wantErr {
+			t.Error("want error")
+			continue
+		}
+		if _, ok := bk.httpRunners["ws"]; ok {
+			t.Error("should not be detected as HTTP runner")
+		}
+		got := bk.wsRunners["ws"]
+		opts := cmp.AllowUnexported(wsRunner{})
+		if diff := cmp.Diff(got, tt.want, opts); diff != "" {
+			t.Error(diff)
+		}
+	}
+}
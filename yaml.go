@@ -17,8 +17,15 @@ import (
 // CreateHTTPStepMapSlice creates yaml.MapSlice from *http.Request.
 func CreateHTTPStepMapSlice(key string, req *http.Request) (yaml.MapSlice, error) {
 	endpoint := req.URL.Path
+	var query url.Values
 	if req.URL.RawQuery != "" {
-		endpoint = fmt.Sprintf("%s?%s", endpoint, req.URL.RawQuery)
+		q, err := url.ParseQuery(req.URL.RawQuery)
+		if err != nil {
+			// Keep query string as is
+			endpoint = fmt.Sprintf("%s?%s", endpoint, req.URL.RawQuery)
+		} else {
+			query = q
+		}
 	}
 	if endpoint == "" {
 		endpoint = "/"
@@ -27,12 +34,12 @@ func CreateHTTPStepMapSlice(key string, req *http.Request) (yaml.MapSlice, error
 	hb := yaml.MapSlice{}
 	// headers
 	contentType := req.Header.Get("Content-Type")
-	h := map[string]string{}
+	h := map[string]any{}
 	for k, v := range req.Header {
 		if k == "Content-Type" || k == "Host" {
 			continue
 		}
-		h[k] = v[0]
+		h[k] = singleOrMulti(v)
 	}
 	if len(h) > 0 {
 		hb = append(hb, yaml.MapItem{
@@ -41,6 +48,18 @@ func CreateHTTPStepMapSlice(key string, req *http.Request) (yaml.MapSlice, error
 		})
 	}
 
+	// query
+	if len(query) > 0 {
+		q := map[string]any{}
+		for k, v := range query {
+			q[k] = singleOrMulti(v)
+		}
+		hb = append(hb, yaml.MapItem{
+			Key:   "query",
+			Value: q,
+		})
+	}
+
 	// body
 	var bd yaml.MapSlice
 	var (
@@ -191,3 +210,11 @@ func drainBody(b io.ReadCloser) (r1, r2 io.ReadCloser, err error) {
 	}
 	return io.NopCloser(&buf), io.NopCloser(bytes.NewReader(buf.Bytes())), nil
 }
+
+// singleOrMulti returns the value itself if the length of values is 1, otherwise returns values.
+func singleOrMulti(values []string) any {
+	if len(values) == 1 {
+		return values[0]
+	}
+	return values
+}